@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/motemen/prchecklist/v2/lib/fakegithub"
+)
+
+// runFakeGitHub runs "prchecklist fake-github", which serves a fake GitHub driven by fixtures
+// for local development without a real OAuth app and repository.
+func runFakeGitHub(args []string) error {
+	flags := flag.NewFlagSet("fake-github", flag.ExitOnError)
+	fixturesDir := flags.String("fixtures", "", "`directory` of YAML fixtures")
+	addr := flags.String("listen", ":8090", "`address` to listen")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *fixturesDir == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	fixtures, err := fakegithub.LoadFixtures(*fixturesDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("fake GitHub starting at %s", *addr)
+	return http.ListenAndServe(*addr, fakegithub.New(fixtures))
+}
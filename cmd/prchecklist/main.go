@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +14,8 @@ import (
 
 	_ "github.com/motemen/go-loghttp/global"
 
+	"gopkg.in/yaml.v2"
+
 	"github.com/motemen/prchecklist/v2"
 	"github.com/motemen/prchecklist/v2/lib/gateway"
 	"github.com/motemen/prchecklist/v2/lib/repository"
@@ -23,6 +26,9 @@ import (
 var (
 	datasource   string
 	addr         string
+	mentionsFile string
+	secretsFile  string
+	provider     string
 	showVersion  bool
 	showLicenses bool
 )
@@ -51,6 +57,9 @@ func init() {
 		port = "8080"
 	}
 	flag.StringVar(&addr, "listen", ":"+port, "`address` to listen")
+	flag.StringVar(&mentionsFile, "mentions", os.Getenv("PRCHECKLIST_MENTIONS"), "`path` to YAML mapping GitHub logins to chat user IDs (PRCHECKLIST_MENTIONS)")
+	flag.StringVar(&secretsFile, "secrets", os.Getenv("PRCHECKLIST_SECRETS"), "`path` to YAML mapping names to secrets referred from prchecklist.yml and the repositories allowed (PRCHECKLIST_SECRETS)")
+	flag.StringVar(&provider, "provider", getenv("PRCHECKLIST_PROVIDER", "github"), "code hosting `service`, \"github\" or \"gitlab\" (PRCHECKLIST_PROVIDER)")
 	flag.BoolVar(&showVersion, "version", false, "show version information")
 	flag.BoolVar(&showLicenses, "licenses", false, "show license notifications")
 }
@@ -71,19 +80,58 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flag.Arg(0) == "fake-github" {
+		log.Fatal(runFakeGitHub(flag.Args()[1:]))
+	}
+
 	coreRepo, err := repository.NewCore(datasource)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	github, err := gateway.NewGitHub()
+	var github interface {
+		usecase.GitHubGateway
+		web.GitHubGateway
+	}
+	switch provider {
+	case "github":
+		github, err = gateway.NewGitHub()
+	case "gitlab":
+		github, err = gateway.NewGitLab()
+	default:
+		err = fmt.Errorf("unknown provider: %q", provider)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	app := usecase.New(github, coreRepo)
+
+	if mentionsFile != "" {
+		mentions, err := loadStringMap(mentionsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.SetMentions(mentions)
+	}
+
+	if secretsFile != "" {
+		secrets, err := loadSecrets(secretsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.SetSecrets(secrets)
+	}
+
 	w := web.New(app, github)
 
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	outboxDone := make(chan struct{})
+	go func() {
+		app.RunOutbox(outboxCtx)
+		close(outboxDone)
+	}()
+
 	log.Printf("prchecklist starting at %s", addr)
 
 	server := http.Server{
@@ -119,4 +167,36 @@ func main() {
 			log.Fatalf("while shutdown: %s", err)
 		}
 	}
+
+	// Undelivered notifications remain in the outbox for the next run
+	app.FlushNotificationDigests(ctx)
+	stopOutbox()
+	select {
+	case <-outboxDone:
+	case <-ctx.Done():
+		log.Printf("outbox did not stop in time")
+	}
+}
+
+// loadStringMap reads a YAML file of a map from strings to strings.
+func loadStringMap(path string) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]string
+	err = yaml.Unmarshal(buf, &m)
+	return m, err
+}
+
+func loadSecrets(path string) (map[string]usecase.Secret, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]usecase.Secret
+	err = yaml.UnmarshalStrict(buf, &m)
+	return m, err
 }
@@ -25,6 +25,36 @@ func ContextClient(ctx context.Context) *http.Client {
 	return http.DefaultClient
 }
 
+var contextKeyHost = &contextKey{"host"}
+
+// ContextWithHost creates a context telling the gateway the domain of GitHub to access,
+// which is empty for the default one.
+func ContextWithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, contextKeyHost, host)
+}
+
+// ContextHost returns the domain of GitHub associated by ContextWithHost,
+// or empty for the default one.
+func ContextHost(ctx context.Context) string {
+	host, _ := ctx.Value(contextKeyHost).(string)
+	return host
+}
+
+var contextKeyUserID = &contextKey{"userID"}
+
+// ContextWithUserID creates a context telling the gateway the ID of the GitHub user
+// whose client is associated by ContextKeyHTTPClient.
+func ContextWithUserID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, contextKeyUserID, id)
+}
+
+// ContextUserID returns the ID of the GitHub user associated by ContextWithUserID.
+// ok is false if the client is not the one of a user, such as a service one.
+func ContextUserID(ctx context.Context) (id int, ok bool) {
+	id, ok = ctx.Value(contextKeyUserID).(int)
+	return
+}
+
 var contextKeyRequestOrigin = &contextKey{"requestOrigin"}
 
 // RequestContext creates a context from an HTTP request req,
@@ -63,5 +93,7 @@ func NewContextWithValuesOf(base context.Context) context.Context {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, ContextKeyHTTPClient, base.Value(ContextKeyHTTPClient))
 	ctx = context.WithValue(ctx, contextKeyRequestOrigin, base.Value(contextKeyRequestOrigin))
+	ctx = context.WithValue(ctx, contextKeyHost, base.Value(contextKeyHost))
+	ctx = context.WithValue(ctx, contextKeyUserID, base.Value(contextKeyUserID))
 	return ctx
 }
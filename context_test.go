@@ -3,6 +3,7 @@ package prchecklist
 import "testing"
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -23,6 +24,28 @@ func TestRequestContext(t *testing.T) {
 	}
 }
 
+func TestContextHost(t *testing.T) {
+	ctx := ContextWithHost(context.Background(), "ghe.example.com")
+	if expected, got := "ghe.example.com", ContextHost(NewContextWithValuesOf(ctx)); got != expected {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+
+	if expected, got := "", ContextHost(context.Background()); got != expected {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestContextUserID(t *testing.T) {
+	ctx := ContextWithUserID(context.Background(), 8465)
+	if id, ok := ContextUserID(NewContextWithValuesOf(ctx)); !ok || id != 8465 {
+		t.Errorf("expected 8465 but got %v, %v", id, ok)
+	}
+
+	if _, ok := ContextUserID(context.Background()); ok {
+		t.Errorf("expected no user")
+	}
+}
+
 func TestBuildURL(t *testing.T) {
 	req, err := http.NewRequest("GET", "https://example.com:1234/foo/bar", nil)
 	if err != nil {
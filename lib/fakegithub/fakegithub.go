@@ -0,0 +1,567 @@
+// Package fakegithub implements a fake GitHub serving the subset of API which prchecklist uses,
+// from the data given as Fixtures, for local development and end-to-end tests.
+//
+// It is laid out as GitHub Enterprise: REST API at /api/v3/, GraphQL API at /api/graphql
+// and OAuth at /login/oauth/. Access tokens are "fake:<login>" of the users in the fixtures,
+// issued with refresh tokens "fake-refresh:<login>" as expiring user tokens of GitHub Apps.
+package fakegithub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	tokenPrefix        = "fake:"
+	refreshTokenPrefix = "fake-refresh:"
+	// tokenExpiresIn is the lifetime of access tokens in seconds, same as GitHub's
+	tokenExpiresIn = 8 * 60 * 60
+)
+
+// Status is a commit status created through the API.
+type Status struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+// Comment is an issue comment created through the API.
+type Comment struct {
+	ID     int64  `json:"id"`
+	Number int    `json:"-"`
+	Body   string `json:"body"`
+	User   string `json:"-"`
+}
+
+// CheckRun is a check run created through the API.
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+// Server is the fake GitHub, which also records the writes to it.
+type Server struct {
+	fixtures *Fixtures
+	router   *mux.Router
+
+	mu sync.Mutex
+	// statuses are by "owner/repo@sha"
+	statuses map[string][]Status
+	// comments and checkRuns are by "owner/repo"
+	comments  map[string][]*Comment
+	checkRuns map[string][]*CheckRun
+	lastID    int64
+}
+
+// New creates a fake GitHub serving fixtures.
+func New(fixtures *Fixtures) *Server {
+	s := &Server{
+		fixtures:  fixtures,
+		statuses:  map[string][]Status{},
+		comments:  map[string][]*Comment{},
+		checkRuns: map[string][]*CheckRun{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/login/oauth/authorize", s.handleAuthorize).Methods("GET")
+	router.HandleFunc("/login/oauth/access_token", s.handleAccessToken).Methods("POST")
+	router.HandleFunc("/api/graphql", s.handleGraphQL).Methods("POST")
+
+	api := router.PathPrefix("/api/v3").Subrouter()
+	api.HandleFunc("/user", s.handleUser).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/git/blobs/{sha}", s.handleGetBlob).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/pulls", s.handleListPullRequests).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/statuses/{sha}", s.handleCreateStatus).Methods("POST")
+	api.HandleFunc("/repos/{owner}/{repo}/commits/{sha}/statuses", s.handleListStatuses).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/issues/{number:[0-9]+}/comments", s.handleCreateComment).Methods("POST")
+	api.HandleFunc("/repos/{owner}/{repo}/issues/{number:[0-9]+}/comments", s.handleListComments).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/issues/comments/{id:[0-9]+}", s.handleEditComment).Methods("PATCH")
+	api.HandleFunc("/repos/{owner}/{repo}/check-runs", s.handleCreateCheckRun).Methods("POST")
+	api.HandleFunc("/repos/{owner}/{repo}/commits/{sha}/check-runs", s.handleListCheckRuns).Methods("GET")
+
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.Printf("fakegithub: not implemented: %s %s", req.Method, req.URL)
+		writeError(w, http.StatusNotFound, "Not Found")
+	})
+
+	s.router = router
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.router.ServeHTTP(w, req)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("fakegithub: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func baseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// authUser returns the user of the access token of req.
+func (s *Server) authUser(req *http.Request) *User {
+	auth := req.Header.Get("Authorization")
+	for _, scheme := range []string{"Bearer ", "token "} {
+		if strings.HasPrefix(auth, scheme) {
+			token := strings.TrimPrefix(auth, scheme)
+			if strings.HasPrefix(token, tokenPrefix) {
+				return s.fixtures.user(strings.TrimPrefix(token, tokenPrefix))
+			}
+		}
+	}
+	return nil
+}
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<title>Fake GitHub</title>
+<p>Log in to prchecklist as:</p>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Login}}</a></li>
+{{end}}</ul>
+`))
+
+// handleAuthorize authorizes without asking if there is only one user,
+// or lets the visitor choose one of the users.
+func (s *Server) handleAuthorize(w http.ResponseWriter, req *http.Request) {
+	redirectURI, err := url.Parse(req.URL.Query().Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "redirect_uri required", http.StatusBadRequest)
+		return
+	}
+
+	callback := func(login string) string {
+		u := *redirectURI
+		q := u.Query()
+		q.Set("code", login)
+		q.Set("state", req.URL.Query().Get("state"))
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	if login := req.URL.Query().Get("login"); login != "" || len(s.fixtures.Users) == 1 {
+		if login == "" {
+			login = s.fixtures.Users[0].Login
+		}
+		if s.fixtures.user(login) == nil {
+			http.Error(w, "unknown user", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, req, callback(login), http.StatusFound)
+		return
+	}
+
+	type choice struct {
+		Login string
+		URL   string
+	}
+	choices := make([]choice, len(s.fixtures.Users))
+	for i, u := range s.fixtures.Users {
+		choices[i] = choice{Login: u.Login, URL: callback(u.Login)}
+	}
+	if err := authorizeTemplate.Execute(w, choices); err != nil {
+		log.Printf("fakegithub: %s", err)
+	}
+}
+
+// handleAccessToken exchanges the code, which is the login of the user, or the refresh token for the token.
+func (s *Server) handleAccessToken(w http.ResponseWriter, req *http.Request) {
+	login := req.FormValue("code")
+	if req.FormValue("grant_type") == "refresh_token" {
+		refreshToken := req.FormValue("refresh_token")
+		if !strings.HasPrefix(refreshToken, refreshTokenPrefix) || s.fixtures.user(strings.TrimPrefix(refreshToken, refreshTokenPrefix)) == nil {
+			writeJSON(w, http.StatusOK, map[string]string{"error": "bad_refresh_token"})
+			return
+		}
+		login = strings.TrimPrefix(refreshToken, refreshTokenPrefix)
+	} else if s.fixtures.user(login) == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  tokenPrefix + login,
+		"refresh_token": refreshTokenPrefix + login,
+		"expires_in":    tokenExpiresIn,
+		"token_type":    "bearer",
+		"scope":         "repo",
+	})
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, req *http.Request) {
+	u := s.authUser(req)
+	if u == nil {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+
+	var body struct {
+		Query     string
+		Variables json.RawMessage
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Variables may be given as a JSON string as well as an object
+	var variables map[string]interface{}
+	if len(body.Variables) > 0 && body.Variables[0] == '"' {
+		var s string
+		if err := json.Unmarshal(body.Variables, &s); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		body.Variables = json.RawMessage(s)
+	}
+	if len(body.Variables) > 0 {
+		if err := json.Unmarshal(body.Variables, &variables); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	doc, err := parseGraphQL(body.Query)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errors": []gqlError{{Message: "Parse error: " + err.Error()}},
+		})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := &gqlExecutor{doc: doc, variables: variables}
+	data := map[string]interface{}{}
+	err = e.executeSelectionSet(schema{server: s, viewer: u, baseURL: baseURL(req)}.query(), doc.operation, data)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errors": []gqlError{{Message: err.Error()}},
+		})
+		return
+	}
+
+	result := map[string]interface{}{"data": data}
+	if len(e.errors) > 0 {
+		result["errors"] = e.errors
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, req *http.Request) {
+	u := s.authUser(req)
+	if u == nil {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"login":      u.Login,
+		"id":         u.ID,
+		"avatar_url": u.AvatarURL,
+		"type":       "User",
+	})
+}
+
+// repository returns the repository of the request readable by the user,
+// or responds 404 as GitHub does.
+func (s *Server) repository(w http.ResponseWriter, req *http.Request) *Repository {
+	vars := mux.Vars(req)
+	r := s.fixtures.repository(s.authUser(req), vars["owner"], vars["repo"])
+	if r == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+	return r
+}
+
+func (s *Server) handleGetBlob(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	sha := mux.Vars(req)["sha"]
+	content, ok := r.blob(sha)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sha":      sha,
+		"size":     len(content),
+		"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+		"encoding": "base64",
+	})
+}
+
+func (s *Server) handleListPullRequests(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	state := req.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := req.URL.Query().Get("head")
+
+	pullReqs := []map[string]interface{}{}
+	for _, pr := range r.PullRequests {
+		prState := pr.State
+		if prState == "merged" {
+			prState = "closed"
+		}
+		if state != "all" && state != prState {
+			continue
+		}
+		if head != "" && head != r.Owner+":"+pr.Head {
+			continue
+		}
+		pullReqs = append(pullReqs, map[string]interface{}{
+			"number": pr.Number,
+			"title":  pr.Title,
+			"state":  prState,
+			"head":   map[string]interface{}{"ref": pr.Head, "sha": pr.headOid()},
+			"base":   map[string]interface{}{"ref": pr.Base},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, pullReqs)
+}
+
+func (s *Server) handleCreateStatus(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	var status Status
+	if err := json.NewDecoder(req.Body).Decode(&status); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch status.State {
+	case "error", "failure", "pending", "success":
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	if status.Context == "" {
+		status.Context = "default"
+	}
+
+	s.mu.Lock()
+	key := r.nameWithOwner() + "@" + mux.Vars(req)["sha"]
+	s.statuses[key] = append(s.statuses[key], status)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// Statuses returns the statuses created on the commit, the latest first.
+func (s *Server) Statuses(owner, repo, sha string) []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := s.statuses[owner+"/"+repo+"@"+sha]
+	result := make([]Status, len(statuses))
+	for i, st := range statuses {
+		result[len(statuses)-1-i] = st
+	}
+	return result
+}
+
+func (s *Server) handleListStatuses(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.Statuses(r.Owner, r.Name, mux.Vars(req)["sha"]))
+}
+
+// combinedStatus computes the combined status of the commit from the latest status of each context,
+// or the one in the fixtures for the head commit of the pull request.
+// s.mu must be held.
+func (s *Server) combinedStatus(r *Repository, pr *PullRequest, sha string) string {
+	latest := map[string]string{}
+	for _, st := range s.statuses[r.nameWithOwner()+"@"+sha] {
+		latest[st.Context] = st.State
+	}
+	if len(latest) == 0 {
+		if sha == pr.headOid() {
+			return pr.CIStatus
+		}
+		return ""
+	}
+
+	combined := "success"
+	for _, state := range latest {
+		switch {
+		case state == "failure" || state == "error":
+			return "failure"
+		case state == "pending":
+			combined = "pending"
+		}
+	}
+	return combined
+}
+
+func (s *Server) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *Server) handleCreateComment(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	number, _ := strconv.Atoi(mux.Vars(req)["number"])
+	if r.pullRequest(number) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var comment Comment
+	if err := json.NewDecoder(req.Body).Decode(&comment); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	comment.Number = number
+	if u := s.authUser(req); u != nil {
+		comment.User = u.Login
+	}
+
+	s.mu.Lock()
+	comment.ID = s.nextID()
+	s.comments[r.nameWithOwner()] = append(s.comments[r.nameWithOwner()], &comment)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) handleEditComment(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	var in Comment
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.comments[r.nameWithOwner()] {
+		if c.ID == id {
+			c.Body = in.Body
+			writeJSON(w, http.StatusOK, c)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// Comments returns the comments created on the issue or pull request.
+func (s *Server) Comments(owner, repo string, number int) []Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comments := []Comment{}
+	for _, c := range s.comments[owner+"/"+repo] {
+		if c.Number == number {
+			comments = append(comments, *c)
+		}
+	}
+	return comments
+}
+
+func (s *Server) handleListComments(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	number, _ := strconv.Atoi(mux.Vars(req)["number"])
+	writeJSON(w, http.StatusOK, s.Comments(r.Owner, r.Name, number))
+}
+
+func (s *Server) handleCreateCheckRun(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	var run CheckRun
+	if err := json.NewDecoder(req.Body).Decode(&run); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	run.ID = s.nextID()
+	s.checkRuns[r.nameWithOwner()] = append(s.checkRuns[r.nameWithOwner()], &run)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+func (s *Server) handleListCheckRuns(w http.ResponseWriter, req *http.Request) {
+	r := s.repository(w, req)
+	if r == nil {
+		return
+	}
+
+	sha := mux.Vars(req)["sha"]
+
+	s.mu.Lock()
+	runs := []CheckRun{}
+	for _, run := range s.checkRuns[r.nameWithOwner()] {
+		if run.HeadSHA == sha {
+			runs = append(runs, *run)
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": len(runs),
+		"check_runs":  runs,
+	})
+}
@@ -0,0 +1,154 @@
+package fakegithub
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	fixtures, err := LoadFixtures("testdata")
+	require.NoError(t, err)
+	return httptest.NewServer(New(fixtures))
+}
+
+func queryGraphQL(t *testing.T, s *httptest.Server, token, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("POST", s.URL+"/api/graphql", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
+}
+
+func TestServer_graphQL(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	const query = `
+query($owner: String!, $after: String) {
+  repository(owner: $owner, name: "test-repository") {
+    release: pullRequest(number: 2) {
+      commits(first: 1, after: $after) {
+        nodes { commit { message } }
+        pageInfo { hasNextPage endCursor }
+      }
+      headRef @include(if: false) { name }
+    }
+    ... on Repository @skip(if: true) { isPrivate }
+    ...names
+  }
+}
+fragment names on Repository { nameWithOwner }
+`
+
+	status, result := queryGraphQL(t, s, "fake:tester", query, map[string]interface{}{"owner": "motemen"})
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, result["errors"])
+
+	repo := result["data"].(map[string]interface{})["repository"].(map[string]interface{})
+	assert.Equal(t, "motemen/test-repository", repo["nameWithOwner"])
+	assert.NotContains(t, repo, "isPrivate")
+
+	release := repo["release"].(map[string]interface{})
+	assert.NotContains(t, release, "headRef")
+
+	commits := release["commits"].(map[string]interface{})
+	assert.Equal(t, 1, len(commits["nodes"].([]interface{})))
+	pageInfo := commits["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+
+	_, result = queryGraphQL(t, s, "fake:tester", query, map[string]interface{}{"owner": "motemen", "after": pageInfo["endCursor"]})
+	commits = result["data"].(map[string]interface{})["repository"].(map[string]interface{})["release"].(map[string]interface{})["commits"].(map[string]interface{})
+	assert.Contains(t, commits["nodes"].([]interface{})[0].(map[string]interface{})["commit"].(map[string]interface{})["message"], "#3")
+	assert.Equal(t, false, commits["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestServer_private(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	const query = `{ repository(owner: "motemen", name: "private-repository") { isPrivate } }`
+
+	_, result := queryGraphQL(t, s, "fake:motemen", query, nil)
+	assert.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{"isPrivate": true}, result["data"].(map[string]interface{})["repository"])
+
+	_, result = queryGraphQL(t, s, "fake:tester", query, nil)
+	assert.Nil(t, result["data"].(map[string]interface{})["repository"])
+	assert.Equal(t, "NOT_FOUND", result["errors"].([]interface{})[0].(map[string]interface{})["type"])
+
+	status, _ := queryGraphQL(t, s, "invalid", query, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestServer_authorize(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	// Users to log in as are listed
+	resp, err := http.Get(s.URL + "/login/oauth/authorize?client_id=x&state=S&redirect_uri=http%3A%2F%2Fprchecklist.test%2Fauth%2Fcallback")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `<a href="http://prchecklist.test/auth/callback?code=tester&amp;state=S">tester</a>`)
+
+	resp, err = http.PostForm(s.URL+"/login/oauth/access_token", map[string][]string{"code": {"tester"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var token map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	assert.Equal(t, "fake:tester", token["access_token"])
+	assert.Equal(t, "fake-refresh:tester", token["refresh_token"])
+
+	resp, err = http.PostForm(s.URL+"/login/oauth/access_token", map[string][]string{"grant_type": {"refresh_token"}, "refresh_token": {"fake-refresh:tester"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	token = nil
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	assert.Equal(t, "fake:tester", token["access_token"])
+
+	resp, err = http.PostForm(s.URL+"/login/oauth/access_token", map[string][]string{"grant_type": {"refresh_token"}, "refresh_token": {"fake-refresh:nobody"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	token = nil
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	assert.Equal(t, "bad_refresh_token", token["error"])
+}
+
+func TestParseGraphQL(t *testing.T) {
+	for _, query := range []string{
+		`{ a(x: [1, "2", {y: $z}], w: ENUM) { b } }`,
+		`query Named { a @skip(if: $x) }`,
+		`# comment
+		query($a: [Int!]! = [1]) { ...f } fragment f on T { b }`,
+	} {
+		_, err := parseGraphQL(query)
+		assert.NoError(t, err, query)
+	}
+
+	for _, query := range []string{
+		`{ a `,
+		`{ a(x: ) }`,
+		`{ a } { b }`,
+		`fragment f on T { a }`,
+	} {
+		_, err := parseGraphQL(query)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,232 @@
+package fakegithub
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Fixtures are the data served by the fake GitHub.
+type Fixtures struct {
+	// Users can log in by OAuth, chosen on the authorization page if more than one.
+	Users        []*User       `yaml:"users"`
+	Repositories []*Repository `yaml:"repositories"`
+}
+
+// User is a GitHub user.
+type User struct {
+	Login     string `yaml:"login"`
+	ID        int    `yaml:"id"`
+	AvatarURL string `yaml:"avatar_url"`
+}
+
+// Repository is a GitHub repository.
+type Repository struct {
+	Owner         string `yaml:"owner"`
+	Name          string `yaml:"name"`
+	Private       bool   `yaml:"private"`
+	DefaultBranch string `yaml:"default_branch"`
+	// Collaborators are the logins of the users who can read the private repository.
+	Collaborators []string       `yaml:"collaborators"`
+	PullRequests  []*PullRequest `yaml:"pull_requests"`
+}
+
+// PullRequest is a pull request of a Repository.
+type PullRequest struct {
+	Number    int      `yaml:"number"`
+	Title     string   `yaml:"title"`
+	Body      string   `yaml:"body"`
+	Author    string   `yaml:"author"`
+	Assignees []string `yaml:"assignees"`
+	Base      string   `yaml:"base"`
+	Head      string   `yaml:"head"`
+	// State is one of "open", "closed" and "merged", "open" by default.
+	State   string    `yaml:"state"`
+	Commits []*Commit `yaml:"commits"`
+	// Files are the contents of the files at the head, such as prchecklist.yml, by their names.
+	Files map[string]string `yaml:"files"`
+
+	Labels             []string `yaml:"labels"`
+	MergedAt           string   `yaml:"merged_at"`
+	MergedBy           string   `yaml:"merged_by"`
+	RequestedReviewers []string `yaml:"requested_reviewers"`
+	ApprovedBy         []string `yaml:"approved_by"`
+	ChangedFiles       int      `yaml:"changed_files"`
+	Additions          int      `yaml:"additions"`
+	Deletions          int      `yaml:"deletions"`
+	// CIStatus is the combined status of the head commit, such as "success" and "failure".
+	CIStatus string `yaml:"ci_status"`
+}
+
+// Commit is a commit of a PullRequest.
+type Commit struct {
+	// Oid is derived from the message if empty.
+	Oid     string `yaml:"oid"`
+	Message string `yaml:"message"`
+}
+
+// LoadFixtures reads all the YAML files in dir and merges them into Fixtures.
+func LoadFixtures(dir string) (*Fixtures, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no fixtures found in %s", dir)
+	}
+
+	fixtures := &Fixtures{}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var f Fixtures
+		if err := yaml.UnmarshalStrict(b, &f); err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+
+		fixtures.Users = append(fixtures.Users, f.Users...)
+		fixtures.Repositories = append(fixtures.Repositories, f.Repositories...)
+	}
+
+	if err := fixtures.normalize(); err != nil {
+		return nil, err
+	}
+
+	return fixtures, nil
+}
+
+// normalize validates the fixtures and fills the default values.
+func (f *Fixtures) normalize() error {
+	if len(f.Users) == 0 {
+		return errors.New("at least one user is required")
+	}
+	for i, u := range f.Users {
+		if u.Login == "" {
+			return errors.Errorf("users[%d]: login is required", i)
+		}
+		if u.ID == 0 {
+			u.ID = i + 1
+		}
+	}
+
+	for _, r := range f.Repositories {
+		if r.Owner == "" || r.Name == "" {
+			return errors.New("repositories: both owner and name are required")
+		}
+		if r.DefaultBranch == "" {
+			r.DefaultBranch = "master"
+		}
+
+		for _, pr := range r.PullRequests {
+			if pr.Number == 0 {
+				return errors.Errorf("%s/%s: number of pull request is required", r.Owner, r.Name)
+			}
+			if pr.State == "" {
+				pr.State = "open"
+			}
+			if pr.Base == "" {
+				pr.Base = r.DefaultBranch
+			}
+			if pr.Head == "" {
+				pr.Head = fmt.Sprintf("branch-%d", pr.Number)
+			}
+			for i, c := range pr.Commits {
+				if c.Oid == "" {
+					c.Oid = fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%s/%s#%d\000%d\000%s", r.Owner, r.Name, pr.Number, i, c.Message))))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *Fixtures) user(login string) *User {
+	for _, u := range f.Users {
+		if u.Login == login {
+			return u
+		}
+	}
+	return nil
+}
+
+// repository returns the repository which u can read, or nil.
+func (f *Fixtures) repository(u *User, owner, name string) *Repository {
+	for _, r := range f.Repositories {
+		if strings.EqualFold(r.Owner, owner) && strings.EqualFold(r.Name, name) && r.readableBy(u) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *Repository) nameWithOwner() string {
+	return r.Owner + "/" + r.Name
+}
+
+func (r *Repository) readableBy(u *User) bool {
+	if !r.Private {
+		return true
+	}
+	if u == nil {
+		return false
+	}
+	if u.Login == r.Owner {
+		return true
+	}
+	for _, login := range r.Collaborators {
+		if login == u.Login {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Repository) pullRequest(number int) *PullRequest {
+	for _, pr := range r.PullRequests {
+		if pr.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// blob returns the content of the file whose blob SHA is sha in any pull request.
+func (r *Repository) blob(sha string) (string, bool) {
+	for _, pr := range r.PullRequests {
+		for _, content := range pr.Files {
+			if blobSHA(content) == sha {
+				return content, true
+			}
+		}
+	}
+	return "", false
+}
+
+// blobSHA computes the SHA of content as a Git blob.
+func blobSHA(content string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("blob %d\000%s", len(content), content))))
+}
+
+// headOid is the SHA of the head commit of the pull request.
+func (pr *PullRequest) headOid() string {
+	if len(pr.Commits) == 0 {
+		return ""
+	}
+	return pr.Commits[len(pr.Commits)-1].Oid
+}
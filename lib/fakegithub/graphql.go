@@ -0,0 +1,490 @@
+package fakegithub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the subset of GraphQL which the queries of lib/gateway use:
+// operations with variables, fields with aliases and arguments, inline fragments,
+// named fragments and the @skip/@include directives. Types are not checked.
+
+type gqlDocument struct {
+	operation gqlSelectionSet
+	fragments map[string]gqlSelectionSet
+}
+
+type gqlSelectionSet []gqlSelection
+
+type gqlSelection struct {
+	// field is set for fields
+	alias string
+	field string
+	args  map[string]interface{}
+	// fragment is set for fragment spreads, typeCondition for inline fragments
+	fragment      string
+	typeCondition string
+
+	directives   map[string]map[string]interface{}
+	selectionSet gqlSelectionSet
+}
+
+// gqlVariable is a reference to a variable in argument values.
+type gqlVariable string
+
+// gqlEnum is an enum value in argument values.
+type gqlEnum string
+
+type gqlParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseGraphQL(query string) (*gqlDocument, error) {
+	tokens, err := tokenizeGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: map[string]gqlSelectionSet{}}
+
+	for p.peek() != "" {
+		switch p.peek() {
+		case "{":
+			if doc.operation != nil {
+				return nil, errors.New("multiple operations")
+			}
+			doc.operation, err = p.parseSelectionSet()
+
+		case "query":
+			p.next()
+			if p.peek() != "(" && p.peek() != "{" {
+				p.next() // operation name
+			}
+			if p.peek() == "(" {
+				err = p.skipVariableDefinitions()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if doc.operation != nil {
+				return nil, errors.New("multiple operations")
+			}
+			doc.operation, err = p.parseSelectionSet()
+
+		case "fragment":
+			p.next()
+			name := p.next()
+			if err := p.expect("on"); err != nil {
+				return nil, err
+			}
+			p.next() // type condition
+			doc.fragments[name], err = p.parseSelectionSet()
+
+		default:
+			return nil, errors.Errorf("unexpected %q", p.peek())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if doc.operation == nil {
+		return nil, errors.New("no operation")
+	}
+
+	return doc, nil
+}
+
+func tokenizeGraphQL(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(s[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+
+		case strings.IndexByte("{}():$!@[]=", c) != -1:
+			tokens = append(tokens, string(c))
+			i++
+
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+
+		case c == '-' || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+
+		default:
+			return nil, errors.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *gqlParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *gqlParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return errors.Errorf("expected %q but got %q", t, got)
+	}
+	return nil
+}
+
+func (p *gqlParser) skipVariableDefinitions() error {
+	depth := 0
+	for {
+		switch p.next() {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		case "":
+			return errors.New("unterminated variable definitions")
+		}
+	}
+}
+
+func (p *gqlParser) parseSelectionSet() (gqlSelectionSet, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var set gqlSelectionSet
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, errors.New("unterminated selection set")
+		}
+
+		var sel gqlSelection
+		var err error
+
+		if p.peek() == "..." {
+			p.next()
+			if p.peek() == "on" {
+				p.next()
+				sel.typeCondition = p.next()
+			} else if p.peek() != "@" && p.peek() != "{" {
+				sel.fragment = p.next()
+			}
+		} else {
+			sel.field = p.next()
+			if p.peek() == ":" {
+				p.next()
+				sel.alias = sel.field
+				sel.field = p.next()
+			}
+			if p.peek() == "(" {
+				sel.args, err = p.parseArguments()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for p.peek() == "@" {
+			p.next()
+			name := p.next()
+			var args map[string]interface{}
+			if p.peek() == "(" {
+				args, err = p.parseArguments()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if sel.directives == nil {
+				sel.directives = map[string]map[string]interface{}{}
+			}
+			sel.directives[name] = args
+		}
+
+		if p.peek() == "{" {
+			sel.selectionSet, err = p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+		} else if sel.field == "" && sel.fragment == "" {
+			return nil, errors.New("inline fragment without selections")
+		}
+
+		set = append(set, sel)
+	}
+	p.next()
+
+	return set, nil
+}
+
+func (p *gqlParser) parseArguments() (map[string]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{}
+	for p.peek() != ")" {
+		name := p.next()
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	p.next()
+
+	return args, nil
+}
+
+func (p *gqlParser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t == "$":
+		return gqlVariable(p.next()), nil
+
+	case t == "[":
+		list := []interface{}{}
+		for p.peek() != "]" {
+			if p.peek() == "" {
+				return nil, errors.New("unterminated list")
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.next()
+		return list, nil
+
+	case t == "{":
+		obj := map[string]interface{}{}
+		for p.peek() != "}" {
+			name := p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = v
+		}
+		p.next()
+		return obj, nil
+
+	case strings.HasPrefix(t, `"`):
+		return strconv.Unquote(t)
+
+	case t == "true" || t == "false":
+		return t == "true", nil
+
+	case t == "null":
+		return nil, nil
+
+	case t != "" && (t[0] == '-' || unicode.IsDigit(rune(t[0]))):
+		if n, err := strconv.Atoi(t); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(t, 64)
+
+	case t == "" || strings.IndexAny(t, "{}():$!@[]=") != -1:
+		return nil, errors.Errorf("unexpected %q", t)
+
+	default:
+		return gqlEnum(t), nil
+	}
+}
+
+// gqlObject is an object of the schema, which resolves its fields.
+type gqlObject interface {
+	typename() string
+	resolve(field string, args map[string]interface{}) (interface{}, error)
+}
+
+// notFoundError makes the field null with an error of type NOT_FOUND
+// instead of failing the whole query.
+type notFoundError struct {
+	what string
+}
+
+func (e *notFoundError) Error() string {
+	return "Could not resolve to a " + e.what
+}
+
+type gqlError struct {
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message"`
+}
+
+// gqlExecutor executes the operation of a document.
+type gqlExecutor struct {
+	doc       *gqlDocument
+	variables map[string]interface{}
+	errors    []gqlError
+}
+
+// resolveValue replaces variables in v with their values.
+func (e *gqlExecutor) resolveValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case gqlVariable:
+		return e.variables[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, x := range v {
+			list[i] = e.resolveValue(x)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			obj[k] = e.resolveValue(x)
+		}
+		return obj
+	}
+	return v
+}
+
+func (e *gqlExecutor) included(sel gqlSelection) bool {
+	if args, ok := sel.directives["skip"]; ok && e.resolveValue(args["if"]) == true {
+		return false
+	}
+	if args, ok := sel.directives["include"]; ok && e.resolveValue(args["if"]) != true {
+		return false
+	}
+	return true
+}
+
+func (e *gqlExecutor) executeSelectionSet(obj gqlObject, set gqlSelectionSet, result map[string]interface{}) error {
+	for _, sel := range set {
+		if !e.included(sel) {
+			continue
+		}
+
+		switch {
+		case sel.fragment != "":
+			fragment, ok := e.doc.fragments[sel.fragment]
+			if !ok {
+				return errors.Errorf("unknown fragment %q", sel.fragment)
+			}
+			if err := e.executeSelectionSet(obj, fragment, result); err != nil {
+				return err
+			}
+
+		case sel.field == "":
+			if sel.typeCondition != "" && sel.typeCondition != obj.typename() {
+				continue
+			}
+			if err := e.executeSelectionSet(obj, sel.selectionSet, result); err != nil {
+				return err
+			}
+
+		default:
+			key := sel.alias
+			if key == "" {
+				key = sel.field
+			}
+
+			if sel.field == "__typename" {
+				result[key] = obj.typename()
+				continue
+			}
+
+			args := make(map[string]interface{}, len(sel.args))
+			for k, v := range sel.args {
+				args[k] = e.resolveValue(v)
+			}
+
+			v, err := obj.resolve(sel.field, args)
+			if nf, ok := err.(*notFoundError); ok {
+				e.errors = append(e.errors, gqlError{Type: "NOT_FOUND", Message: nf.Error()})
+				result[key] = nil
+				continue
+			}
+			if err != nil {
+				return errors.Wrap(err, key)
+			}
+
+			result[key], err = e.complete(v, sel.selectionSet)
+			if err != nil {
+				return errors.Wrap(err, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// complete converts the resolved value v into the JSON representation.
+func (e *gqlExecutor) complete(v interface{}, set gqlSelectionSet) (interface{}, error) {
+	switch v := v.(type) {
+	case gqlObject:
+		if set == nil {
+			return nil, errors.Errorf("selections of %s required", v.typename())
+		}
+		result := map[string]interface{}{}
+		err := e.executeSelectionSet(v, set, result)
+		return result, err
+
+	case []gqlObject:
+		list := make([]interface{}, len(v))
+		for i, x := range v {
+			var err error
+			list[i], err = e.complete(x, set)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+
+	case nil, string, int, bool:
+		if set != nil && v != nil {
+			return nil, fmt.Errorf("selections on scalar")
+		}
+		return v, nil
+	}
+
+	return nil, errors.Errorf("unexpected value of %T", v)
+}
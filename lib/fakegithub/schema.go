@@ -0,0 +1,372 @@
+package fakegithub
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// object is an object of the schema whose fields are values,
+// or funcs of arguments for the fields which take ones.
+type object struct {
+	name   string
+	fields map[string]interface{}
+}
+
+type fieldFunc func(args map[string]interface{}) (interface{}, error)
+
+func (o object) typename() string {
+	return o.name
+}
+
+func (o object) resolve(field string, args map[string]interface{}) (interface{}, error) {
+	v, ok := o.fields[field]
+	if !ok {
+		return nil, errors.Errorf("field %q not found on %s", field, o.name)
+	}
+	if f, ok := v.(fieldFunc); ok {
+		return f(args)
+	}
+	return v, nil
+}
+
+// intArg returns the integer argument, which is a float64 if given by a JSON variable.
+func intArg(args map[string]interface{}, name string) (int, bool) {
+	switch v := args[name].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// connection paginates nodes by first/last and after, whose cursors are the indices of nodes.
+func connection(nodes []gqlObject, args map[string]interface{}) (interface{}, error) {
+	start, end := 0, len(nodes)
+	if after := stringArg(args, "after"); after != "" {
+		i, err := strconv.Atoi(after)
+		if err != nil {
+			return nil, errors.Errorf("invalid cursor: %q", after)
+		}
+		start = i + 1
+		if start > end {
+			start = end
+		}
+	}
+	if first, ok := intArg(args, "first"); ok && start+first < end {
+		end = start + first
+	}
+	if last, ok := intArg(args, "last"); ok && end-last > start {
+		start = end - last
+	}
+
+	edges := make([]gqlObject, 0, end-start)
+	for i := start; i < end; i++ {
+		edges = append(edges, object{name: "Edge", fields: map[string]interface{}{
+			"node":   nodes[i],
+			"cursor": strconv.Itoa(i),
+		}})
+	}
+
+	var startCursor, endCursor interface{}
+	if end > start {
+		startCursor, endCursor = strconv.Itoa(start), strconv.Itoa(end-1)
+	}
+
+	return object{name: "Connection", fields: map[string]interface{}{
+		"edges":      edges,
+		"nodes":      nodes[start:end],
+		"totalCount": len(nodes),
+		"pageInfo": object{name: "PageInfo", fields: map[string]interface{}{
+			"hasNextPage":     end < len(nodes),
+			"hasPreviousPage": start > 0,
+			"startCursor":     startCursor,
+			"endCursor":       endCursor,
+		}},
+	}}, nil
+}
+
+// schema builds the objects of the fixtures seen by the viewer.
+type schema struct {
+	server  *Server
+	viewer  *User
+	baseURL string
+}
+
+func (s schema) query() gqlObject {
+	return object{name: "Query", fields: map[string]interface{}{
+		"rateLimit": object{name: "RateLimit", fields: map[string]interface{}{
+			"cost":      1,
+			"limit":     5000,
+			"remaining": 4999,
+			"resetAt":   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		}},
+		"viewer": s.user(s.viewer.Login),
+		"repository": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			r := s.server.fixtures.repository(s.viewer, stringArg(args, "owner"), stringArg(args, "name"))
+			if r == nil {
+				return nil, &notFoundError{"repository"}
+			}
+			return s.repository(r), nil
+		}),
+		"search": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(s.search(stringArg(args, "query")), args)
+		}),
+	}}
+}
+
+func (s schema) user(login string) gqlObject {
+	if login == "" {
+		return nil
+	}
+
+	fields := map[string]interface{}{
+		"login": login,
+	}
+	if u := s.server.fixtures.user(login); u != nil {
+		fields["databaseId"] = u.ID
+		fields["avatarUrl"] = u.AvatarURL
+	}
+	if login == s.viewer.Login {
+		fields["repositories"] = fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			var repos []gqlObject
+			for _, r := range s.server.fixtures.Repositories {
+				if r.readableBy(s.viewer) {
+					repos = append(repos, s.repository(r))
+				}
+			}
+			return connection(repos, args)
+		})
+	}
+
+	return object{name: "User", fields: fields}
+}
+
+func (s schema) users(logins []string) []gqlObject {
+	users := make([]gqlObject, len(logins))
+	for i, login := range logins {
+		users[i] = s.user(login)
+	}
+	return users
+}
+
+func (s schema) repository(r *Repository) gqlObject {
+	return object{name: "Repository", fields: map[string]interface{}{
+		"name":          r.Name,
+		"nameWithOwner": r.nameWithOwner(),
+		"owner":         s.user(r.Owner),
+		"isPrivate":     r.Private,
+		"url":           s.baseURL + "/" + r.nameWithOwner(),
+		"defaultBranchRef": object{name: "Ref", fields: map[string]interface{}{
+			"name": r.DefaultBranch,
+		}},
+		"pullRequest": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			number, _ := intArg(args, "number")
+			pr := r.pullRequest(number)
+			if pr == nil {
+				return nil, &notFoundError{"PullRequest"}
+			}
+			return s.pullRequest(r, pr), nil
+		}),
+		"pullRequests": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			states, _ := args["states"].([]interface{})
+
+			prs := make([]*PullRequest, 0, len(r.PullRequests))
+			for _, pr := range r.PullRequests {
+				if len(states) == 0 || containsEnum(states, strings.ToUpper(pr.State)) {
+					prs = append(prs, pr)
+				}
+			}
+			// Newer ones first in place of UPDATED_AT
+			sort.SliceStable(prs, func(i, j int) bool { return prs[i].Number > prs[j].Number })
+
+			nodes := make([]gqlObject, len(prs))
+			for i, pr := range prs {
+				nodes[i] = s.pullRequest(r, pr)
+			}
+			return connection(nodes, args)
+		}),
+	}}
+}
+
+func containsEnum(list []interface{}, v string) bool {
+	for _, x := range list {
+		if x == gqlEnum(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s schema) pullRequest(r *Repository, pr *PullRequest) gqlObject {
+	commits := make([]gqlObject, len(pr.Commits))
+	for i, c := range pr.Commits {
+		commits[i] = object{name: "PullRequestCommit", fields: map[string]interface{}{
+			"commit": s.commit(r, pr, c),
+		}}
+	}
+
+	labels := make([]gqlObject, len(pr.Labels))
+	for i, name := range pr.Labels {
+		labels[i] = object{name: "Label", fields: map[string]interface{}{"name": name}}
+	}
+
+	reviewRequests := make([]gqlObject, len(pr.RequestedReviewers))
+	for i, login := range pr.RequestedReviewers {
+		reviewRequests[i] = object{name: "ReviewRequest", fields: map[string]interface{}{
+			"requestedReviewer": s.user(login),
+		}}
+	}
+
+	reviews := make([]gqlObject, len(pr.ApprovedBy))
+	for i, login := range pr.ApprovedBy {
+		reviews[i] = object{name: "PullRequestReview", fields: map[string]interface{}{
+			"author": s.user(login),
+			"state":  "APPROVED",
+		}}
+	}
+
+	var mergedAt interface{}
+	if pr.MergedAt != "" {
+		mergedAt = pr.MergedAt
+	}
+
+	var headTarget gqlObject
+	if len(pr.Commits) > 0 {
+		headTarget = s.commit(r, pr, pr.Commits[len(pr.Commits)-1])
+	}
+
+	return object{name: "PullRequest", fields: map[string]interface{}{
+		"title":       pr.Title,
+		"number":      pr.Number,
+		"body":        pr.Body,
+		"url":         s.baseURL + "/" + r.nameWithOwner() + "/pull/" + strconv.Itoa(pr.Number),
+		"state":       strings.ToUpper(pr.State),
+		"merged":      pr.State == "merged",
+		"author":      s.user(pr.Author),
+		"repository":  s.repository(r),
+		"baseRefName": pr.Base,
+		"headRefName": pr.Head,
+		"baseRef":     object{name: "Ref", fields: map[string]interface{}{"name": pr.Base}},
+		"headRef": object{name: "Ref", fields: map[string]interface{}{
+			"name":   pr.Head,
+			"target": headTarget,
+		}},
+		"assignees": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(s.users(pr.Assignees), args)
+		}),
+		"commits": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(commits, args)
+		}),
+		"labels": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(labels, args)
+		}),
+		"reviewRequests": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(reviewRequests, args)
+		}),
+		"reviews": fieldFunc(func(args map[string]interface{}) (interface{}, error) {
+			return connection(reviews, args)
+		}),
+		"mergedAt":     mergedAt,
+		"mergedBy":     s.user(pr.MergedBy),
+		"changedFiles": pr.ChangedFiles,
+		"additions":    pr.Additions,
+		"deletions":    pr.Deletions,
+	}}
+}
+
+func (s schema) commit(r *Repository, pr *PullRequest, c *Commit) gqlObject {
+	fields := map[string]interface{}{
+		"oid":               c.Oid,
+		"message":           c.Message,
+		"status":            nil,
+		"statusCheckRollup": nil,
+	}
+
+	if state := s.server.combinedStatus(r, pr, c.Oid); state != "" {
+		fields["status"] = object{name: "Status", fields: map[string]interface{}{
+			"state": strings.ToUpper(state),
+		}}
+		fields["statusCheckRollup"] = object{name: "StatusCheckRollup", fields: map[string]interface{}{
+			"state": strings.ToUpper(state),
+		}}
+	}
+
+	if c.Oid == pr.headOid() {
+		names := make([]string, 0, len(pr.Files))
+		for name := range pr.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		entries := make([]gqlObject, len(names))
+		for i, name := range names {
+			entries[i] = object{name: "TreeEntry", fields: map[string]interface{}{
+				"name": name,
+				"oid":  blobSHA(pr.Files[name]),
+				"type": "blob",
+			}}
+		}
+		fields["tree"] = object{name: "Tree", fields: map[string]interface{}{
+			"entries": entries,
+		}}
+	} else {
+		fields["tree"] = object{name: "Tree", fields: map[string]interface{}{
+			"entries": []gqlObject{},
+		}}
+	}
+
+	return object{name: "Commit", fields: fields}
+}
+
+// search finds the pull requests whose titles contain all the words of query.
+// Qualifiers other than repo: and is:open are ignored.
+func (s schema) search(query string) []gqlObject {
+	var (
+		words  []string
+		repo   string
+		isOpen bool
+	)
+	for _, w := range strings.Fields(query) {
+		switch {
+		case strings.HasPrefix(w, "repo:"):
+			repo = strings.TrimPrefix(w, "repo:")
+		case w == "is:open":
+			isOpen = true
+		case strings.Contains(w, ":"):
+		default:
+			words = append(words, strings.ToLower(w))
+		}
+	}
+
+	var nodes []gqlObject
+	for _, r := range s.server.fixtures.Repositories {
+		if !r.readableBy(s.viewer) || (repo != "" && !strings.EqualFold(repo, r.nameWithOwner())) {
+			continue
+		}
+
+	pullRequests:
+		for _, pr := range r.PullRequests {
+			if isOpen && pr.State != "open" {
+				continue
+			}
+			for _, w := range words {
+				if !strings.Contains(strings.ToLower(pr.Title), w) {
+					continue pullRequests
+				}
+			}
+			nodes = append(nodes, s.pullRequest(r, pr))
+		}
+	}
+
+	return nodes
+}
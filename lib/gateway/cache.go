@@ -0,0 +1,170 @@
+package gateway
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+)
+
+// Cache stores data retrieved from GitHub, possibly shared among processes.
+// A ttl of 0 means the entry never expires.
+type Cache interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// NewCache creates a Cache by spec, which is either "memory"
+// or a URL of form "redis://[<user>:<password>@]<hostname>".
+func NewCache(spec string) (Cache, error) {
+	if spec == "" || spec == "memory" {
+		return newMemoryCache(), nil
+	}
+
+	if strings.HasPrefix(spec, "redis:") {
+		return newRedisCache(spec)
+	}
+
+	return nil, errors.Errorf("unknown cache: %q", spec)
+}
+
+// memoryCache is a Cache in the process.
+type memoryCache struct {
+	cache *cache.Cache
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{cache: cache.New(30*time.Second, 10*time.Minute)}
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool, error) {
+	if v, ok := c.cache.Get(key); ok {
+		if b, ok := v.([]byte); ok {
+			return b, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (c *memoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = cache.NoExpiration
+	}
+	c.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (c *memoryCache) Delete(key string) error {
+	c.cache.Delete(key)
+	return nil
+}
+
+// redisCacheKeyPrefix separates the cache from other data in the Redis database.
+const redisCacheKeyPrefix = "githubCache:"
+
+// redisCache is a Cache on Redis, shared among processes.
+type redisCache struct {
+	pool *redis.Pool
+}
+
+func newRedisCache(datasource string) (*redisCache, error) {
+	u, err := url.Parse(datasource)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []redis.DialOption{}
+	if u.User != nil {
+		if pw, ok := u.User.Password(); ok {
+			opts = append(opts, redis.DialPassword(pw))
+		}
+	}
+
+	return &redisCache{
+		pool: redis.NewPool(func() (redis.Conn, error) {
+			return redis.Dial("tcp", u.Host, opts...)
+		}, 8),
+	}, nil
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", redisCacheKeyPrefix+key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "redisCache.Get")
+	}
+	return b, true, nil
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	args := []interface{}{redisCacheKeyPrefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", int64(ttl/time.Millisecond))
+	}
+	_, err := conn.Do("SET", args...)
+	return errors.Wrap(err, "redisCache.Set")
+}
+
+func (c *redisCache) Delete(key string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", redisCacheKeyPrefix+key)
+	return errors.Wrap(err, "redisCache.Delete")
+}
+
+// prefixedCache separates its keys from others in the underlying Cache by prefix.
+type prefixedCache struct {
+	Cache
+	prefix string
+}
+
+func (c prefixedCache) Get(key string) ([]byte, bool, error) {
+	return c.Cache.Get(c.prefix + key)
+}
+
+func (c prefixedCache) Set(key string, value []byte, ttl time.Duration) error {
+	return c.Cache.Set(c.prefix+key, value, ttl)
+}
+
+func (c prefixedCache) Delete(key string) error {
+	return c.Cache.Delete(c.prefix + key)
+}
+
+// getCacheJSON retrieves the value of key in c into v, reporting whether it was found.
+// Broken entries are regarded as not found.
+func getCacheJSON(c Cache, key string, v interface{}) bool {
+	b, ok, err := c.Get(key)
+	if err != nil {
+		log.Printf("cache: %s", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(b, v) == nil
+}
+
+// setCacheJSON stores v to c serialized as JSON.
+func setCacheJSON(c Cache, key string, v interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.Set(key, b, ttl)
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/fakegithub"
+)
+
+func testCache(t *testing.T, c Cache) {
+	key := "test\000" + time.Now().Format(time.RFC3339Nano)
+
+	_, ok, err := c.Get(key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(key, []byte("value"), time.Minute))
+	b, ok, err := c.Get(key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), b)
+
+	require.NoError(t, c.Delete(key))
+	_, ok, err = c.Get(key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(key, []byte("forever"), 0))
+	b, ok, err = c.Get(key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("forever"), b)
+	require.NoError(t, c.Delete(key))
+}
+
+func TestMemoryCache(t *testing.T) {
+	c, err := NewCache("memory")
+	require.NoError(t, err)
+
+	testCache(t, c)
+}
+
+func TestRedisCache(t *testing.T) {
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if !strings.HasPrefix(redisURL, "redis:") {
+		log.Println("to test lib/gateway/cache.go with Redis, set TEST_REDIS_URL")
+		t.SkipNow()
+		return
+	}
+
+	c, err := NewCache(redisURL)
+	require.NoError(t, err)
+
+	testCache(t, c)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("no network")
+}
+
+func TestGitHub_GetPullRequest_cachedPrivate(t *testing.T) {
+	g := githubGateway{cache: newMemoryCache(), domain: "github.com"}
+
+	ref := prchecklist.ChecklistRef{Owner: "owner", Repo: "repo", Number: 2}
+	require.NoError(t, setCacheJSON(g.cache, pullRequestCacheKey("owner", "repo", 2, false), &prchecklist.PullRequest{
+		Owner:     "owner",
+		Repo:      "repo",
+		Number:    2,
+		Title:     "Feature",
+		IsPrivate: true,
+	}, time.Minute))
+
+	ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: failingTransport{}})
+
+	// Without the access right, GitHub is asked
+	_, _, err := g.GetPullRequest(ctx, ref, false)
+	assert.Error(t, err)
+
+	pullReq, _, err := g.GetPullRequest(contextWithRepoAccessRight(ctx, ref), ref, false)
+	require.NoError(t, err)
+	assert.Equal(t, "Feature", pullReq.Title)
+
+	g.InvalidatePullRequest(context.Background(), "owner", "repo", 2)
+	_, _, err = g.GetPullRequest(contextWithRepoAccessRight(ctx, ref), ref, false)
+	assert.Error(t, err)
+}
+
+func TestGitHub_GetPullRequest_repoAccess(t *testing.T) {
+	fixtures, err := fakegithub.LoadFixtures("../fakegithub/testdata")
+	require.NoError(t, err)
+	s := httptest.NewServer(fakegithub.New(fixtures))
+	defer s.Close()
+
+	g := githubGateway{
+		cache:      newMemoryCache(),
+		rateLimits: newRateLimits(),
+		domain:     "github.com",
+		apiURL:     s.URL + "/api/v3/",
+		graphqlURL: s.URL + "/api/graphql",
+	}
+
+	userContext := func(login string, id int) context.Context {
+		ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake:" + login})))
+		return prchecklist.ContextWithUserID(ctx, id)
+	}
+	offlineContext := func(id int) context.Context {
+		ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: failingTransport{}})
+		return prchecklist.ContextWithUserID(ctx, id)
+	}
+
+	ref := prchecklist.ChecklistRef{Owner: "motemen", Repo: "private-repository", Number: 1}
+
+	// Not a collaborator
+	_, _, err = g.GetPullRequest(userContext("tester", 1001), ref, true)
+	assert.Error(t, err)
+
+	pullReq, _, err := g.GetPullRequest(userContext("motemen", 8465), ref, true)
+	require.NoError(t, err)
+	assert.True(t, pullReq.IsPrivate)
+
+	// The release pull request is cached for the confirmed user only
+	cached, _, err := g.GetPullRequest(offlineContext(8465), ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, pullReq, cached)
+
+	_, _, err = g.GetPullRequest(offlineContext(1001), ref, true)
+	assert.Error(t, err)
+
+	_, _, err = g.GetPullRequest(context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: failingTransport{}}), ref, true)
+	assert.Error(t, err, "service clients are not trusted by the cache")
+
+	// The access expires
+	require.NoError(t, g.cache.Delete(repoAccessCacheKey(8465, "motemen", "private-repository")))
+	_, _, err = g.GetPullRequest(offlineContext(8465), ref, true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,360 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// cassetteScrubbed replaces secrets in cassettes.
+const cassetteScrubbed = "[scrubbed]"
+
+// cassetteExpiresAt replaces the expiration times of tokens in cassettes,
+// which are in the far future so that the tokens replayed do not expire.
+const cassetteExpiresAt = "2099-01-01T00:00:00Z"
+
+// cassetteSecretKeys are the names of JSON fields, form values and query parameters holding secrets.
+var cassetteSecretKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"token":         true,
+	"code":          true,
+}
+
+// cassetteSecretHeaders are the headers of requests and responses not recorded.
+var cassetteSecretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// cassetteTokenPattern matches the tokens of GitHub and GitLab appearing anywhere,
+// such as in GraphQL strings and headers.
+var cassetteTokenPattern = regexp.MustCompile(`\b(gh[opsur]_[A-Za-z0-9]{16,}|github_pat_[A-Za-z0-9_]{16,}|glpat-[A-Za-z0-9_-]{16,})`)
+
+// cassette records the exchanges with GitHub into a file, or replays them from it,
+// to reproduce problems offline from a recorded session. The file has an interaction in each line.
+// Requests are matched on their methods and URL paths, and GraphQL queries and variables,
+// regardless of the query parameters and other bodies, which may have been scrubbed.
+// The same requests are replayed in the order recorded.
+// cassetteSecretHeaders, cassetteSecretKeys and the values like tokens are not recorded,
+// and the expiration times of tokens are replaced by cassetteExpiresAt.
+// Installation IDs and the contents of the repositories are recorded as they are.
+type cassette struct {
+	replay bool
+
+	mu sync.Mutex
+	// file is appended while recording
+	file *os.File
+	// interactions are the recorded ones by their keys while replaying,
+	// which are replayed in order and the last one repeatedly
+	interactions map[string][]*cassetteInteraction
+}
+
+type cassetteInteraction struct {
+	Request struct {
+		Method string
+		URL    string
+		Body   string `json:",omitempty"`
+	}
+	Response struct {
+		StatusCode int
+		Header     http.Header
+		Body       string
+	}
+}
+
+// newCassette opens the cassette specified as "record:<path>" or "replay:<path>".
+func newCassette(spec string) (*cassette, error) {
+	p := strings.SplitN(spec, ":", 2)
+	if len(p) != 2 || p[1] == "" {
+		return nil, errors.Errorf("invalid cassette: %q", spec)
+	}
+
+	mode, path := p[0], p[1]
+	switch mode {
+	case "record":
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &cassette{file: f}, nil
+
+	case "replay":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		c := &cassette{replay: true, interactions: map[string][]*cassetteInteraction{}}
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var in cassetteInteraction
+				if err := json.Unmarshal(line, &in); err != nil {
+					return nil, errors.Wrapf(err, "%s", path)
+				}
+				key := cassetteKey(in.Request.Method, in.Request.URL, in.Request.Body)
+				c.interactions[key] = append(c.interactions[key], &in)
+			}
+			if err != nil {
+				break
+			}
+		}
+		return c, nil
+
+	default:
+		return nil, errors.Errorf("invalid cassette mode: %q", mode)
+	}
+}
+
+// transport returns an http.RoundTripper recording or replaying the requests sent through base.
+func (c *cassette) transport(base http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{cassette: c, base: base}
+}
+
+type cassetteTransport struct {
+	cassette *cassette
+	base     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = cloneRequest(req)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	var in cassetteInteraction
+	in.Request.Method = req.Method
+	in.Request.URL = scrubCassetteURL(req.URL)
+	in.Request.Body = scrubCassetteBody(req.Header.Get("Content-Type"), body)
+
+	if t.cassette.replay {
+		return t.cassette.replayInteraction(req, in)
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	in.Response.StatusCode = resp.StatusCode
+	in.Response.Header = make(http.Header, len(resp.Header))
+	for k, v := range resp.Header {
+		if cassetteSecretHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		values := make([]string, len(v))
+		for i, s := range v {
+			values[i] = scrubCassetteTokens(s)
+		}
+		in.Response.Header[k] = values
+	}
+	in.Response.Body = scrubCassetteBody(resp.Header.Get("Content-Type"), respBody)
+
+	if err := t.cassette.record(in); err != nil {
+		return nil, errors.Wrap(err, "cassette")
+	}
+
+	return resp, nil
+}
+
+func (c *cassette) record(in cassetteInteraction) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.file.Write(append(b, '\n'))
+	return err
+}
+
+func (c *cassette) replayInteraction(req *http.Request, in cassetteInteraction) (*http.Response, error) {
+	key := cassetteKey(in.Request.Method, in.Request.URL, in.Request.Body)
+
+	c.mu.Lock()
+	recorded := c.interactions[key]
+	if len(recorded) == 0 {
+		c.mu.Unlock()
+		return nil, errors.Errorf("cassette: no recorded response for %s %s", req.Method, in.Request.URL)
+	}
+	found := recorded[0]
+	if len(recorded) > 1 {
+		c.interactions[key] = recorded[1:]
+	}
+	c.mu.Unlock()
+
+	header := make(http.Header, len(found.Response.Header))
+	for k, v := range found.Response.Header {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        http.StatusText(found.Response.StatusCode),
+		StatusCode:    found.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(found.Response.Body)),
+		ContentLength: int64(len(found.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// authCodeURL is the URL which the visitor is redirected to for authorization while replaying,
+// which skips GitHub and goes back to redirectURI, then the recorded token exchange is replayed.
+func (c *cassette) authCodeURL(state string, redirectURI *url.URL) (string, error) {
+	if redirectURI == nil {
+		return "", errors.New("cassette: redirect URI required to replay")
+	}
+
+	u := *redirectURI
+	q := u.Query()
+	q.Set("code", cassetteScrubbed)
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// cassetteKey is the key to match requests on.
+func cassetteKey(method, rawurl, body string) string {
+	path := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		u.RawQuery = ""
+		path = u.String()
+	}
+	return method + " " + path + "\n" + normalizeCassetteGraphQL(body)
+}
+
+// normalizeCassetteGraphQL collapses whitespaces in the GraphQL query of body
+// and orders the fields of the variables, which may be given as a string.
+// It returns an empty string for bodies other than GraphQL requests.
+func normalizeCassetteGraphQL(body string) string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &obj); err != nil {
+		return ""
+	}
+
+	query, ok := obj["query"].(string)
+	if !ok {
+		return ""
+	}
+
+	v := map[string]interface{}{
+		"query":     strings.Join(strings.Fields(query), " "),
+		"variables": obj["variables"],
+	}
+	if vars, ok := obj["variables"].(string); ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(vars), &parsed); err == nil {
+			v["variables"] = parsed
+		}
+	}
+
+	// Keys of maps are sorted
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// scrubCassetteTokens replaces the values like tokens in s.
+func scrubCassetteTokens(s string) string {
+	return cassetteTokenPattern.ReplaceAllString(s, cassetteScrubbed)
+}
+
+func scrubCassetteURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	q := scrubbed.Query()
+	for k := range q {
+		if cassetteSecretKeys[k] {
+			q.Set(k, cassetteScrubbed)
+		}
+	}
+	// Encode sorts parameters
+	scrubbed.RawQuery = q.Encode()
+	return scrubCassetteTokens(scrubbed.String())
+}
+
+// scrubCassetteBody removes secrets in JSON or form-encoded body, and tokens in any body.
+func scrubCassetteBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			for k := range form {
+				if cassetteSecretKeys[k] {
+					form.Set(k, cassetteScrubbed)
+				}
+			}
+			return scrubCassetteTokens(form.Encode())
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if b, err := json.Marshal(scrubCassetteJSON(v)); err == nil {
+			return scrubCassetteTokens(string(b))
+		}
+	}
+
+	return scrubCassetteTokens(string(body))
+}
+
+func scrubCassetteJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			if _, isString := x.(string); isString && cassetteSecretKeys[k] {
+				v[k] = cassetteScrubbed
+			} else if _, isString := x.(string); isString && k == "expires_at" {
+				v[k] = cassetteExpiresAt
+			} else {
+				v[k] = scrubCassetteJSON(x)
+			}
+		}
+	case []interface{}:
+		for i, x := range v {
+			v[i] = scrubCassetteJSON(x)
+		}
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/fakegithub"
+)
+
+func TestGitHub_fakeGitHub(t *testing.T) {
+	fixtures, err := fakegithub.LoadFixtures("../fakegithub/testdata")
+	require.NoError(t, err)
+
+	fake := fakegithub.New(fixtures)
+	s := httptest.NewServer(fake)
+	defer s.Close()
+
+	g := githubGateway{
+		cache:      newMemoryCache(),
+		rateLimits: newRateLimits(),
+		domain:     "github.com",
+		apiURL:     s.URL + "/api/v3/",
+		graphqlURL: s.URL + "/api/graphql",
+		oauth2Config: &oauth2.Config{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  s.URL + "/login/oauth/authorize",
+				TokenURL: s.URL + "/login/oauth/access_token",
+			},
+		},
+	}
+	ctx := context.Background()
+
+	// OAuth
+	authURL, err := g.AuthCodeURL(ctx, "STATE", &url.URL{Scheme: "http", Host: "prchecklist.test", Path: "/auth/callback"})
+	require.NoError(t, err)
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := noRedirectClient.Get(authURL + "&login=motemen")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "STATE", callback.Query().Get("state"))
+
+	user, err := g.AuthenticateUser(ctx, callback.Query().Get("code"))
+	require.NoError(t, err)
+	assert.Equal(t, "motemen", user.Login)
+	assert.Equal(t, 8465, user.ID)
+
+	ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, user.HTTPClient(ctx))
+	ref := prchecklist.ChecklistRef{Owner: "motemen", Repo: "test-repository", Number: 2}
+
+	// Release pull request
+	pullReq, ctx, err := g.GetPullRequest(ctx, ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Release 2020-06-01", pullReq.Title)
+	require.Equal(t, 2, len(pullReq.Commits))
+	assert.Contains(t, pullReq.Commits[0].Message, "Merge pull request #1 ")
+	require.NotEmpty(t, pullReq.ConfigBlobID)
+
+	blob, err := g.GetBlob(ctx, ref, pullReq.ConfigBlobID)
+	require.NoError(t, err)
+	assert.Contains(t, string(blob), "- production")
+
+	// Feature pull requests
+	features, err := g.GetFeaturePullRequests(ctx, ref.Owner, ref.Repo, []int{1, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bug"}, features[1].Labels)
+	assert.Equal(t, []prchecklist.GitHubUserSimple{{Login: "tester"}}, features[1].ApprovedBy)
+	assert.Equal(t, "success", features[1].CIStatus)
+	assert.Equal(t, 10, features[1].Additions)
+	assert.Equal(t, "motemen", features[3].User.Login, "prefer assignee")
+	assert.Equal(t, []prchecklist.GitHubUserSimple{{Login: "motemen"}}, features[3].RequestedReviewers)
+	assert.Equal(t, "failure", features[3].CIStatus)
+
+	_, _, err = g.GetPullRequest(ctx, prchecklist.ChecklistRef{Owner: "motemen", Repo: "test-repository", Number: 99}, true)
+	assert.Error(t, err)
+
+	// Recent pull requests
+	recent, err := g.GetRecentPullRequests(ctx, prchecklist.RecentPullRequestsQuery{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(recent.PullRequests["motemen/test-repository"]))
+	assert.Equal(t, 2, recent.PullRequests["motemen/test-repository"][0].Number)
+	assert.Equal(t, 1, len(recent.PullRequests["motemen/private-repository"]))
+
+	recent, err = g.GetRecentPullRequests(ctx, prchecklist.RecentPullRequestsQuery{Search: "release 2020"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(recent.PullRequests["motemen/test-repository"]))
+
+	numbers, err := g.GetPullRequestNumbersByHead(ctx, ref.Owner, ref.Repo, "release")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, numbers)
+
+	// Writes
+	err = g.SetRepositoryStatusAs(ctx, ref.Owner, ref.Repo, pullReq.Commits[1].Oid, "prchecklist/qa/completed", "success", "2/2 checked", "http://prchecklist.test/")
+	require.NoError(t, err)
+	assert.Equal(t, []fakegithub.Status{{State: "success", Context: "prchecklist/qa/completed", Description: "2/2 checked", TargetURL: "http://prchecklist.test/"}}, fake.Statuses(ref.Owner, ref.Repo, pullReq.Commits[1].Oid))
+
+	id, err := g.PutIssueComment(ctx, ref.Owner, ref.Repo, ref.Number, 0, "summary")
+	require.NoError(t, err)
+	_, err = g.PutIssueComment(ctx, ref.Owner, ref.Repo, ref.Number, id, "summary updated")
+	require.NoError(t, err)
+	comments := fake.Comments(ref.Owner, ref.Repo, ref.Number)
+	require.Equal(t, 1, len(comments))
+	assert.Equal(t, "summary updated", comments[0].Body)
+}
+
+func TestGitHub_RefreshToken(t *testing.T) {
+	fixtures, err := fakegithub.LoadFixtures("../fakegithub/testdata")
+	require.NoError(t, err)
+	s := httptest.NewServer(fakegithub.New(fixtures))
+	defer s.Close()
+
+	g := githubGateway{
+		cache:      newMemoryCache(),
+		rateLimits: newRateLimits(),
+		domain:     "github.com",
+		apiURL:     s.URL + "/api/v3/",
+		graphqlURL: s.URL + "/api/graphql",
+		oauth2Config: &oauth2.Config{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  s.URL + "/login/oauth/authorize",
+				TokenURL: s.URL + "/login/oauth/access_token",
+			},
+		},
+	}
+	ctx := context.Background()
+	ref := prchecklist.ChecklistRef{Owner: "motemen", Repo: "test-repository", Number: 2}
+
+	user, err := g.AuthenticateUser(ctx, "motemen")
+	require.NoError(t, err)
+	assert.False(t, user.Token.Expiry.IsZero(), "token expires")
+
+	// Revoked
+	user.Token.AccessToken = "revoked"
+	_, _, err = g.GetPullRequest(context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, user.HTTPClient(ctx)), ref, true)
+	assert.True(t, errors.Is(err, prchecklist.ErrNotAuthed), "%v", err)
+
+	token, err := g.RefreshToken(ctx, user.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "fake:motemen", token.AccessToken)
+
+	user.Token = token
+	_, _, err = g.GetPullRequest(context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, user.HTTPClient(ctx)), ref, true)
+	assert.NoError(t, err)
+
+	_, err = g.RefreshToken(ctx, &oauth2.Token{AccessToken: "revoked", RefreshToken: "fake-refresh:nobody"})
+	assert.Equal(t, prchecklist.ErrNotAuthed, err)
+
+	_, err = g.RefreshToken(ctx, &oauth2.Token{AccessToken: "revoked"})
+	assert.Equal(t, prchecklist.ErrNotAuthed, err)
+}
@@ -0,0 +1,248 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// featurePullRequestsChunkSize is how many feature pull requests are queried at once.
+const featurePullRequestsChunkSize = 50
+
+// githubFeaturePullRequest is a feature pull request in the result of featurePullRequestsQuery.
+type githubFeaturePullRequest struct {
+	Title  string
+	Number int
+	Body   string
+	URL    string
+	Author struct {
+		Login string
+	}
+	Assignees struct {
+		Edges []struct {
+			Node struct {
+				Login string
+			}
+		}
+	} `graphql:"(first: 1)"`
+	githubPullRequestDetails `graphql:"... on PullRequest"`
+}
+
+// githubFeaturePullRequestFragment is the GraphQL fragment querying githubFeaturePullRequest.
+// Keep it in sync with the fields of githubFeaturePullRequest.
+const githubFeaturePullRequestFragment = `fragment featurePullRequest on PullRequest {
+  title
+  number
+  body
+  url
+  author {
+    login
+  }
+  assignees(first: 1) {
+    edges {
+      node {
+        login
+      }
+    }
+  }
+  labels(first: 20) {
+    edges {
+      node {
+        name
+      }
+    }
+  }
+  mergedAt
+  mergedBy {
+    login
+  }
+  reviewRequests(first: 20) {
+    edges {
+      node {
+        requestedReviewer {
+          ... on User {
+            login
+          }
+        }
+      }
+    }
+  }
+  reviews(first: 50, states: APPROVED) {
+    edges {
+      node {
+        author {
+          login
+        }
+      }
+    }
+  }
+  changedFiles
+  additions
+  deletions
+  headCommit: commits(last: 1) {
+    edges {
+      node {
+        commit {
+          statusCheckRollup {
+            state
+          }
+        }
+      }
+    }
+  }
+}`
+
+// featurePullRequestsQuery builds a query fetching the pull requests of numbers at once,
+// aliased as pr0, pr1, ... in the order of numbers.
+func featurePullRequestsQuery(numbers []int) string {
+	var b strings.Builder
+	b.WriteString("query($owner: String!, $repo: String!) {\n")
+	b.WriteString("  rateLimit {\n    cost\n    limit\n    remaining\n    resetAt\n  }\n")
+	b.WriteString("  repository(owner: $owner, name: $repo) {\n    isPrivate\n")
+	for i, n := range numbers {
+		fmt.Fprintf(&b, "    pr%d: pullRequest(number: %d) {\n      ...featurePullRequest\n    }\n", i, n)
+	}
+	b.WriteString("  }\n}\n")
+	b.WriteString(githubFeaturePullRequestFragment)
+	return b.String()
+}
+
+// GetFeaturePullRequests retrieves the feature pull requests of numbers in the repository,
+// querying the ones not cached in chunks of featurePullRequestsChunkSize.
+// Like GetPullRequest with falsy isBase, it must be called with the context
+// returned by GetPullRequest for the release pull request.
+func (g githubGateway) GetFeaturePullRequests(ctx context.Context, owner, repo string, numbers []int) (map[int]*prchecklist.PullRequest, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pullReqs := make(map[int]*prchecklist.PullRequest, len(numbers))
+
+	ref := prchecklist.ChecklistRef{Owner: owner, Repo: repo}
+	missing := []int{}
+	for _, n := range numbers {
+		if _, ok := pullReqs[n]; ok {
+			continue
+		}
+
+		var cached prchecklist.PullRequest
+		if getCacheJSON(g.cache, pullRequestCacheKey(owner, repo, n, false), &cached) && (!cached.IsPrivate || g.hasRepoAccess(ctx, ref)) {
+			pullReqs[n] = &cached
+		} else {
+			pullReqs[n] = nil
+			missing = append(missing, n)
+		}
+	}
+
+	for len(missing) > 0 {
+		chunk := missing
+		if len(chunk) > featurePullRequestsChunkSize {
+			chunk = chunk[:featurePullRequestsChunkSize]
+		}
+		missing = missing[len(chunk):]
+
+		fetched, err := g.getFeaturePullRequests(ctx, owner, repo, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pullReq := range fetched {
+			pullReqs[pullReq.Number] = pullReq
+			if err := setCacheJSON(g.cache, pullRequestCacheKey(owner, repo, pullReq.Number, false), pullReq, cacheDurationPullReqFeat); err != nil {
+				log.Printf("cache: %s", err)
+			}
+		}
+	}
+
+	return pullReqs, nil
+}
+
+// getFeaturePullRequests queries the pull requests of numbers at once.
+// Pull requests which cannot be retrieved, such as deleted ones, are left out.
+func (g githubGateway) getFeaturePullRequests(ctx context.Context, owner, repo string, numbers []int) ([]*prchecklist.PullRequest, error) {
+	result, err := g.queryGraphQLResult(ctx, featurePullRequestsQuery(numbers), map[string]string{"owner": owner, "repo": repo})
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors on the aliased pull requests leave the others
+	failed := map[string]bool{}
+	for _, e := range result.Errors {
+		if len(e.Path) >= 2 && e.Path[0] == "repository" {
+			if alias, ok := e.Path[1].(string); ok && strings.HasPrefix(alias, "pr") {
+				failed[alias] = true
+				log.Printf("GetFeaturePullRequests(%s/%s): %s: %s", owner, repo, alias, e.Message)
+				continue
+			}
+		}
+		return nil, fmt.Errorf("GraphQL error: %v", result.Errors)
+	}
+
+	var qr struct {
+		Repository map[string]json.RawMessage
+	}
+	if len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, &qr); err != nil {
+			return nil, err
+		}
+	}
+	if qr.Repository == nil {
+		return nil, errors.Errorf("could not retrieve repo/pullreq")
+	}
+
+	var isPrivate bool
+	if err := json.Unmarshal(qr.Repository["isPrivate"], &isPrivate); err != nil {
+		return nil, errors.Wrap(err, "isPrivate")
+	}
+
+	pullReqs := make([]*prchecklist.PullRequest, 0, len(numbers))
+	for i, n := range numbers {
+		alias := fmt.Sprintf("pr%d", i)
+		if failed[alias] {
+			continue
+		}
+
+		var fr *githubFeaturePullRequest
+		if raw, ok := qr.Repository[alias]; ok {
+			if err := json.Unmarshal(raw, &fr); err != nil {
+				return nil, errors.Wrapf(err, "pull request #%d", n)
+			}
+		}
+		if fr == nil {
+			log.Printf("GetFeaturePullRequests(%s/%s): could not retrieve pull request #%d", owner, repo, n)
+			continue
+		}
+
+		pullReq := &prchecklist.PullRequest{
+			URL:       fr.URL,
+			Title:     fr.Title,
+			Body:      fr.Body,
+			IsPrivate: isPrivate,
+			Owner:     owner,
+			Repo:      repo,
+			Number:    n,
+			Commits:   []prchecklist.Commit{},
+			User: prchecklist.GitHubUserSimple{
+				Login: fr.Author.Login,
+			},
+		}
+
+		// prefer assignee
+		if len(fr.Assignees.Edges) > 0 {
+			pullReq.User.Login = fr.Assignees.Edges[0].Node.Login
+		}
+
+		fr.githubPullRequestDetails.fillPullRequest(pullReq)
+
+		pullReqs = append(pullReqs, pullReq)
+	}
+
+	return pullReqs, nil
+}
@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// handlerTransport serves requests by an http.Handler without network.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+var rxFeaturePullRequestAlias = regexp.MustCompile(`(pr\d+): pullRequest\(number: (\d+)\)`)
+
+func TestGitHub_GetFeaturePullRequests(t *testing.T) {
+	var queries int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		queries++
+
+		var body struct{ Query string }
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+
+		repository := map[string]interface{}{"isPrivate": false}
+		for _, m := range rxFeaturePullRequestAlias.FindAllStringSubmatch(body.Query, -1) {
+			n, _ := strconv.Atoi(m[2])
+			repository[m[1]] = map[string]interface{}{
+				"title":     fmt.Sprintf("Feature %d", n),
+				"number":    n,
+				"url":       fmt.Sprintf("https://github.com/owner/repo/pull/%d", n),
+				"author":    map[string]interface{}{"login": "author"},
+				"assignees": map[string]interface{}{"edges": []interface{}{}},
+				"labels": map[string]interface{}{"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"name": "risky"}},
+				}},
+				"mergedAt":     "2020-06-01T12:00:00Z",
+				"mergedBy":     map[string]interface{}{"login": "merger"},
+				"changedFiles": 3,
+				"additions":    10,
+				"deletions":    2,
+				"reviewRequests": map[string]interface{}{"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"requestedReviewer": map[string]interface{}{"login": "reviewer"}}},
+					map[string]interface{}{"node": map[string]interface{}{"requestedReviewer": map[string]interface{}{}}},
+				}},
+				"reviews": map[string]interface{}{"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"author": map[string]interface{}{"login": "approver"}}},
+					map[string]interface{}{"node": map[string]interface{}{"author": map[string]interface{}{"login": "approver"}}},
+				}},
+				"headCommit": map[string]interface{}{"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"commit": map[string]interface{}{"statusCheckRollup": map[string]interface{}{"state": "FAILURE"}}}},
+				}},
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"repository": repository},
+		})
+	})
+
+	g := githubGateway{cache: newMemoryCache(), domain: "github.com"}
+	ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: handlerTransport{handler}})
+
+	numbers := make([]int, featurePullRequestsChunkSize+10)
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+
+	pullReqs, err := g.GetFeaturePullRequests(ctx, "owner", "repo", numbers)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queries, "queried in chunks")
+	assert.Equal(t, len(numbers), len(pullReqs))
+	assert.Equal(t, "Feature 55", pullReqs[55].Title)
+	assert.Equal(t, "author", pullReqs[55].User.Login)
+	assert.Equal(t, []string{"risky"}, pullReqs[55].Labels)
+	assert.Equal(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), pullReqs[55].MergedAt.UTC())
+	assert.Equal(t, &prchecklist.GitHubUserSimple{Login: "merger"}, pullReqs[55].MergedBy)
+	assert.Equal(t, []prchecklist.GitHubUserSimple{{Login: "reviewer"}}, pullReqs[55].RequestedReviewers)
+	assert.Equal(t, []prchecklist.GitHubUserSimple{{Login: "approver"}}, pullReqs[55].ApprovedBy)
+	assert.Equal(t, 3, pullReqs[55].ChangedFiles)
+	assert.Equal(t, 10, pullReqs[55].Additions)
+	assert.Equal(t, 2, pullReqs[55].Deletions)
+	assert.Equal(t, "failure", pullReqs[55].CIStatus)
+
+	pullReqs, err = g.GetFeaturePullRequests(ctx, "owner", "repo", []int{3, 100})
+	require.NoError(t, err)
+	assert.Equal(t, 3, queries, "only #100 is queried")
+	assert.Equal(t, "Feature 3", pullReqs[3].Title)
+	assert.Equal(t, "Feature 100", pullReqs[100].Title)
+}
+
+func TestGitHub_GetRecentPullRequests(t *testing.T) {
+	var vars map[string]interface{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct{ Variables string }
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		require.NoError(t, json.Unmarshal([]byte(body.Variables), &vars))
+
+		pr := func(n int, base string) map[string]interface{} {
+			return map[string]interface{}{"node": map[string]interface{}{
+				"title": fmt.Sprintf("PR %d", n), "number": n, "baseRefName": base,
+			}}
+		}
+
+		fmt.Fprint(w, `{"data":{"viewer":{"repositories":{"edges":[`)
+		json.NewEncoder(w).Encode(map[string]interface{}{"node": map[string]interface{}{
+			"nameWithOwner":    "owner/main-repo",
+			"defaultBranchRef": map[string]interface{}{"name": "main"},
+			"pullRequests":     map[string]interface{}{"edges": []interface{}{pr(1, "main"), pr(2, "feature"), pr(3, "master")}},
+		}})
+		fmt.Fprint(w, `],"pageInfo":{"hasNextPage":true,"endCursor":"CURSOR"}}}}}`)
+	})
+
+	g := githubGateway{cache: newMemoryCache(), domain: "github.com"}
+	ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: handlerTransport{handler}})
+
+	result, err := g.GetRecentPullRequests(ctx, prchecklist.RecentPullRequestsQuery{After: "PREV"})
+	require.NoError(t, err)
+	assert.Equal(t, "PREV", vars["after"])
+	assert.Equal(t, "CURSOR", result.NextCursor)
+	require.Equal(t, 1, len(result.PullRequests["owner/main-repo"]))
+	assert.Equal(t, 1, result.PullRequests["owner/main-repo"][0].Number, "default branch")
+
+	result, err = g.GetRecentPullRequests(ctx, prchecklist.RecentPullRequestsQuery{BaseBranches: []string{"master", "feature"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.PullRequests["owner/main-repo"]))
+	assert.Equal(t, 2, result.PullRequests["owner/main-repo"][0].Number)
+	assert.Equal(t, 3, result.PullRequests["owner/main-repo"][1].Number)
+}
+
+func TestGitHubFeaturePullRequestFragment(t *testing.T) {
+	// The fragment selects the fields githubFeaturePullRequest expects, ignoring nesting
+	fields := func(q string) []string {
+		q = strings.Replace(q, "... on PullRequest", "", -1)
+		fs := []string{}
+		for _, f := range strings.Fields(q) {
+			if f != "{" && f != "}" {
+				fs = append(fs, f)
+			}
+		}
+		return fs
+	}
+
+	q := string(mustBuildGraphQLQuery(&struct{ PullRequest githubFeaturePullRequest }{}))
+	assert.Equal(t, append([]string{"fragment", "featurePullRequest", "on", "PullRequest"}, fields(q)[2:]...), fields(githubFeaturePullRequestFragment))
+}
+
+func TestGitHub_GetFeaturePullRequests_partial(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{
+  "data": {"repository": {"isPrivate": false, "pr0": {"title": "Feature 1", "number": 1}, "pr1": null}},
+  "errors": [{"type": "NOT_FOUND", "path": ["repository", "pr1"], "message": "Could not resolve to a PullRequest with the number of 2."}]
+}`)
+	})
+
+	g := githubGateway{cache: newMemoryCache(), domain: "github.com"}
+	ctx := context.WithValue(context.Background(), prchecklist.ContextKeyHTTPClient, &http.Client{Transport: handlerTransport{handler}})
+
+	pullReqs, err := g.GetFeaturePullRequests(ctx, "owner", "repo", []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Feature 1", pullReqs[1].Title)
+	assert.Nil(t, pullReqs[2])
+}
@@ -7,14 +7,17 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v31/github"
 	graphqlquery "github.com/motemen/go-graphql-query"
-	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
 
@@ -24,13 +27,24 @@ import (
 const (
 	cacheDurationPullReqBase = 30 * time.Second
 	cacheDurationPullReqFeat = 5 * time.Minute
-	cacheDurationBlob        = cache.NoExpiration
+	cacheDurationBlob        = 0 // never expires
+	// cacheDurationRepoAccess is how long a user is trusted to read a private repository
+	// after the access is confirmed by GitHub.
+	cacheDurationRepoAccess = 5 * time.Minute
 )
 
 var (
 	githubClientID     string
 	githubClientSecret string
 	githubDomain       string
+	githubURL          string
+	githubToken        string
+	githubAppID        int64
+	githubAppKey       string
+	githubCache        string
+	githubCAFile       string
+	githubHostsFile    string
+	githubCassette     string
 )
 
 func getenv(key, def string) string {
@@ -45,6 +59,15 @@ func init() {
 	flag.StringVar(&githubClientID, "github-client-id", os.Getenv("GITHUB_CLIENT_ID"), "GitHub client ID (GITHUB_CLIENT_ID)")
 	flag.StringVar(&githubClientSecret, "github-client-secret", os.Getenv("GITHUB_CLIENT_SECRET"), "GitHub client secret (GITHUB_CLIENT_SECRET)")
 	flag.StringVar(&githubDomain, "github-domain", getenv("GITHUB_DOMAIN", "github.com"), "GitHub domain (GITHUB_DOMAIN)")
+	flag.StringVar(&githubURL, "github-url", os.Getenv("PRCHECKLIST_GITHUB_URL"), "base `URL` of GitHub laid out as GitHub Enterprise, such as the one of \"prchecklist fake-github\" (PRCHECKLIST_GITHUB_URL)")
+	flag.StringVar(&githubToken, "github-token", os.Getenv("PRCHECKLIST_GITHUB_TOKEN"), "GitHub token used without visitors, such as on webhooks (PRCHECKLIST_GITHUB_TOKEN)")
+	flag.StringVar(&githubCache, "github-cache", getenv("PRCHECKLIST_GITHUB_CACHE", "memory"), "cache of GitHub data, \"memory\" or \"redis://...\" to share among processes (PRCHECKLIST_GITHUB_CACHE)")
+	appID, _ := strconv.ParseInt(os.Getenv("PRCHECKLIST_GITHUB_APP_ID"), 10, 64)
+	flag.Int64Var(&githubAppID, "github-app-id", appID, "GitHub App ID to authenticate as the app (PRCHECKLIST_GITHUB_APP_ID)")
+	flag.StringVar(&githubCAFile, "github-ca-file", os.Getenv("PRCHECKLIST_GITHUB_CA_FILE"), "`path` to PEM-encoded certificates to trust for GitHub Enterprise (PRCHECKLIST_GITHUB_CA_FILE)")
+	flag.StringVar(&githubHostsFile, "github-hosts", os.Getenv("PRCHECKLIST_GITHUB_HOSTS"), "`path` to YAML listing additional GitHub hosts (PRCHECKLIST_GITHUB_HOSTS)")
+	flag.StringVar(&githubCassette, "github-cassette", os.Getenv("PRCHECKLIST_GITHUB_CASSETTE"), "\"record:<path>\" to record exchanges with GitHub to the file, or \"replay:<path>\" to replay them (PRCHECKLIST_GITHUB_CASSETTE)")
+	flag.StringVar(&githubAppKey, "github-app-private-key", os.Getenv("PRCHECKLIST_GITHUB_APP_PRIVATE_KEY"), "GitHub App private key in PEM or path to it (PRCHECKLIST_GITHUB_APP_PRIVATE_KEY)")
 }
 
 // NewGitHub creates a new GitHub gateway.
@@ -53,13 +76,24 @@ func NewGitHub() (*githubGateway, error) {
 		return nil, errors.New("gateway/github: both GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET must be set")
 	}
 
+	baseURL := "https://" + githubDomain
+	if githubURL != "" {
+		baseURL = strings.TrimSuffix(githubURL, "/")
+	}
+
 	var githubEndpoint = oauth2.Endpoint{
-		AuthURL:  "https://" + githubDomain + "/login/oauth/authorize",
-		TokenURL: "https://" + githubDomain + "/login/oauth/access_token",
+		AuthURL:  baseURL + "/login/oauth/authorize",
+		TokenURL: baseURL + "/login/oauth/access_token",
+	}
+
+	c, err := NewCache(githubCache)
+	if err != nil {
+		return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_CACHE")
 	}
 
-	return &githubGateway{
-		cache: cache.New(30*time.Second, 10*time.Minute),
+	g := &githubGateway{
+		cache:      c,
+		rateLimits: newRateLimits(),
 		oauth2Config: &oauth2.Config{
 			ClientID:     githubClientID,
 			ClientSecret: githubClientSecret,
@@ -67,19 +101,205 @@ func NewGitHub() (*githubGateway, error) {
 			Scopes:       []string{"repo"},
 		},
 		domain: githubDomain,
-	}, nil
+		token:  githubToken,
+	}
+
+	if githubURL != "" {
+		g.apiURL = baseURL + "/api/v3/"
+		g.graphqlURL = baseURL + "/api/graphql"
+	}
+
+	if githubCAFile != "" {
+		g.transport, err = newGitHubTransport(githubCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_CA_FILE")
+		}
+	}
+
+	if githubCassette != "" {
+		g.cassette, err = newCassette(githubCassette)
+		if err != nil {
+			return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_CASSETTE")
+		}
+		g.transport = g.cassette.transport(g.transport)
+	}
+
+	if githubAppID != 0 {
+		key, err := parseGitHubAppPrivateKey(githubAppKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_APP_PRIVATE_KEY")
+		}
+
+		baseURL, err := url.Parse(g.apiBaseURL())
+		if err != nil {
+			return nil, err
+		}
+
+		g.app = newGitHubApp(githubAppID, key, baseURL)
+		g.app.transport = g.transport
+		// The client ID and secret are the ones of the app, whose user tokens
+		// have the permissions of the app instead of scopes
+		g.oauth2Config.Scopes = nil
+	}
+
+	if githubHostsFile != "" {
+		configs, err := loadGitHubHostConfigs(githubHostsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_HOSTS")
+		}
+
+		g.hosts = map[string]*githubGateway{}
+		for _, c := range configs {
+			if c.Domain == g.domain || g.hosts[c.Domain] != nil {
+				return nil, errors.Errorf("gateway/github: PRCHECKLIST_GITHUB_HOSTS: duplicate domain: %q", c.Domain)
+			}
+			h, err := g.newGitHubHost(c)
+			if err != nil {
+				return nil, errors.Wrap(err, "gateway/github: PRCHECKLIST_GITHUB_HOSTS")
+			}
+			if g.cassette != nil {
+				h.cassette = g.cassette
+				h.transport = g.cassette.transport(h.transport)
+			}
+			g.hosts[c.Domain] = h
+		}
+	}
+
+	return g, nil
 }
 
 type githubGateway struct {
-	cache        *cache.Cache
+	cache        Cache
 	oauth2Config *oauth2.Config
 	domain       string
+	// app is set when authenticating as a GitHub App
+	app        *githubApp
+	rateLimits *rateLimits
+	// token is used without visitors if not authenticating as a GitHub App
+	token string
+	// apiURL and graphqlURL override the API endpoints derived from domain
+	apiURL     string
+	graphqlURL string
+	// transport is set to trust a custom CA, or to record or replay with cassette
+	transport http.RoundTripper
+	cassette  *cassette
+
+	// host is the domain of an additional host, which is empty for the default host
+	host string
+	// hosts are the additional hosts by their domains, set to the default host
+	hosts map[string]*githubGateway
+}
+
+// githubRateLimit is the rate limit status of GraphQL API, queried along with other fields.
+type githubRateLimit struct {
+	Cost      int
+	Limit     int
+	Remaining int
+	ResetAt   string // DateTime
+}
+
+// githubPullRequestDetails are the fields of feature pull requests
+// which help to prioritize checklist items.
+type githubPullRequestDetails struct {
+	Labels struct {
+		Edges []struct {
+			Node struct {
+				Name string
+			}
+		}
+	} `graphql:"(first: 20)"`
+	MergedAt string // DateTime
+	MergedBy *struct {
+		Login string
+	}
+	ReviewRequests struct {
+		Edges []struct {
+			Node struct {
+				RequestedReviewer struct {
+					Login string `graphql:"... on User"`
+				}
+			}
+		}
+	} `graphql:"(first: 20)"`
+	Reviews struct {
+		Edges []struct {
+			Node struct {
+				Author struct {
+					Login string
+				}
+			}
+		}
+	} `graphql:"(first: 50, states: APPROVED)"`
+	ChangedFiles int
+	Additions    int
+	Deletions    int
+	HeadCommit   struct {
+		Edges []struct {
+			Node struct {
+				Commit struct {
+					// StatusCheckRollup combines check runs as well as commit statuses
+					StatusCheckRollup *struct {
+						State string
+					}
+				}
+			}
+		}
+	} `graphql:"alias=commits,(last: 1)"`
+}
+
+// fillPullRequest sets the details to pullReq.
+func (d githubPullRequestDetails) fillPullRequest(pullReq *prchecklist.PullRequest) {
+	pullReq.Labels = make([]string, len(d.Labels.Edges))
+	for i, e := range d.Labels.Edges {
+		pullReq.Labels[i] = e.Node.Name
+	}
+
+	if t, err := time.Parse(time.RFC3339, d.MergedAt); err == nil {
+		pullReq.MergedAt = &t
+	}
+	if d.MergedBy != nil {
+		pullReq.MergedBy = &prchecklist.GitHubUserSimple{Login: d.MergedBy.Login}
+	}
+
+	pullReq.RequestedReviewers = []prchecklist.GitHubUserSimple{}
+	for _, e := range d.ReviewRequests.Edges {
+		// Teams have no login
+		if login := e.Node.RequestedReviewer.Login; login != "" {
+			pullReq.RequestedReviewers = append(pullReq.RequestedReviewers, prchecklist.GitHubUserSimple{Login: login})
+		}
+	}
+
+	pullReq.ApprovedBy = []prchecklist.GitHubUserSimple{}
+	seen := map[string]bool{}
+	for _, e := range d.Reviews.Edges {
+		if login := e.Node.Author.Login; login != "" && !seen[login] {
+			seen[login] = true
+			pullReq.ApprovedBy = append(pullReq.ApprovedBy, prchecklist.GitHubUserSimple{Login: login})
+		}
+	}
+
+	pullReq.ChangedFiles = d.ChangedFiles
+	pullReq.Additions = d.Additions
+	pullReq.Deletions = d.Deletions
+
+	if edges := d.HeadCommit.Edges; len(edges) > 0 && edges[0].Node.Commit.StatusCheckRollup != nil {
+		pullReq.CIStatus = githubRollupStatus(edges[0].Node.Commit.StatusCheckRollup.State)
+	}
+}
+
+// githubRollupStatus maps the state of a status check rollup to the one of GitHub commit statuses.
+func githubRollupStatus(state string) string {
+	if state == "EXPECTED" {
+		return "pending"
+	}
+	return strings.ToLower(state)
 }
 
 type githubPullRequest struct {
 	GraphQLArguments struct {
 		IsBase bool `graphql:"$isBase,notnull"`
 	}
+	RateLimit  githubRateLimit
 	Repository *struct {
 		GraphQLArguments struct {
 			Owner string `graphql:"$owner,notnull"`
@@ -107,7 +327,8 @@ type githubPullRequest struct {
 			BaseRef struct {
 				Name string
 			}
-			HeadRef struct {
+			githubPullRequestDetails `graphql:"... on PullRequest,@skip(if: $isBase)"`
+			HeadRef                  struct {
 				Target struct {
 					Tree struct {
 						Entries []struct {
@@ -141,27 +362,84 @@ type githubPullRequest struct {
 	}
 }
 
+// githubRecentPullRequest is a pull request listed on the top page.
+type githubRecentPullRequest struct {
+	Title       string
+	Number      int
+	URL         string
+	BaseRefName string
+	Commits     struct {
+		Edges []struct {
+			Node struct {
+				Commit struct {
+					Message string
+				}
+			}
+		}
+	} `graphql:"(first: 100),@include(if: $withCommits)"`
+}
+
 type githubRecentPullRequests struct {
-	Viewer struct {
+	GraphQLArguments struct {
+		After       string `graphql:"$after"`
+		WithCommits bool   `graphql:"$withCommits,notnull"`
+	}
+	RateLimit githubRateLimit
+	Viewer    struct {
 		Repositories struct {
 			Edges []struct {
 				Node struct {
-					NameWithOwner string
-					PullRequests  struct {
+					NameWithOwner    string
+					DefaultBranchRef *struct {
+						Name string
+					}
+					PullRequests struct {
 						Edges []struct {
-							Node struct {
-								Title  string
-								Number int
-								URL    string
-							}
+							Node githubRecentPullRequest
 						}
-					} `graphql:"(first: 5, orderBy: {field: UPDATED_AT, direction: DESC}, baseRefName: \"master\")"`
+					} `graphql:"(first: 20, orderBy: {field: UPDATED_AT, direction: DESC})"`
 				}
 			}
-		} `graphql:"(first: 10, orderBy: {field: PUSHED_AT, direction: DESC}, affiliations: [OWNER, ORGANIZATION_MEMBER, COLLABORATOR])"`
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+		} `graphql:"(first: 10, after: $after, orderBy: {field: PUSHED_AT, direction: DESC}, affiliations: [OWNER, ORGANIZATION_MEMBER, COLLABORATOR])"`
 	}
 }
 
+type githubSearchPullRequests struct {
+	GraphQLArguments struct {
+		Search      string `graphql:"$search,notnull"`
+		After       string `graphql:"$after"`
+		WithCommits bool   `graphql:"$withCommits,notnull"`
+	}
+	RateLimit githubRateLimit
+	Search    struct {
+		Edges []struct {
+			Node struct {
+				githubRecentPullRequest `graphql:"... on PullRequest"`
+				Repository              struct {
+					NameWithOwner    string
+					DefaultBranchRef *struct {
+						Name string
+					}
+				} `graphql:"... on PullRequest"`
+			}
+		}
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   string
+		}
+	} `graphql:"(query: $search, type: ISSUE, first: 30, after: $after)"`
+}
+
+type githubRecentPullRequestsVars struct {
+	Search      string `json:"search,omitempty"`
+	After       string `json:"after,omitempty"`
+	WithCommits bool   `json:"withCommits"`
+}
+
 type githubPullRequsetVars struct {
 	Owner        string `json:"owner"`
 	Repo         string `json:"repo"`
@@ -171,15 +449,21 @@ type githubPullRequsetVars struct {
 }
 
 type graphQLResult struct {
-	Data   interface{}
-	Errors []struct {
-		Message string
-	}
+	Data   json.RawMessage
+	Errors []graphQLError
+}
+
+type graphQLError struct {
+	Type    string
+	Message string
+	// Path is where the error occurred in the query, such as ["repository", "pr0"]
+	Path []interface{}
 }
 
 var (
 	pullRequestQuery        string
 	recentPullRequestsQuery string
+	searchPullRequestsQuery string
 )
 
 func mustBuildGraphQLQuery(q interface{}) []byte {
@@ -194,15 +478,21 @@ func mustBuildGraphQLQuery(q interface{}) []byte {
 func init() {
 	pullRequestQuery = string(mustBuildGraphQLQuery(&githubPullRequest{}))
 	recentPullRequestsQuery = string(mustBuildGraphQLQuery(&githubRecentPullRequests{}))
+	searchPullRequestsQuery = string(mustBuildGraphQLQuery(&githubSearchPullRequests{}))
 }
 
 func (g githubGateway) GetBlob(ctx context.Context, ref prchecklist.ChecklistRef, sha string) ([]byte, error) {
-	cacheKey := fmt.Sprintf("blob\000%s\000%s", ref.String(), sha)
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	if data, ok := g.cache.Get(cacheKey); ok {
-		if blob, ok := data.([]byte); ok {
-			return blob, nil
-		}
+	cacheKey := fmt.Sprintf("blob\000%s/%s\000%s", ref.Owner, ref.Repo, sha)
+
+	if blob, ok, err := g.cache.Get(cacheKey); err != nil {
+		log.Printf("cache: %s", err)
+	} else if ok {
+		return blob, nil
 	}
 
 	blob, err := g.getBlob(ctx, ref, sha)
@@ -210,7 +500,9 @@ func (g githubGateway) GetBlob(ctx context.Context, ref prchecklist.ChecklistRef
 		return nil, err
 	}
 
-	g.cache.Set(cacheKey, blob, cacheDurationBlob)
+	if err := g.cache.Set(cacheKey, blob, cacheDurationBlob); err != nil {
+		log.Printf("cache: %s", err)
+	}
 
 	return blob, nil
 }
@@ -254,29 +546,25 @@ func contextWithRepoAccessRight(ctx context.Context, ref prchecklist.ChecklistRe
 }
 
 func (g githubGateway) GetPullRequest(ctx context.Context, ref prchecklist.ChecklistRef, isBase bool) (*prchecklist.PullRequest, context.Context, error) {
-	cacheKey := fmt.Sprintf("pullRequest\000%s\000%v", ref.String(), isBase)
-
-	if data, ok := g.cache.Get(cacheKey); ok {
-		if pullReq, ok := data.(*prchecklist.PullRequest); ok {
-			if pullReq.IsPrivate && !contextHasRepoAccessRight(ctx, ref) {
-				// something's wrong!
-			} else {
-				return pullReq, ctx, nil
-			}
-		}
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, ctx, err
 	}
 
+	cacheKey := pullRequestCacheKey(ref.Owner, ref.Repo, ref.Number, isBase)
+
+	var cached prchecklist.PullRequest
+	if getCacheJSON(g.cache, cacheKey, &cached) && (!cached.IsPrivate || g.hasRepoAccess(ctx, ref)) {
+		return &cached, contextWithRepoAccessRight(ctx, ref), nil
+	}
+
+	// Fetching the pull request by the visitor's client confirms the access to the repository
 	pullReq, err := g.getPullRequest(ctx, ref, isBase)
 	if err != nil {
 		return nil, ctx, err
 	}
-	if isBase && pullReq.IsPrivate {
-		// Do not cache result if the pull request is private
-		// and isBase is true to check if the visitor has rights to
-		// read the repo.
-		// If isBase is false, we don't need to check vititor's rights
-		// because GetPullRequest() with truthy isBase must be called before falsy one.
-		return pullReq, contextWithRepoAccessRight(ctx, ref), nil
+	if pullReq.IsPrivate {
+		g.setRepoAccess(ctx, ref)
 	}
 
 	var cacheDuration time.Duration
@@ -286,36 +574,152 @@ func (g githubGateway) GetPullRequest(ctx context.Context, ref prchecklist.Check
 		cacheDuration = cacheDurationPullReqFeat
 	}
 
-	g.cache.Set(cacheKey, pullReq, cacheDuration)
+	if err := setCacheJSON(g.cache, cacheKey, pullReq, cacheDuration); err != nil {
+		log.Printf("cache: %s", err)
+	}
 
 	return pullReq, contextWithRepoAccessRight(ctx, ref), nil
 }
 
-func (g githubGateway) GetRecentPullRequests(ctx context.Context) (map[string][]*prchecklist.PullRequest, error) {
-	var result githubRecentPullRequests
-	err := g.queryGraphQL(ctx, recentPullRequestsQuery, nil, &result)
+// hasRepoAccess reports whether the visitor is known to be able to read the repository of ref,
+// by the context or the cached access of the user.
+func (g githubGateway) hasRepoAccess(ctx context.Context, ref prchecklist.ChecklistRef) bool {
+	if contextHasRepoAccessRight(ctx, ref) {
+		return true
+	}
+
+	userID, ok := prchecklist.ContextUserID(ctx)
+	if !ok {
+		return false
+	}
+
+	var granted bool
+	return getCacheJSON(g.cache, repoAccessCacheKey(userID, ref.Owner, ref.Repo), &granted) && granted
+}
+
+// setRepoAccess remembers that the visitor can read the repository of ref for cacheDurationRepoAccess.
+func (g githubGateway) setRepoAccess(ctx context.Context, ref prchecklist.ChecklistRef) {
+	userID, ok := prchecklist.ContextUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := setCacheJSON(g.cache, repoAccessCacheKey(userID, ref.Owner, ref.Repo), true, cacheDurationRepoAccess); err != nil {
+		log.Printf("cache: %s", err)
+	}
+}
+
+// repoAccessCacheKey is the cache key of whether the user can read the repository.
+// Repository names are case-insensitive on GitHub.
+func repoAccessCacheKey(userID int, owner, repo string) string {
+	return fmt.Sprintf("repoAccess\000%d\000%s/%s", userID, strings.ToLower(owner), strings.ToLower(repo))
+}
+
+// pullRequestCacheKey is the cache key of a pull request, which is shared among stages.
+func pullRequestCacheKey(owner, repo string, number int, isBase bool) string {
+	return fmt.Sprintf("pullRequest\000%s/%s#%d\000%v", owner, repo, number, isBase)
+}
+
+// recentPullRequestsPerRepo is how many pull requests are listed for each repository.
+const recentPullRequestsPerRepo = 5
+
+// GetRecentPullRequests lists pull requests the visitor may be interested in, grouped by repositories,
+// from the recently pushed repositories or by the search query.
+// Pull requests are restricted to the ones into q.BaseBranches, or the default branch of each repository.
+// Commits of pull requests are filled if q.ReleaseOnly is true, to be told whether they are release ones.
+func (g githubGateway) GetRecentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+	g, err := g.forHost(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	pullRequests := map[string][]*prchecklist.PullRequest{}
-	for _, edge := range result.Viewer.Repositories.Edges {
-		repo := edge.Node
-		if len(repo.PullRequests.Edges) == 0 {
-			continue
-		}
-		pullRequests[repo.NameWithOwner] = make([]*prchecklist.PullRequest, len(repo.PullRequests.Edges))
-		for i, edge := range repo.PullRequests.Edges {
-			pullReq := edge.Node
-			pullRequests[repo.NameWithOwner][i] = &prchecklist.PullRequest{
-				Title:  pullReq.Title,
-				URL:    pullReq.URL,
-				Number: pullReq.Number,
+	vars := githubRecentPullRequestsVars{After: q.After, WithCommits: q.ReleaseOnly}
+	result := &prchecklist.RecentPullRequests{PullRequests: map[string][]*prchecklist.PullRequest{}}
+
+	add := func(nameWithOwner, defaultBranch string, pr githubRecentPullRequest) {
+		if !recentPullRequestBaseMatches(q.BaseBranches, defaultBranch, pr.BaseRefName) {
+			return
+		}
+		if len(result.PullRequests[nameWithOwner]) >= recentPullRequestsPerRepo {
+			return
+		}
+
+		pullReq := &prchecklist.PullRequest{
+			Title:  pr.Title,
+			URL:    pr.URL,
+			Number: pr.Number,
+		}
+		if q.ReleaseOnly {
+			pullReq.Commits = make([]prchecklist.Commit, len(pr.Commits.Edges))
+			for i, e := range pr.Commits.Edges {
+				pullReq.Commits[i] = prchecklist.Commit{Message: e.Node.Commit.Message}
+			}
+		}
+		result.PullRequests[nameWithOwner] = append(result.PullRequests[nameWithOwner], pullReq)
+	}
+
+	if q.Search != "" {
+		vars.Search = "is:pr " + q.Search
+
+		var qr githubSearchPullRequests
+		if err := g.queryGraphQL(ctx, searchPullRequestsQuery, vars, &qr); err != nil {
+			return nil, err
+		}
+
+		for _, edge := range qr.Search.Edges {
+			node := edge.Node
+			if node.Number == 0 {
+				// not a pull request
+				continue
+			}
+			var defaultBranch string
+			if node.Repository.DefaultBranchRef != nil {
+				defaultBranch = node.Repository.DefaultBranchRef.Name
 			}
+			add(node.Repository.NameWithOwner, defaultBranch, node.githubRecentPullRequest)
 		}
+
+		if qr.Search.PageInfo.HasNextPage {
+			result.NextCursor = qr.Search.PageInfo.EndCursor
+		}
+		return result, nil
+	}
+
+	var qr githubRecentPullRequests
+	if err := g.queryGraphQL(ctx, recentPullRequestsQuery, vars, &qr); err != nil {
+		return nil, err
 	}
 
-	return pullRequests, nil
+	for _, edge := range qr.Viewer.Repositories.Edges {
+		repo := edge.Node
+		var defaultBranch string
+		if repo.DefaultBranchRef != nil {
+			defaultBranch = repo.DefaultBranchRef.Name
+		}
+		for _, edge := range repo.PullRequests.Edges {
+			add(repo.NameWithOwner, defaultBranch, edge.Node)
+		}
+	}
+
+	if qr.Viewer.Repositories.PageInfo.HasNextPage {
+		result.NextCursor = qr.Viewer.Repositories.PageInfo.EndCursor
+	}
+	return result, nil
+}
+
+// recentPullRequestBaseMatches reports whether a pull request into baseRefName is listed,
+// that is, baseRefName is one of baseBranches, or the default branch if baseBranches is empty.
+func recentPullRequestBaseMatches(baseBranches []string, defaultBranch, baseRefName string) bool {
+	if len(baseBranches) == 0 {
+		return baseRefName == defaultBranch
+	}
+
+	for _, b := range baseBranches {
+		if b == baseRefName {
+			return true
+		}
+	}
+	return false
 }
 
 func (g githubGateway) getPullRequest(ctx context.Context, ref prchecklist.ChecklistRef, isBase bool) (*prchecklist.PullRequest, error) {
@@ -360,6 +764,10 @@ func (g githubGateway) getPullRequest(ctx context.Context, ref prchecklist.Check
 		pullReq.User.Login = qr.Repository.PullRequest.Assignees.Edges[0].Node.Login
 	}
 
+	if !isBase {
+		qr.Repository.PullRequest.githubPullRequestDetails.fillPullRequest(pullReq)
+	}
+
 	for _, e := range qr.Repository.PullRequest.HeadRef.Target.Tree.Entries {
 		if e.Name == "prchecklist.yml" && e.Type == "blob" {
 			pullReq.ConfigBlobID = e.Oid
@@ -391,6 +799,9 @@ func (g githubGateway) getPullRequest(ctx context.Context, ref prchecklist.Check
 }
 
 func (g githubGateway) graphqlEndpoint() string {
+	if g.graphqlURL != "" {
+		return g.graphqlURL
+	}
 	if g.domain == "github.com" {
 		return "https://api.github.com/graphql"
 	}
@@ -399,57 +810,125 @@ func (g githubGateway) graphqlEndpoint() string {
 }
 
 func (g githubGateway) queryGraphQL(ctx context.Context, query string, variables interface{}, value interface{}) error {
-	client := prchecklist.ContextClient(ctx)
+	result, err := g.queryGraphQLResult(ctx, query, variables)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("GraphQL error: %v", result.Errors)
+	}
+
+	if len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, value)
+}
+
+// queryGraphQLResult sends the GraphQL query and returns the result as is,
+// failing only if rate limited, so that errors on a part of the query can be handled.
+func (g githubGateway) queryGraphQLResult(ctx context.Context, query string, variables interface{}) (*graphQLResult, error) {
+	client := g.wrapRateLimitClient(prchecklist.ContextClient(ctx))
 
 	varBytes, err := json.Marshal(variables)
+	if err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
 	err = json.NewEncoder(&buf).Encode(map[string]string{"query": query, "variables": string(varBytes)})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", g.graphqlEndpoint(), &buf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	result := graphQLResult{
-		Data: value,
-	}
+	var result graphQLResult
 
 	defer resp.Body.Close()
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(result.Errors) > 0 {
-		return fmt.Errorf("GraphQL error: %v", result.Errors)
+	var rl struct {
+		RateLimit *githubRateLimit
+	}
+	if len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, &rl); err == nil && rl.RateLimit != nil && rl.RateLimit.Limit > 0 {
+			if float64(rl.RateLimit.Remaining) < float64(rl.RateLimit.Limit)*rateLimitLowRatio {
+				log.Printf("GitHub GraphQL rate limit is low: %d/%d remaining until %s (cost %d)", rl.RateLimit.Remaining, rl.RateLimit.Limit, rl.RateLimit.ResetAt, rl.RateLimit.Cost)
+			}
+		}
 	}
 
-	return nil
+	for _, e := range result.Errors {
+		if e.Type == "RATE_LIMITED" {
+			until := time.Now().Add(time.Minute)
+			if rl.RateLimit != nil {
+				if resetAt, err := time.Parse(time.RFC3339, rl.RateLimit.ResetAt); err == nil {
+					until = resetAt
+				}
+			}
+			return nil, &prchecklist.RateLimitedError{Until: until}
+		}
+	}
+
+	return &result, nil
+}
+
+// Hosts returns the domains of the additional GitHub hosts, which prefix the paths of their checklists.
+func (g githubGateway) Hosts() []string {
+	hosts := make([]string, 0, len(g.hosts))
+	for host := range g.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
 }
 
-func (g githubGateway) AuthCodeURL(state string, redirectURI *url.URL) string {
+func (g githubGateway) AuthCodeURL(ctx context.Context, state string, redirectURI *url.URL) (string, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if g.cassette != nil && g.cassette.replay {
+		return g.cassette.authCodeURL(state, redirectURI)
+	}
+
 	opts := []oauth2.AuthCodeOption{}
 	if redirectURI != nil {
 		opts = append(opts, oauth2.SetAuthURLParam("redirect_uri", redirectURI.String()))
 	}
-	return g.oauth2Config.AuthCodeURL(state, opts...)
+	return g.oauth2Config.AuthCodeURL(state, opts...), nil
+}
+
+func (g githubGateway) apiBaseURL() string {
+	if g.apiURL != "" {
+		return g.apiURL
+	}
+	if g.domain == "github.com" {
+		return "https://api.github.com/"
+	}
+
+	return "https://" + g.domain + "/api/v3/"
 }
 
 func (g githubGateway) newGitHubClient(base *http.Client) (*github.Client, error) {
-	client := github.NewClient(base)
-	if g.domain != "github.com" {
+	client := github.NewClient(g.wrapRateLimitClient(base))
+	if g.domain != "github.com" || g.apiURL != "" {
 		var err error
 		// TODO(motemen): parsing url can be done earlier
-		client.BaseURL, err = url.Parse("https://" + g.domain + "/api/v3/")
+		client.BaseURL, err = url.Parse(g.apiBaseURL())
 		if err != nil {
 			return nil, err
 		}
@@ -457,8 +936,23 @@ func (g githubGateway) newGitHubClient(base *http.Client) (*github.Client, error
 	return client, nil
 }
 
+// newWriterGitHubClient creates a client to write to the repository,
+// which acts as the app if authenticating as a GitHub App, or as the visitor otherwise.
+func (g githubGateway) newWriterGitHubClient(ctx context.Context, owner, repo string) (*github.Client, error) {
+	if g.app != nil {
+		return g.newGitHubClient(g.app.HTTPClient(ctx, owner, repo))
+	}
+
+	return g.newGitHubClient(prchecklist.ContextClient(ctx))
+}
+
 func (g githubGateway) AuthenticateUser(ctx context.Context, code string) (*prchecklist.GitHubUser, error) {
-	token, err := g.oauth2Config.Exchange(ctx, code)
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := g.oauth2Config.Exchange(g.oauth2Context(ctx), code)
 	if err != nil {
 		return nil, err
 	}
@@ -466,7 +960,45 @@ func (g githubGateway) AuthenticateUser(ctx context.Context, code string) (*prch
 	return g.GetUserFromToken(ctx, token)
 }
 
+// RefreshToken obtains a new token of the user by the refresh token of token,
+// which GitHub issues along with expiring user tokens of GitHub Apps.
+// Returns prchecklist.ErrNotAuthed if the token cannot be refreshed.
+func (g githubGateway) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshToken(g.oauth2Context(ctx), g.oauth2Config, token)
+}
+
+// refreshToken obtains a new token by the refresh token of token from the token endpoint of config.
+func refreshToken(ctx context.Context, config *oauth2.Config, token *oauth2.Token) (*oauth2.Token, error) {
+	if token == nil || token.RefreshToken == "" {
+		return nil, prchecklist.ErrNotAuthed
+	}
+
+	// Without an access token the source always refreshes
+	newToken, err := config.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, errors.Wrap(err, "refreshToken")
+		}
+		// The refresh token is rejected, which may also have been expired or revoked
+		log.Printf("refreshToken: %s", err)
+		return nil, prchecklist.ErrNotAuthed
+	}
+
+	return newToken, nil
+}
+
 func (g githubGateway) GetUserFromToken(ctx context.Context, token *oauth2.Token) (*prchecklist.GitHubUser, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := g.newGitHubClient(
 		oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)),
 	)
@@ -483,20 +1015,27 @@ func (g githubGateway) GetUserFromToken(ctx context.Context, token *oauth2.Token
 		ID:        int(u.GetID()),
 		Login:     u.GetLogin(),
 		AvatarURL: u.GetAvatarURL(),
+		Host:      g.host,
 		Token:     token,
 	}, nil
 }
 
-func (g githubGateway) SetRepositoryStatusAs(ctx context.Context, owner, repo, ref, contextName, state, targetURL string) error {
-	gh, err := g.newGitHubClient(prchecklist.ContextClient(ctx))
+func (g githubGateway) SetRepositoryStatusAs(ctx context.Context, owner, repo, ref, contextName, state, description, targetURL string) error {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return err
+	}
+
+	gh, err := g.newWriterGitHubClient(ctx, owner, repo)
 	if err != nil {
 		return err
 	}
 
 	status := &github.RepoStatus{
-		State:     &state,
-		Context:   &contextName,
-		TargetURL: &targetURL,
+		State:       &state,
+		Context:     &contextName,
+		Description: &description,
+		TargetURL:   &targetURL,
 	}
 	if _, _, err = gh.Repositories.CreateStatus(ctx, owner, repo, ref, status); err != nil {
 		return err
@@ -504,3 +1043,144 @@ func (g githubGateway) SetRepositoryStatusAs(ctx context.Context, owner, repo, r
 
 	return nil
 }
+
+// PutIssueComment edits the comment specified by commentID on the issue or pull request,
+// or creates a new one if commentID is 0 or the comment has been deleted.
+// Returns the ID of the comment.
+func (g githubGateway) PutIssueComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (int64, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	gh, err := g.newWriterGitHubClient(ctx, owner, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	comment := &github.IssueComment{Body: &body}
+
+	if commentID != 0 {
+		c, _, err := gh.Issues.EditComment(ctx, owner, repo, commentID, comment)
+		if err == nil {
+			return c.GetID(), nil
+		}
+		if errResp, ok := err.(*github.ErrorResponse); !ok || errResp.Response.StatusCode != http.StatusNotFound {
+			return 0, errors.Wrap(err, "EditComment")
+		}
+	}
+
+	c, _, err := gh.Issues.CreateComment(ctx, owner, repo, number, comment)
+	if err != nil {
+		return 0, errors.Wrap(err, "CreateComment")
+	}
+
+	return c.GetID(), nil
+}
+
+// CreateCheckRun creates a check run, which supersedes the former ones of the same name on the commit.
+// Check runs can only be created by GitHub Apps.
+func (g githubGateway) CreateCheckRun(ctx context.Context, owner, repo string, run prchecklist.CheckRun) error {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return err
+	}
+
+	gh, err := g.newWriterGitHubClient(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	opts := github.CreateCheckRunOptions{
+		Name:       run.Name,
+		HeadSHA:    run.HeadSHA,
+		DetailsURL: &run.DetailsURL,
+		Output: &github.CheckRunOutput{
+			Title:   &run.Title,
+			Summary: &run.Summary,
+			Text:    &run.Text,
+		},
+	}
+	if run.Conclusion == "" {
+		opts.Status = github.String("in_progress")
+	} else {
+		opts.Status = github.String("completed")
+		opts.Conclusion = &run.Conclusion
+		opts.CompletedAt = &github.Timestamp{Time: time.Now()}
+	}
+
+	_, _, err = gh.Checks.CreateCheckRun(ctx, owner, repo, opts)
+	return errors.Wrap(err, "CreateCheckRun")
+}
+
+// CanCreateCheckRun reports whether check runs can be created on the host,
+// that is, prchecklist authenticates as a GitHub App.
+func (g githubGateway) CanCreateCheckRun(ctx context.Context) bool {
+	g, err := g.forHost(ctx)
+	return err == nil && g.app != nil
+}
+
+// ServiceHTTPClient returns an *http.Client to access the repository
+// on behalf of prchecklist itself rather than visitors, such as on webhooks.
+// It is the installation of the GitHub App if authenticating as an app.
+func (g githubGateway) ServiceHTTPClient(ctx context.Context, owner, repo string) (*http.Client, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.app != nil {
+		return g.app.HTTPClient(ctx, owner, repo), nil
+	}
+
+	if g.token == "" {
+		if g.host != "" {
+			return nil, errors.Errorf("gateway/github: token of %s must be set to access GitHub without visitors", g.host)
+		}
+		return nil, errors.New("gateway/github: either PRCHECKLIST_GITHUB_APP_ID or PRCHECKLIST_GITHUB_TOKEN must be set to access GitHub without visitors")
+	}
+
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: g.token})), nil
+}
+
+// InvalidatePullRequest removes the cached data of the pull request.
+func (g githubGateway) InvalidatePullRequest(ctx context.Context, owner, repo string, number int) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		log.Printf("InvalidatePullRequest: %s", err)
+		return
+	}
+
+	for _, isBase := range []bool{true, false} {
+		if err := g.cache.Delete(pullRequestCacheKey(owner, repo, number, isBase)); err != nil {
+			log.Printf("cache: %s", err)
+		}
+	}
+}
+
+// GetPullRequestNumbersByHead lists the numbers of the open pull requests whose head is the branch.
+func (g githubGateway) GetPullRequestNumbersByHead(ctx context.Context, owner, repo, branch string) ([]int, error) {
+	g, err := g.forHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gh, err := g.newGitHubClient(prchecklist.ContextClient(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	pullReqs, _, err := gh.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + branch,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "PullRequests.List")
+	}
+
+	numbers := make([]int, len(pullReqs))
+	for i, pullReq := range pullReqs {
+		numbers[i] = pullReq.GetNumber()
+	}
+	return numbers, nil
+}
@@ -34,3 +34,15 @@ func TestGitHub_GetPullRequest(t *testing.T) {
 	}, true)
 	assert.NoError(t, err)
 }
+
+func TestGithubRollupStatus(t *testing.T) {
+	for state, expected := range map[string]string{
+		"SUCCESS":  "success",
+		"FAILURE":  "failure",
+		"ERROR":    "error",
+		"PENDING":  "pending",
+		"EXPECTED": "pending",
+	} {
+		assert.Equal(t, expected, githubRollupStatus(state), state)
+	}
+}
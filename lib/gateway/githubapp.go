@@ -0,0 +1,239 @@
+package gateway
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v31/github"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// githubAppJWTLifetime is how long JWTs to authenticate as the app are valid.
+	// GitHub accepts 10 minutes at most.
+	githubAppJWTLifetime = 9 * time.Minute
+	// githubAppTokenLeeway is how long before expiry installation tokens are renewed.
+	githubAppTokenLeeway = time.Minute
+)
+
+// githubApp authenticates as a GitHub App and issues installation tokens,
+// with which repositories can be accessed without users' tokens.
+// https://docs.github.com/en/developers/apps/authenticating-with-github-apps
+type githubApp struct {
+	id      int64
+	key     *rsa.PrivateKey
+	baseURL *url.URL
+	// transport is set to trust a custom CA
+	transport http.RoundTripper
+
+	// mu guards the maps below, and is not held during requests to GitHub
+	mu sync.Mutex
+	// "owner/repo" -> installation ID
+	installations map[string]int64
+	// installation ID -> token
+	tokens map[int64]*oauth2.Token
+
+	// requests merges concurrent requests for the same installation
+	requests singleflight.Group
+}
+
+func newGitHubApp(id int64, key *rsa.PrivateKey, baseURL *url.URL) *githubApp {
+	return &githubApp{
+		id:            id,
+		key:           key,
+		baseURL:       baseURL,
+		installations: map[string]int64{},
+		tokens:        map[int64]*oauth2.Token{},
+	}
+}
+
+// parseGitHubAppPrivateKey parses a PEM-encoded RSA private key,
+// given either as its content or as a path to the file.
+func parseGitHubAppPrivateKey(keyOrPath string) (*rsa.PrivateKey, error) {
+	data := []byte(keyOrPath)
+	if !strings.HasPrefix(strings.TrimSpace(keyOrPath), "-----BEGIN") {
+		var err error
+		data, err = ioutil.ReadFile(keyOrPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// jwt builds a JWT signed with RS256 to authenticate as the app.
+func (a *githubApp) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		// Allow clock drifts
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": fmt.Sprint(a.id),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return message + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// appClient returns a GitHub API client authenticated as the app itself.
+func (a *githubApp) appClient(ctx context.Context) (*github.Client, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt")
+	}
+
+	if a.transport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: a.transport})
+	}
+
+	gh := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt, TokenType: "Bearer"})))
+	gh.BaseURL = a.baseURL
+	return gh, nil
+}
+
+// installationToken returns a token of the installation of the app on the repository,
+// which is cached until shortly before it expires.
+func (a *githubApp) installationToken(ctx context.Context, owner, repo string) (*oauth2.Token, error) {
+	repoKey := owner + "/" + repo
+
+	installationID, token, ok := a.cached(repoKey)
+	if token != nil && time.Now().Add(githubAppTokenLeeway).Before(token.Expiry) {
+		return token, nil
+	}
+
+	if !ok {
+		v, err, _ := a.requests.Do("installation:"+repoKey, func() (interface{}, error) {
+			gh, err := a.appClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			installation, _, err := gh.Apps.FindRepositoryInstallation(ctx, owner, repo)
+			if err != nil {
+				return nil, errors.Wrapf(err, "FindRepositoryInstallation(%s)", repoKey)
+			}
+
+			a.mu.Lock()
+			a.installations[repoKey] = installation.GetID()
+			a.mu.Unlock()
+
+			return installation.GetID(), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		installationID = v.(int64)
+	}
+
+	v, err, _ := a.requests.Do(fmt.Sprintf("token:%d", installationID), func() (interface{}, error) {
+		// Another request may have renewed it meanwhile
+		a.mu.Lock()
+		token := a.tokens[installationID]
+		a.mu.Unlock()
+		if token != nil && time.Now().Add(githubAppTokenLeeway).Before(token.Expiry) {
+			return token, nil
+		}
+
+		gh, err := a.appClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		it, _, err := gh.Apps.CreateInstallationToken(ctx, installationID, nil)
+		if err != nil {
+			if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == http.StatusNotFound {
+				// The app may have been reinstalled
+				a.mu.Lock()
+				delete(a.installations, repoKey)
+				a.mu.Unlock()
+			}
+			return nil, errors.Wrap(err, "CreateInstallationToken")
+		}
+
+		token = &oauth2.Token{AccessToken: it.GetToken(), TokenType: "token", Expiry: it.GetExpiresAt()}
+
+		a.mu.Lock()
+		a.tokens[installationID] = token
+		a.mu.Unlock()
+
+		return token, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*oauth2.Token), nil
+}
+
+// cached returns the installation ID for the repository and its token, if known.
+func (a *githubApp) cached(repoKey string) (int64, *oauth2.Token, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	installationID, ok := a.installations[repoKey]
+	if !ok {
+		return 0, nil, false
+	}
+	return installationID, a.tokens[installationID], true
+}
+
+// HTTPClient returns an *http.Client which accesses the repository as the installation of the app.
+func (a *githubApp) HTTPClient(ctx context.Context, owner, repo string) *http.Client {
+	return oauth2.NewClient(ctx, githubAppTokenSource{ctx: ctx, app: a, owner: owner, repo: repo})
+}
+
+type githubAppTokenSource struct {
+	ctx         context.Context
+	app         *githubApp
+	owner, repo string
+}
+
+func (s githubAppTokenSource) Token() (*oauth2.Token, error) {
+	return s.app.installationToken(s.ctx, s.owner, s.repo)
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// githubHostConfig configures an additional GitHub host, listed in the file given by -github-hosts.
+type githubHostConfig struct {
+	Domain       string `yaml:"domain"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// APIURL is the base URL of REST API, "https://<domain>/api/v3/" by default
+	APIURL string `yaml:"api_url"`
+	// GraphQLURL is the endpoint of GraphQL API, "https://<domain>/api/graphql" by default
+	GraphQLURL string `yaml:"graphql_url"`
+	// CAFile is the path to PEM-encoded certificates trusted in addition to the system ones
+	CAFile string `yaml:"ca_file"`
+	// Token is used without visitors, such as on webhooks
+	Token string `yaml:"token"`
+}
+
+// loadGitHubHostConfigs reads the YAML file listing additional GitHub hosts.
+func loadGitHubHostConfigs(path string) ([]githubHostConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []githubHostConfig
+	if err := yaml.UnmarshalStrict(b, &configs); err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// newGitHubHost creates the gateway to an additional GitHub host,
+// which shares the cache with the default host with its keys separated.
+func (g githubGateway) newGitHubHost(c githubHostConfig) (*githubGateway, error) {
+	// Domains appear as the first segments of checklist paths, where owners never have dots
+	if !strings.Contains(c.Domain, ".") || strings.ContainsAny(c.Domain, "/:") {
+		return nil, errors.Errorf("invalid domain: %q", c.Domain)
+	}
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return nil, errors.Errorf("%s: both client_id and client_secret must be set", c.Domain)
+	}
+
+	h := &githubGateway{
+		host:       c.Domain,
+		domain:     c.Domain,
+		apiURL:     c.APIURL,
+		graphqlURL: c.GraphQLURL,
+		token:      c.Token,
+		cache:      prefixedCache{Cache: g.cache, prefix: "host\000" + c.Domain + "\000"},
+		rateLimits: g.rateLimits,
+		oauth2Config: &oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://" + c.Domain + "/login/oauth/authorize",
+				TokenURL: "https://" + c.Domain + "/login/oauth/access_token",
+			},
+			Scopes: []string{"repo"},
+		},
+	}
+
+	if h.apiURL != "" && !strings.HasSuffix(h.apiURL, "/") {
+		h.apiURL += "/"
+	}
+	if _, err := url.Parse(h.apiBaseURL()); err != nil {
+		return nil, errors.Wrapf(err, "%s: api_url", c.Domain)
+	}
+
+	if c.CAFile != "" {
+		var err error
+		h.transport, err = newGitHubTransport(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: ca_file", c.Domain)
+		}
+	}
+
+	return h, nil
+}
+
+// newGitHubTransport creates an http.RoundTripper trusting the certificates in caFile
+// in addition to the system ones, for GitHub Enterprise with a private CA.
+func newGitHubTransport(caFile string) (http.RoundTripper, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no certificates found in %s", caFile)
+	}
+
+	// Same as http.DefaultTransport except TLSClientConfig
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       &tls.Config{RootCAs: pool},
+	}, nil
+}
+
+// forHost returns the gateway to the GitHub host of ctx given by prchecklist.ContextWithHost.
+func (g githubGateway) forHost(ctx context.Context) (githubGateway, error) {
+	host := prchecklist.ContextHost(ctx)
+	if host == g.host {
+		return g, nil
+	}
+	if h, ok := g.hosts[host]; ok {
+		return *h, nil
+	}
+	return g, errors.Errorf("gateway/github: unknown GitHub host: %q", host)
+}
+
+// oauth2Context makes OAuth requests with ctx go through the transport of the host.
+func (g githubGateway) oauth2Context(ctx context.Context) context.Context {
+	if g.transport == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: g.transport})
+}
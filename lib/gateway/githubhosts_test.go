@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func TestLoadGitHubHostConfigs(t *testing.T) {
+	f, err := ioutil.TempFile("", "prchecklist-github-hosts")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+- domain: ghe.example.com
+  client_id: id
+  client_secret: secret
+  ca_file: /etc/ssl/ghe.pem
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	configs, err := loadGitHubHostConfigs(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []githubHostConfig{
+		{Domain: "ghe.example.com", ClientID: "id", ClientSecret: "secret", CAFile: "/etc/ssl/ghe.pem"},
+	}, configs)
+}
+
+func TestGitHub_newGitHubHost(t *testing.T) {
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+
+	for _, domain := range []string{"ghe", "ghe.example.com/", "ghe.example.com:8443", ""} {
+		_, err := g.newGitHubHost(githubHostConfig{Domain: domain, ClientID: "id", ClientSecret: "secret"})
+		assert.Error(t, err, domain)
+	}
+
+	_, err := g.newGitHubHost(githubHostConfig{Domain: "ghe.example.com"})
+	assert.Error(t, err, "client_id missing")
+
+	h, err := g.newGitHubHost(githubHostConfig{
+		Domain:       "ghe.example.com",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		APIURL:       "https://ghe-api.example.com/v3",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghe-api.example.com/v3/", h.apiBaseURL())
+	assert.Equal(t, "https://ghe.example.com/api/graphql", h.graphqlEndpoint())
+	assert.Equal(t, "https://ghe.example.com/login/oauth/authorize", h.oauth2Config.Endpoint.AuthURL)
+
+	// caches do not collide between hosts
+	require.NoError(t, h.cache.Set("key", []byte("ghe"), 0))
+	_, ok, err := g.cache.Get("key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGitHub_forHost(t *testing.T) {
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+	h, err := g.newGitHubHost(githubHostConfig{Domain: "ghe.example.com", ClientID: "id", ClientSecret: "secret"})
+	require.NoError(t, err)
+	g.hosts = map[string]*githubGateway{"ghe.example.com": h}
+
+	got, err := g.forHost(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "", got.host)
+
+	got, err = g.forHost(prchecklist.ContextWithHost(context.Background(), "ghe.example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "ghe.example.com", got.host)
+
+	_, err = g.forHost(prchecklist.ContextWithHost(context.Background(), "unknown.example.com"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,648 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+var (
+	gitlabURL          string
+	gitlabClientID     string
+	gitlabClientSecret string
+	gitlabToken        string
+)
+
+func init() {
+	flag.StringVar(&gitlabURL, "gitlab-url", getenv("GITLAB_URL", "https://gitlab.com"), "GitLab URL (GITLAB_URL)")
+	flag.StringVar(&gitlabClientID, "gitlab-client-id", os.Getenv("GITLAB_CLIENT_ID"), "GitLab application ID (GITLAB_CLIENT_ID)")
+	flag.StringVar(&gitlabClientSecret, "gitlab-client-secret", os.Getenv("GITLAB_CLIENT_SECRET"), "GitLab application secret (GITLAB_CLIENT_SECRET)")
+	flag.StringVar(&gitlabToken, "gitlab-token", os.Getenv("PRCHECKLIST_GITLAB_TOKEN"), "GitLab token used without visitors, such as on webhooks (PRCHECKLIST_GITLAB_TOKEN)")
+}
+
+const (
+	// gitlabPerPage is the maximum page size of GitLab API.
+	gitlabPerPage = 100
+	// gitlabRecentProjectsPerPage is how many projects are listed on a page of the top page.
+	gitlabRecentProjectsPerPage = 10
+)
+
+// NewGitLab creates a new GitLab gateway, which treats merge requests as pull requests.
+func NewGitLab() (*gitlabGateway, error) {
+	if gitlabClientID == "" || gitlabClientSecret == "" {
+		return nil, errors.New("gateway/gitlab: both GITLAB_CLIENT_ID and GITLAB_CLIENT_SECRET must be set")
+	}
+
+	c, err := NewCache(githubCache)
+	if err != nil {
+		return nil, errors.Wrap(err, "gateway/gitlab: PRCHECKLIST_GITHUB_CACHE")
+	}
+
+	baseURL := strings.TrimSuffix(gitlabURL, "/")
+
+	return &gitlabGateway{
+		cache:   c,
+		baseURL: baseURL,
+		oauth2Config: &oauth2.Config{
+			ClientID:     gitlabClientID,
+			ClientSecret: gitlabClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  baseURL + "/oauth/authorize",
+				TokenURL: baseURL + "/oauth/token",
+			},
+			// To set commit statuses and post comments
+			Scopes: []string{"api"},
+		},
+	}, nil
+}
+
+// gitlabGateway implements the gateways of GitHub on GitLab.
+// Owners of the checklists are the namespaces of the projects, which may contain slashes,
+// and numbers are the IIDs of merge requests.
+// https://docs.gitlab.com/ee/api/
+type gitlabGateway struct {
+	cache        Cache
+	oauth2Config *oauth2.Config
+	// baseURL is the URL of GitLab without the trailing slash, like "https://gitlab.com"
+	baseURL string
+}
+
+type gitlabUser struct {
+	ID        int
+	Username  string
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitlabProject struct {
+	ID                int
+	PathWithNamespace string `json:"path_with_namespace"`
+	Visibility        string
+	DefaultBranch     string `json:"default_branch"`
+}
+
+type gitlabMergeRequest struct {
+	IID          int `json:"iid"`
+	ProjectID    int `json:"project_id"`
+	Title        string
+	Description  string
+	WebURL       string `json:"web_url"`
+	TargetBranch string `json:"target_branch"`
+	SourceBranch string `json:"source_branch"`
+	SHA          string
+	Author       gitlabUser
+	Assignees    []gitlabUser
+	Reviewers    []gitlabUser
+	Labels       []string
+	MergedAt     *time.Time  `json:"merged_at"`
+	MergedBy     *gitlabUser `json:"merged_by"`
+	// ChangesCount is like "42", or "1000+" if too many
+	ChangesCount string `json:"changes_count"`
+	// HeadPipeline is only returned for a single merge request
+	HeadPipeline *struct {
+		Status string
+	} `json:"head_pipeline"`
+	References struct {
+		Full string
+	}
+}
+
+type gitlabCommit struct {
+	ID      string
+	Message string
+}
+
+// gitlabError is an error response of GitLab API.
+type gitlabError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *gitlabError) Error() string {
+	return fmt.Sprintf("GitLab API: %d %s", e.StatusCode, e.Message)
+}
+
+// Is makes 401 errors match prchecklist.ErrNotAuthed.
+func (e *gitlabError) Is(target error) bool {
+	return target == prchecklist.ErrNotAuthed && e.StatusCode == http.StatusUnauthorized
+}
+
+func isGitLabNotFound(err error) bool {
+	var e *gitlabError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// gitlabProjectPath is the URL-encoded path of the project, with which API refers to it.
+func gitlabProjectPath(owner, repo string) string {
+	return "projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+// request calls GitLab API at path relative to "/api/v4/" by client, sending body as JSON if not nil,
+// and decodes the response into v if not nil.
+func (g gitlabGateway) request(ctx context.Context, client *http.Client, method, path string, body, v interface{}) (http.Header, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, g.baseURL+"/api/v4/"+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		var e struct {
+			Message interface{}
+			Error   string
+		}
+		msg := resp.Status
+		if json.Unmarshal(b, &e) == nil {
+			if e.Message != nil {
+				msg = fmt.Sprint(e.Message)
+			} else if e.Error != "" {
+				msg = e.Error
+			}
+		}
+		return nil, &gitlabError{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	if v == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (g gitlabGateway) get(ctx context.Context, path string, v interface{}) (http.Header, error) {
+	return g.request(ctx, prchecklist.ContextClient(ctx), "GET", path, nil, v)
+}
+
+func (g gitlabGateway) GetBlob(ctx context.Context, ref prchecklist.ChecklistRef, sha string) ([]byte, error) {
+	cacheKey := fmt.Sprintf("blob\000%s/%s\000%s", ref.Owner, ref.Repo, sha)
+
+	if blob, ok, err := g.cache.Get(cacheKey); err != nil {
+		log.Printf("cache: %s", err)
+	} else if ok {
+		return blob, nil
+	}
+
+	req, err := http.NewRequest("GET", g.baseURL+"/api/v4/"+gitlabProjectPath(ref.Owner, ref.Repo)+"/repository/blobs/"+url.PathEscape(sha)+"/raw", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := prchecklist.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &gitlabError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+
+	blob, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.cache.Set(cacheKey, blob, cacheDurationBlob); err != nil {
+		log.Printf("cache: %s", err)
+	}
+
+	return blob, nil
+}
+
+// GetPullRequest retrieves the merge request pointed by ref.
+// Merge requests are not cached, as it would require to check visitors' access to the projects.
+func (g gitlabGateway) GetPullRequest(ctx context.Context, ref prchecklist.ChecklistRef, isBase bool) (*prchecklist.PullRequest, context.Context, error) {
+	projectPath := gitlabProjectPath(ref.Owner, ref.Repo)
+
+	var project gitlabProject
+	if _, err := g.get(ctx, projectPath, &project); err != nil {
+		return nil, ctx, errors.Wrap(err, "get project")
+	}
+
+	var mr gitlabMergeRequest
+	if _, err := g.get(ctx, fmt.Sprintf("%s/merge_requests/%d", projectPath, ref.Number), &mr); err != nil {
+		return nil, ctx, errors.Wrap(err, "get merge request")
+	}
+
+	pullReq := g.toPullRequest(ref.Owner, ref.Repo, mr)
+	pullReq.IsPrivate = project.Visibility != "public"
+
+	if !isBase {
+		return pullReq, ctx, nil
+	}
+
+	commits, err := g.getMergeRequestCommits(ctx, projectPath, ref.Number, 0)
+	if err != nil {
+		return nil, ctx, err
+	}
+	pullReq.Commits = commits
+
+	var file struct {
+		BlobID string `json:"blob_id"`
+	}
+	_, err = g.get(ctx, fmt.Sprintf("%s/repository/files/prchecklist.yml?ref=%s", projectPath, url.QueryEscape(mr.SHA)), &file)
+	if err == nil {
+		pullReq.ConfigBlobID = file.BlobID
+	} else if !isGitLabNotFound(err) {
+		return nil, ctx, errors.Wrap(err, "get prchecklist.yml")
+	}
+
+	return pullReq, ctx, nil
+}
+
+// getMergeRequestCommits retrieves the commits of the merge request from the oldest,
+// up to limit ones if it is positive.
+func (g gitlabGateway) getMergeRequestCommits(ctx context.Context, projectPath string, iid int, limit int) ([]prchecklist.Commit, error) {
+	var commits []prchecklist.Commit
+	for page := "1"; page != ""; {
+		var cs []gitlabCommit
+		header, err := g.get(ctx, fmt.Sprintf("%s/merge_requests/%d/commits?per_page=%d&page=%s", projectPath, iid, gitlabPerPage, page), &cs)
+		if err != nil {
+			return nil, errors.Wrap(err, "get merge request commits")
+		}
+		for _, c := range cs {
+			commits = append(commits, prchecklist.Commit{Message: c.Message, Oid: c.ID})
+		}
+		if limit > 0 && len(commits) >= limit {
+			break
+		}
+		page = header.Get("X-Next-Page")
+	}
+
+	// GitLab lists commits from the newest
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	if commits == nil {
+		commits = []prchecklist.Commit{}
+	}
+	return commits, nil
+}
+
+// toPullRequest converts mr into a PullRequest, filling the details of feature pull requests.
+func (g gitlabGateway) toPullRequest(owner, repo string, mr gitlabMergeRequest) *prchecklist.PullRequest {
+	pullReq := &prchecklist.PullRequest{
+		URL:      mr.WebURL,
+		Title:    mr.Title,
+		Body:     mr.Description,
+		Owner:    owner,
+		Repo:     repo,
+		Number:   mr.IID,
+		User:     prchecklist.GitHubUserSimple{Login: mr.Author.Username},
+		Provider: prchecklist.ProviderGitLab,
+	}
+
+	// prefer assignee
+	if len(mr.Assignees) > 0 {
+		pullReq.User.Login = mr.Assignees[0].Username
+	}
+
+	pullReq.Labels = mr.Labels
+	if pullReq.Labels == nil {
+		pullReq.Labels = []string{}
+	}
+	pullReq.MergedAt = mr.MergedAt
+	if mr.MergedBy != nil {
+		pullReq.MergedBy = &prchecklist.GitHubUserSimple{Login: mr.MergedBy.Username}
+	}
+	pullReq.RequestedReviewers = make([]prchecklist.GitHubUserSimple, len(mr.Reviewers))
+	for i, u := range mr.Reviewers {
+		pullReq.RequestedReviewers[i] = prchecklist.GitHubUserSimple{Login: u.Username}
+	}
+	// Approvals require another request for each merge request
+	pullReq.ApprovedBy = []prchecklist.GitHubUserSimple{}
+	pullReq.ChangedFiles, _ = strconv.Atoi(mr.ChangesCount)
+	if mr.HeadPipeline != nil {
+		pullReq.CIStatus = gitlabPipelineStatus(mr.HeadPipeline.Status)
+	}
+
+	return pullReq
+}
+
+// gitlabPipelineStatus maps the status of a GitLab pipeline to the one of GitHub commit statuses.
+func gitlabPipelineStatus(status string) string {
+	switch status {
+	case "success":
+		return "success"
+	case "failed":
+		return "failure"
+	case "canceled", "skipped":
+		return "error"
+	case "":
+		return ""
+	default:
+		return "pending"
+	}
+}
+
+// GetFeaturePullRequests retrieves the merge requests of the numbers in the project,
+// up to gitlabPerPage ones by a request.
+func (g gitlabGateway) GetFeaturePullRequests(ctx context.Context, owner, repo string, numbers []int) (map[int]*prchecklist.PullRequest, error) {
+	projectPath := gitlabProjectPath(owner, repo)
+	result := make(map[int]*prchecklist.PullRequest, len(numbers))
+
+	for start := 0; start < len(numbers); start += gitlabPerPage {
+		end := start + gitlabPerPage
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+
+		q := url.Values{"per_page": {strconv.Itoa(gitlabPerPage)}, "state": {"all"}}
+		for _, n := range numbers[start:end] {
+			q.Add("iids[]", strconv.Itoa(n))
+		}
+
+		var mrs []gitlabMergeRequest
+		if _, err := g.get(ctx, projectPath+"/merge_requests?"+q.Encode(), &mrs); err != nil {
+			return nil, errors.Wrap(err, "list merge requests")
+		}
+		for _, mr := range mrs {
+			result[mr.IID] = g.toPullRequest(owner, repo, mr)
+		}
+	}
+
+	return result, nil
+}
+
+// GetRecentPullRequests lists merge requests the visitor may be interested in, grouped by projects,
+// from the recently active projects the visitor is a member of or by the search query.
+// Cursors are page numbers.
+func (g gitlabGateway) GetRecentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+	page := q.After
+	if page == "" {
+		page = "1"
+	}
+
+	if q.Search != "" {
+		return g.searchRecentPullRequests(ctx, q, page)
+	}
+
+	var projects []gitlabProject
+	header, err := g.get(ctx, fmt.Sprintf("projects?membership=true&archived=false&order_by=last_activity_at&per_page=%d&page=%s", gitlabRecentProjectsPerPage, url.QueryEscape(page)), &projects)
+	if err != nil {
+		return nil, errors.Wrap(err, "list projects")
+	}
+
+	pullReqs := make([][]*prchecklist.PullRequest, len(projects))
+	eg, ctx := errgroup.WithContext(ctx)
+	for i, project := range projects {
+		i, project := i, project
+		eg.Go(func() error {
+			var mrs []gitlabMergeRequest
+			_, err := g.get(ctx, fmt.Sprintf("projects/%d/merge_requests?state=opened&order_by=updated_at&per_page=20", project.ID), &mrs)
+			if err != nil {
+				return errors.Wrapf(err, "list merge requests of %s", project.PathWithNamespace)
+			}
+			pullReqs[i], err = g.recentPullRequests(ctx, q, project, mrs)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := &prchecklist.RecentPullRequests{
+		PullRequests: map[string][]*prchecklist.PullRequest{},
+		NextCursor:   header.Get("X-Next-Page"),
+	}
+	for i, project := range projects {
+		if len(pullReqs[i]) > 0 {
+			result.PullRequests[project.PathWithNamespace] = pullReqs[i]
+		}
+	}
+	return result, nil
+}
+
+func (g gitlabGateway) searchRecentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery, page string) (*prchecklist.RecentPullRequests, error) {
+	var mrs []gitlabMergeRequest
+	header, err := g.get(ctx, fmt.Sprintf("merge_requests?scope=all&state=opened&per_page=30&search=%s&page=%s", url.QueryEscape(q.Search), url.QueryEscape(page)), &mrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "search merge requests")
+	}
+
+	result := &prchecklist.RecentPullRequests{
+		PullRequests: map[string][]*prchecklist.PullRequest{},
+		NextCursor:   header.Get("X-Next-Page"),
+	}
+
+	byProject := map[int][]gitlabMergeRequest{}
+	var projectIDs []int
+	for _, mr := range mrs {
+		if _, ok := byProject[mr.ProjectID]; !ok {
+			projectIDs = append(projectIDs, mr.ProjectID)
+		}
+		byProject[mr.ProjectID] = append(byProject[mr.ProjectID], mr)
+	}
+
+	for _, id := range projectIDs {
+		var project gitlabProject
+		if _, err := g.get(ctx, fmt.Sprintf("projects/%d", id), &project); err != nil {
+			return nil, errors.Wrap(err, "get project")
+		}
+
+		pullReqs, err := g.recentPullRequests(ctx, q, project, byProject[id])
+		if err != nil {
+			return nil, err
+		}
+		if len(pullReqs) > 0 {
+			result.PullRequests[project.PathWithNamespace] = pullReqs
+		}
+	}
+
+	return result, nil
+}
+
+// recentPullRequests converts mrs of the project into the ones listed on the top page,
+// filling commits if q.ReleaseOnly is true.
+func (g gitlabGateway) recentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery, project gitlabProject, mrs []gitlabMergeRequest) ([]*prchecklist.PullRequest, error) {
+	var pullReqs []*prchecklist.PullRequest
+	for _, mr := range mrs {
+		if !recentPullRequestBaseMatches(q.BaseBranches, project.DefaultBranch, mr.TargetBranch) {
+			continue
+		}
+		if len(pullReqs) >= recentPullRequestsPerRepo {
+			break
+		}
+
+		pullReq := &prchecklist.PullRequest{
+			Title:    mr.Title,
+			URL:      mr.WebURL,
+			Number:   mr.IID,
+			Provider: prchecklist.ProviderGitLab,
+		}
+		if q.ReleaseOnly {
+			commits, err := g.getMergeRequestCommits(ctx, fmt.Sprintf("projects/%d", project.ID), mr.IID, gitlabPerPage)
+			if err != nil {
+				return nil, err
+			}
+			pullReq.Commits = commits
+		}
+		pullReqs = append(pullReqs, pullReq)
+	}
+	return pullReqs, nil
+}
+
+// SetRepositoryStatusAs sets a commit status, whose state is the one of GitHub.
+func (g gitlabGateway) SetRepositoryStatusAs(ctx context.Context, owner, repo, ref, contextName, state, description, targetURL string) error {
+	switch state {
+	case "failure", "error":
+		state = "failed"
+	}
+
+	_, err := g.request(ctx, prchecklist.ContextClient(ctx), "POST", gitlabProjectPath(owner, repo)+"/statuses/"+url.PathEscape(ref), map[string]string{
+		"state":       state,
+		"name":        contextName,
+		"description": description,
+		"target_url":  targetURL,
+	}, nil)
+	return errors.Wrap(err, "post commit status")
+}
+
+// CreateCheckRun fails, as GitLab does not have check runs.
+func (g gitlabGateway) CreateCheckRun(ctx context.Context, owner, repo string, run prchecklist.CheckRun) error {
+	return errors.Errorf("gateway/gitlab: check runs are not supported on GitLab; use status: %s", prchecklist.StatusCommitStatus)
+}
+
+// CanCreateCheckRun returns false, as check runs are not supported on GitLab.
+func (g gitlabGateway) CanCreateCheckRun(ctx context.Context) bool {
+	return false
+}
+
+// ServiceHTTPClient returns an *http.Client to access the project
+// on behalf of prchecklist itself rather than visitors.
+func (g gitlabGateway) ServiceHTTPClient(ctx context.Context, owner, repo string) (*http.Client, error) {
+	if gitlabToken == "" {
+		return nil, errors.New("gateway/gitlab: PRCHECKLIST_GITLAB_TOKEN must be set to access GitLab without visitors")
+	}
+
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: gitlabToken})), nil
+}
+
+// InvalidatePullRequest does nothing, as merge requests are not cached.
+func (g gitlabGateway) InvalidatePullRequest(ctx context.Context, owner, repo string, number int) {
+}
+
+// GetPullRequestNumbersByHead lists the IIDs of the open merge requests whose source is the branch.
+func (g gitlabGateway) GetPullRequestNumbersByHead(ctx context.Context, owner, repo, branch string) ([]int, error) {
+	var mrs []gitlabMergeRequest
+	_, err := g.get(ctx, gitlabProjectPath(owner, repo)+"/merge_requests?state=opened&source_branch="+url.QueryEscape(branch), &mrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "list merge requests")
+	}
+
+	numbers := make([]int, len(mrs))
+	for i, mr := range mrs {
+		numbers[i] = mr.IID
+	}
+	return numbers, nil
+}
+
+// PutIssueComment edits the note specified by commentID on the merge request,
+// or creates a new one if commentID is 0 or the note has been deleted.
+// Returns the ID of the note.
+func (g gitlabGateway) PutIssueComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (int64, error) {
+	client := prchecklist.ContextClient(ctx)
+	notesPath := fmt.Sprintf("%s/merge_requests/%d/notes", gitlabProjectPath(owner, repo), number)
+	params := map[string]string{"body": body}
+
+	var note struct {
+		ID int64
+	}
+
+	if commentID != 0 {
+		_, err := g.request(ctx, client, "PUT", fmt.Sprintf("%s/%d", notesPath, commentID), params, &note)
+		if err == nil {
+			return note.ID, nil
+		}
+		if !isGitLabNotFound(err) {
+			return 0, errors.Wrap(err, "edit note")
+		}
+	}
+
+	if _, err := g.request(ctx, client, "POST", notesPath, params, &note); err != nil {
+		return 0, errors.Wrap(err, "create note")
+	}
+
+	return note.ID, nil
+}
+
+// Hosts returns nothing, as additional hosts are not supported on GitLab.
+func (g gitlabGateway) Hosts() []string {
+	return nil
+}
+
+// AuthCodeURL returns the URL to authorize prchecklist on GitLab.
+// Hosts given by prchecklist.ContextWithHost are not supported.
+func (g gitlabGateway) AuthCodeURL(ctx context.Context, state string, redirectURI *url.URL) (string, error) {
+	if host := prchecklist.ContextHost(ctx); host != "" {
+		return "", errors.Errorf("gateway/gitlab: unknown host: %q", host)
+	}
+
+	opts := []oauth2.AuthCodeOption{}
+	if redirectURI != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("redirect_uri", redirectURI.String()))
+	}
+	return g.oauth2Config.AuthCodeURL(state, opts...), nil
+}
+
+func (g gitlabGateway) AuthenticateUser(ctx context.Context, code string) (*prchecklist.GitHubUser, error) {
+	token, err := g.oauth2Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.GetUserFromToken(ctx, token)
+}
+
+// RefreshToken obtains a new token of the user, as GitLab OAuth tokens expire in two hours.
+func (g gitlabGateway) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return refreshToken(ctx, g.oauth2Config, token)
+}
+
+func (g gitlabGateway) GetUserFromToken(ctx context.Context, token *oauth2.Token) (*prchecklist.GitHubUser, error) {
+	var u gitlabUser
+	if _, err := g.request(ctx, oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)), "GET", "user", nil, &u); err != nil {
+		return nil, err
+	}
+
+	return &prchecklist.GitHubUser{
+		ID:        u.ID,
+		Login:     u.Username,
+		AvatarURL: u.AvatarURL,
+		Token:     token,
+	}, nil
+}
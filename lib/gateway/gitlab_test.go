@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// fakeGitLab serves a minimal subset of GitLab API,
+// for a project "group/subgroup/project" with merge requests !1 (release), !2 and !3.
+func fakeGitLab(t *testing.T) (*httptest.Server, *[]string) {
+	var posts []string
+	const project = "/api/v4/projects/group%2Fsubgroup%2Fproject"
+
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := req.URL.EscapedPath()
+		switch {
+		case req.Method == "GET" && path == project:
+			writeJSON(w, map[string]interface{}{"id": 42, "path_with_namespace": "group/subgroup/project", "visibility": "private", "default_branch": "main"})
+
+		case req.Method == "GET" && path == project+"/merge_requests/1":
+			writeJSON(w, map[string]interface{}{
+				"iid": 1, "title": "Release", "description": "body", "web_url": "https://gitlab.example.com/group/subgroup/project/-/merge_requests/1",
+				"sha": "HEADSHA", "author": map[string]interface{}{"username": "alice"},
+			})
+
+		case req.Method == "GET" && path == project+"/merge_requests/1/commits":
+			// newest first, two pages
+			if req.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				writeJSON(w, []map[string]interface{}{
+					{"id": "HEADSHA", "message": "Merge branch 'feature-3' into 'main'\n\nSee merge request group/subgroup/project!3"},
+				})
+			} else {
+				writeJSON(w, []map[string]interface{}{
+					{"id": "SHA2", "message": "Merge branch 'feature-2' into 'main'\n\nSee merge request group/subgroup/project!2"},
+				})
+			}
+
+		case req.Method == "GET" && path == project+"/repository/files/prchecklist.yml":
+			assert.Equal(t, "HEADSHA", req.URL.Query().Get("ref"))
+			writeJSON(w, map[string]interface{}{"blob_id": "BLOBSHA"})
+
+		case req.Method == "GET" && path == project+"/repository/blobs/BLOBSHA/raw":
+			fmt.Fprint(w, "stages:\n  - qa\n")
+
+		case req.Method == "GET" && path == project+"/merge_requests":
+			assert.Equal(t, []string{"2", "3"}, req.URL.Query()["iids[]"])
+			writeJSON(w, []map[string]interface{}{
+				{"iid": 2, "title": "Feature 2", "author": map[string]interface{}{"username": "bob"}, "labels": []string{"bug"}, "merged_at": "2020-01-02T03:04:05Z", "merged_by": map[string]interface{}{"username": "alice"}},
+				{"iid": 3, "title": "Feature 3", "author": map[string]interface{}{"username": "bob"}, "assignees": []map[string]interface{}{{"username": "carol"}}, "reviewers": []map[string]interface{}{{"username": "dave"}}},
+			})
+
+		case req.Method == "PUT" && path == project+"/merge_requests/1/notes/10":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"404 Not found"}`)
+
+		case req.Method == "POST" && (path == project+"/merge_requests/1/notes" || path == project+"/statuses/HEADSHA"):
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+			posts = append(posts, fmt.Sprintf("%s %s %s", path, body["state"], body["body"]))
+			writeJSON(w, map[string]interface{}{"id": 11})
+
+		default:
+			t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return s, &posts
+}
+
+func TestGitLab(t *testing.T) {
+	s, posts := fakeGitLab(t)
+	defer s.Close()
+
+	g := gitlabGateway{cache: newMemoryCache(), baseURL: s.URL}
+	ctx := context.Background()
+	ref := prchecklist.ChecklistRef{Owner: "group/subgroup", Repo: "project", Number: 1}
+
+	pullReq, _, err := g.GetPullRequest(ctx, ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Release", pullReq.Title)
+	assert.Equal(t, prchecklist.ProviderGitLab, pullReq.Provider)
+	assert.True(t, pullReq.IsPrivate)
+	assert.Equal(t, "BLOBSHA", pullReq.ConfigBlobID)
+	require.Equal(t, 2, len(pullReq.Commits))
+	assert.Equal(t, "SHA2", pullReq.Commits[0].Oid, "oldest first")
+	assert.Equal(t, "HEADSHA", pullReq.Commits[1].Oid)
+
+	blob, err := g.GetBlob(ctx, ref, "BLOBSHA")
+	require.NoError(t, err)
+	assert.Equal(t, "stages:\n  - qa\n", string(blob))
+
+	features, err := g.GetFeaturePullRequests(ctx, "group/subgroup", "project", []int{2, 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(features))
+	assert.Equal(t, "bob", features[2].User.Login)
+	assert.Equal(t, []string{"bug"}, features[2].Labels)
+	assert.Equal(t, "alice", features[2].MergedBy.Login)
+	assert.NotNil(t, features[2].MergedAt)
+	assert.Equal(t, "carol", features[3].User.Login, "prefer assignee")
+	assert.Equal(t, []prchecklist.GitHubUserSimple{{Login: "dave"}}, features[3].RequestedReviewers)
+
+	id, err := g.PutIssueComment(ctx, "group/subgroup", "project", 1, 10, "summary")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), id)
+
+	err = g.SetRepositoryStatusAs(ctx, "group/subgroup", "project", "HEADSHA", "prchecklist/default/completed", "failure", "1/2 checked", "https://prchecklist.example.com/")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/api/v4/projects/group%2Fsubgroup%2Fproject/merge_requests/1/notes  summary",
+		"/api/v4/projects/group%2Fsubgroup%2Fproject/statuses/HEADSHA failed ",
+	}, *posts)
+}
+
+func TestGitlabPipelineStatus(t *testing.T) {
+	for status, expected := range map[string]string{
+		"success":  "success",
+		"failed":   "failure",
+		"canceled": "error",
+		"running":  "pending",
+		"created":  "pending",
+		"":         "",
+	} {
+		assert.Equal(t, expected, gitlabPipelineStatus(status), status)
+	}
+}
+
+func TestGitLabError_notAuthed(t *testing.T) {
+	assert.True(t, errors.Is(fmt.Errorf("GetPullRequest: %w", &gitlabError{StatusCode: http.StatusUnauthorized}), prchecklist.ErrNotAuthed))
+	assert.False(t, errors.Is(&gitlabError{StatusCode: http.StatusNotFound}, prchecklist.ErrNotAuthed))
+}
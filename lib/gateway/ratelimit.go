@@ -0,0 +1,326 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+const (
+	// rateLimitMaxRetries is how many times requests are retried on secondary rate limits.
+	rateLimitMaxRetries = 3
+	// rateLimitMaxBackoff is the longest wait before retrying,
+	// beyond which the requests fail as rate limited.
+	rateLimitMaxBackoff = time.Minute
+	// rateLimitMinBackoff is the shortest wait before retrying,
+	// for when the reset time is missing or has passed.
+	rateLimitMinBackoff = time.Second
+	// rateLimitLowRatio is the ratio of the remaining quota below which warnings are logged.
+	rateLimitLowRatio = 0.1
+	// cacheDurationETag is how long responses are kept for conditional requests.
+	cacheDurationETag = 24 * time.Hour
+)
+
+// rateLimit is the state of a rate limit of GitHub API.
+type rateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// rateLimits tracks the rate limits of GitHub API, which exist per token and resource.
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+type rateLimits struct {
+	mu sync.Mutex
+	// tokenKey + "\000" + resource -> rateLimit
+	m map[string]rateLimit
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{m: map[string]rateLimit{}}
+}
+
+func (r *rateLimits) get(tokenKey, resource string) (rateLimit, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rl, ok := r.m[tokenKey+"\000"+resource]
+	return rl, ok
+}
+
+func (r *rateLimits) set(tokenKey, resource string, rl rateLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.m) >= 10000 {
+		r.m = map[string]rateLimit{}
+	}
+	r.m[tokenKey+"\000"+resource] = rl
+}
+
+// exhausted reports until when the rate limit is exhausted, if it is.
+func (r *rateLimits) exhausted(tokenKey, resource string, now time.Time) (time.Time, bool) {
+	rl, ok := r.get(tokenKey, resource)
+	if ok && rl.Remaining == 0 && now.Before(rl.Reset) {
+		return rl.Reset, true
+	}
+	return time.Time{}, false
+}
+
+// updateFromHeader records the X-RateLimit-* headers of a response.
+func (r *rateLimits) updateFromHeader(tokenKey, resource string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if res := header.Get("X-RateLimit-Resource"); res != "" {
+		resource = res
+	}
+
+	r.set(tokenKey, resource, rateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)})
+
+	if limit > 0 && float64(remaining) < float64(limit)*rateLimitLowRatio {
+		log.Printf("GitHub API rate limit of %s is low: %d/%d remaining until %s", resource, remaining, limit, time.Unix(reset, 0).Format(time.RFC3339))
+	}
+}
+
+// rateLimitResource guesses the rate limit resource of req before the response tells it.
+func rateLimitResource(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+	return "core"
+}
+
+// etagCacheEntry is a response kept for conditional requests.
+type etagCacheEntry struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+}
+
+// rateLimitTransport is an http.RoundTripper to GitHub API which
+// fails fast while the rate limit is exhausted, retries on secondary rate limits
+// and revalidates GET responses with ETags, which do not count against the rate limit when not modified.
+type rateLimitTransport struct {
+	base   http.RoundTripper
+	limits *rateLimits
+	cache  Cache
+	// sleep is replaceable for testing.
+	sleep func(context.Context, time.Duration) error
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done earlier.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// wrapRateLimitClient returns a client which sends requests of client through rateLimitTransport.
+func (g githubGateway) wrapRateLimitClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if g.rateLimits == nil {
+		return client
+	}
+
+	t := &rateLimitTransport{base: client.Transport, limits: g.rateLimits, cache: g.cache, sleep: sleepContext}
+
+	wrapped := *client
+	if ot, ok := client.Transport.(*oauth2.Transport); ok {
+		// Go under oauth2.Transport to see the token
+		t.base = ot.Base
+		wrapped.Transport = &oauth2.Transport{Source: ot.Source, Base: t}
+	} else {
+		wrapped.Transport = t
+	}
+	if t.base == nil {
+		// Trust the custom CA of the host, if any
+		t.base = g.transport
+	}
+	return &wrapped
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	tokenKey := ""
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		tokenKey = hex.EncodeToString(sum[:])
+	}
+	resource := rateLimitResource(req)
+
+	if until, ok := t.limits.exhausted(tokenKey, resource, time.Now()); ok {
+		return nil, &prchecklist.RateLimitedError{Until: until}
+	}
+
+	var (
+		etagKey string
+		cached  etagCacheEntry
+		hasETag bool
+	)
+	if req.Method == "GET" && t.cache != nil {
+		etagKey = "etag\000" + tokenKey + "\000" + req.URL.String()
+		if getCacheJSON(t.cache, etagKey, &cached) && cached.ETag != "" {
+			hasETag = true
+			req = cloneRequest(req)
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		t.limits.updateFromHeader(tokenKey, resource, resp.Header)
+
+		// The token has been expired or revoked
+		if resp.StatusCode == http.StatusUnauthorized && tokenKey != "" {
+			resp.Body.Close()
+			return nil, prchecklist.ErrNotAuthed
+		}
+
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			wait, limited, err := rateLimitWait(resp, time.Now(), attempt)
+			if err != nil {
+				return nil, err
+			}
+			if limited {
+				resp.Body.Close()
+
+				if wait < rateLimitMinBackoff {
+					wait = rateLimitMinBackoff
+				}
+
+				until := time.Now().Add(wait)
+				replayable := req.Body == nil || req.GetBody != nil
+				if attempt >= rateLimitMaxRetries || wait > rateLimitMaxBackoff || !replayable {
+					return nil, &prchecklist.RateLimitedError{Until: until}
+				}
+				// Fail now rather than after the caller gives up
+				if deadline, ok := req.Context().Deadline(); ok && until.After(deadline) {
+					return nil, &prchecklist.RateLimitedError{Until: until}
+				}
+
+				log.Printf("GitHub API secondary rate limit: retrying %s in %s", req.URL, wait)
+				if err := t.sleep(req.Context(), wait); err != nil {
+					return nil, err
+				}
+
+				if req.GetBody != nil {
+					if req.Body, err = req.GetBody(); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+		}
+
+		if hasETag && resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			resp.StatusCode = http.StatusOK
+			resp.Status = "200 OK"
+			for k, v := range cached.Header {
+				if _, ok := resp.Header[k]; !ok {
+					resp.Header[k] = v
+				}
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+			resp.ContentLength = int64(len(cached.Body))
+			return resp, nil
+		}
+
+		if etagKey != "" && resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			entry := etagCacheEntry{
+				ETag:   resp.Header.Get("ETag"),
+				Header: http.Header{"Content-Type": resp.Header["Content-Type"]},
+				Body:   body,
+			}
+			if err := setCacheJSON(t.cache, etagKey, entry, cacheDurationETag); err != nil {
+				log.Printf("cache: %s", err)
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// rateLimitWait inspects a 403 or 429 response and reports whether it is rate limited,
+// and how long to wait before retrying.
+// The body of resp is restored to be read again.
+func rateLimitWait(resp *http.Response, now time.Time, attempt int) (time.Duration, bool, error) {
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true, nil
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		return time.Unix(reset, 0).Sub(now), true, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return 0, false, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var e struct{ Message string }
+	if json.Unmarshal(body, &e) == nil {
+		msg := strings.ToLower(e.Message)
+		if strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse detection") {
+			// Exponential backoff from a second
+			return time.Second << uint(attempt), true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func cloneRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	return r
+}
@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func newRateLimitTestClient(g githubGateway, token string) *http.Client {
+	client := g.wrapRateLimitClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	client.Transport.(*oauth2.Transport).Base.(*rateLimitTransport).sleep = func(context.Context, time.Duration) error { return nil }
+	return client
+}
+
+func TestRateLimitTransport_exhausted(t *testing.T) {
+	var requests int
+	reset := time.Now().Add(30 * time.Minute)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if requests > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"API rate limit exceeded"}`)
+		}
+	}))
+	defer s.Close()
+
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+	client := newRateLimitTestClient(g, "token-a")
+
+	resp, err := client.Get(s.URL + "/repos/a/b")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Fails without requesting
+	_, err = client.Get(s.URL + "/repos/a/b")
+	var rlErr *prchecklist.RateLimitedError
+	require.True(t, errors.As(err, &rlErr), "%v", err)
+	assert.Equal(t, reset.Unix(), rlErr.Until.Unix())
+	assert.Equal(t, 1, requests)
+
+	// Other tokens are not affected
+	_, err = newRateLimitTestClient(g, "token-b").Get(s.URL + "/repos/a/b")
+	require.True(t, errors.As(err, &rlErr), "%v", err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestRateLimitTransport_secondaryRateLimit(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusForbidden)
+		case 2:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit."}`)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer s.Close()
+
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+
+	resp, err := newRateLimitTestClient(g, "token").Get(s.URL + "/repos/a/b")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRateLimitTransport_wait(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Reset already passed
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+	client := newRateLimitTestClient(g, "token")
+
+	var waits []time.Duration
+	client.Transport.(*oauth2.Transport).Base.(*rateLimitTransport).sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	_, err := client.Get(s.URL + "/repos/a/b")
+	var rlErr *prchecklist.RateLimitedError
+	require.True(t, errors.As(err, &rlErr), "%v", err)
+	require.Equal(t, rateLimitMaxRetries, len(waits))
+	for _, d := range waits {
+		assert.Equal(t, rateLimitMinBackoff, d, "clamped")
+	}
+
+	// Not waiting beyond the deadline
+	waits = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("GET", s.URL+"/repos/a/b", nil)
+	require.NoError(t, err)
+	_, err = client.Do(req.WithContext(ctx))
+	require.True(t, errors.As(err, &rlErr), "%v", err)
+	assert.Equal(t, 0, len(waits))
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	assert.Equal(t, context.Canceled, sleepContext(ctx, time.Hour))
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.NoError(t, sleepContext(context.Background(), time.Millisecond))
+}
+
+func TestRateLimitTransport_etag(t *testing.T) {
+	var notModified int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":"blob"}`)
+	}))
+	defer s.Close()
+
+	g := githubGateway{cache: newMemoryCache(), rateLimits: newRateLimits()}
+	client := newRateLimitTestClient(g, "token")
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(s.URL + "/repos/a/b/git/blobs/sha")
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"content":"blob"}`, string(b))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	}
+	assert.Equal(t, 1, notModified)
+
+	// Cached responses are not shared among tokens
+	resp, err := newRateLimitTestClient(g, "another").Get(s.URL + "/repos/a/b/git/blobs/sha")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, notModified)
+}
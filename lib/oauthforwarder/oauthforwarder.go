@@ -1,12 +1,12 @@
 package oauthforwarder
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/url"
+
+	"github.com/motemen/prchecklist/v2/lib/signature"
 )
 
 // Forwarder is the implementation root regarding OAuth callback forwarder.
@@ -16,9 +16,7 @@ type Forwarder struct {
 }
 
 func (f *Forwarder) hashString(in string) []byte {
-	h := hmac.New(sha256.New, f.Secret)
-	h.Write([]byte(in))
-	return h.Sum(nil)
+	return signature.Sign(f.Secret, []byte(in))
 }
 
 // CreateURL creates URL to callback host which in success returns back to callback.
@@ -61,7 +59,7 @@ func (f *Forwarder) Wrap(base http.Handler) http.Handler {
 			http.Error(w, "Invalid signature", http.StatusBadRequest)
 			return
 		}
-		if !hmac.Equal(f.hashString(to), sigBytes) {
+		if !signature.Verify(f.Secret, []byte(to), sigBytes) {
 			http.Error(w, "Invalid signature", http.StatusBadRequest)
 			return
 		}
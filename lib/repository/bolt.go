@@ -22,8 +22,12 @@ type boltCoreRepository struct {
 }
 
 const (
-	boltBucketNameUsers  = "users"
-	boltBucketNameChecks = "checks"
+	boltBucketNameUsers           = "users"
+	boltBucketNameChecks          = "checks"
+	boltBucketNameOutbox          = "outbox"
+	boltBucketNameSummaryComments = "summaryComments"
+	boltBucketNameKnownItems      = "knownItems"
+	boltBucketNameSlackUserLinks  = "slackUserLinks"
 )
 
 // NewBoltCore creates a coreRepository backed by boltdb.
@@ -44,6 +48,18 @@ func NewBoltCore(datasource string) (coreRepository, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte(boltBucketNameChecks)); err != nil {
 			return err
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(boltBucketNameOutbox)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(boltBucketNameSummaryComments)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(boltBucketNameKnownItems)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(boltBucketNameSlackUserLinks)); err != nil {
+			return err
+		}
 		return nil
 	})
 	if err != nil {
@@ -63,18 +79,18 @@ func (r boltCoreRepository) AddUser(ctx context.Context, user prchecklist.GitHub
 			return err
 		}
 
-		return usersBucket.Put([]byte(strconv.FormatInt(int64(user.ID), 10)), buf)
+		return usersBucket.Put([]byte(userKey(user.Host, user.ID)), buf)
 	})
 }
 
 // GetUsers implements coreRepository.GetUser.
-func (r boltCoreRepository) GetUsers(ctx context.Context, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
+func (r boltCoreRepository) GetUsers(ctx context.Context, host string, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
 	users := make(map[int]prchecklist.GitHubUser, len(userIDs))
 	err := r.db.View(func(tx *bolt.Tx) error {
 		usersBucket := tx.Bucket([]byte(boltBucketNameUsers))
 
 		for _, id := range userIDs {
-			buf := usersBucket.Get([]byte(strconv.FormatInt(int64(id), 10)))
+			buf := usersBucket.Get([]byte(userKey(host, id)))
 			if buf == nil {
 				return fmt.Errorf("not found: user id=%v", id)
 			}
@@ -191,3 +207,229 @@ func (r boltCoreRepository) RemoveCheck(ctx context.Context, clRef prchecklist.C
 		return checksBucket.Put(dbKey, data)
 	})
 }
+
+// PutOutboxNotification implements coreRepository.PutOutboxNotification.
+func (r boltCoreRepository) PutOutboxNotification(ctx context.Context, n prchecklist.OutboxNotification) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		outboxBucket := tx.Bucket([]byte(boltBucketNameOutbox))
+
+		buf, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+
+		return outboxBucket.Put([]byte(n.ID), buf)
+	})
+	return errors.Wrap(err, "PutOutboxNotification")
+}
+
+// GetOutboxNotification implements coreRepository.GetOutboxNotification.
+func (r boltCoreRepository) GetOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error) {
+	var n *prchecklist.OutboxNotification
+	err := r.db.View(func(tx *bolt.Tx) error {
+		outboxBucket := tx.Bucket([]byte(boltBucketNameOutbox))
+
+		buf := outboxBucket.Get([]byte(id))
+		if buf == nil {
+			return nil
+		}
+
+		return json.Unmarshal(buf, &n)
+	})
+	return n, errors.Wrap(err, "GetOutboxNotification")
+}
+
+// GetOutboxNotifications implements coreRepository.GetOutboxNotifications.
+func (r boltCoreRepository) GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
+	notifications := []*prchecklist.OutboxNotification{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		outboxBucket := tx.Bucket([]byte(boltBucketNameOutbox))
+
+		return outboxBucket.ForEach(func(k, v []byte) error {
+			var n prchecklist.OutboxNotification
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			if n.State == state {
+				notifications = append(notifications, &n)
+			}
+			return nil
+		})
+	})
+	return notifications, errors.Wrap(err, "GetOutboxNotifications")
+}
+
+// ClaimOutboxNotifications implements coreRepository.ClaimOutboxNotifications.
+func (r boltCoreRepository) ClaimOutboxNotifications(ctx context.Context, now time.Time, lease time.Duration) ([]*prchecklist.OutboxNotification, error) {
+	notifications := []*prchecklist.OutboxNotification{}
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		outboxBucket := tx.Bucket([]byte(boltBucketNameOutbox))
+
+		claimed := map[string][]byte{}
+		err := outboxBucket.ForEach(func(k, v []byte) error {
+			var n prchecklist.OutboxNotification
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			if !claimOutboxNotification(&n, now, lease) {
+				return nil
+			}
+
+			buf, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			claimed[n.ID] = buf
+			notifications = append(notifications, &n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Buckets must not be modified during ForEach
+		for id, buf := range claimed {
+			if err := outboxBucket.Put([]byte(id), buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "ClaimOutboxNotifications")
+	}
+	return notifications, nil
+}
+
+// UpdateOutboxNotification implements coreRepository.UpdateOutboxNotification.
+func (r boltCoreRepository) UpdateOutboxNotification(ctx context.Context, id string, update func(n *prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+	var n *prchecklist.OutboxNotification
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		outboxBucket := tx.Bucket([]byte(boltBucketNameOutbox))
+
+		buf := outboxBucket.Get([]byte(id))
+		if buf == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(buf, &n); err != nil {
+			return err
+		}
+		if err := update(n); err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		return outboxBucket.Put([]byte(id), buf)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "UpdateOutboxNotification")
+	}
+	return n, nil
+}
+
+// RemoveOutboxNotification implements coreRepository.RemoveOutboxNotification.
+func (r boltCoreRepository) RemoveOutboxNotification(ctx context.Context, id string) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketNameOutbox)).Delete([]byte(id))
+	})
+	return errors.Wrap(err, "RemoveOutboxNotification")
+}
+
+// GetSummaryCommentID implements coreRepository.GetSummaryCommentID.
+func (r boltCoreRepository) GetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef) (int64, error) {
+	var id int64
+	err := r.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket([]byte(boltBucketNameSummaryComments)).Get([]byte(summaryCommentKey(ref)))
+		if buf == nil {
+			return nil
+		}
+
+		var err error
+		id, err = strconv.ParseInt(string(buf), 10, 64)
+		return err
+	})
+	return id, errors.Wrap(err, "GetSummaryCommentID")
+}
+
+// SetSummaryCommentID implements coreRepository.SetSummaryCommentID.
+func (r boltCoreRepository) SetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef, commentID int64) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketNameSummaryComments)).Put([]byte(summaryCommentKey(ref)), []byte(strconv.FormatInt(commentID, 10)))
+	})
+	return errors.Wrap(err, "SetSummaryCommentID")
+}
+
+// GetKnownItemNumbers implements coreRepository.GetKnownItemNumbers.
+func (r boltCoreRepository) GetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef) ([]int, error) {
+	var numbers []int
+	err := r.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket([]byte(boltBucketNameKnownItems)).Get([]byte(clRef.String()))
+		if buf == nil {
+			return nil
+		}
+		return json.Unmarshal(buf, &numbers)
+	})
+	return numbers, errors.Wrap(err, "GetKnownItemNumbers")
+}
+
+// SetKnownItemNumbers implements coreRepository.SetKnownItemNumbers.
+func (r boltCoreRepository) SetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) error {
+	buf, err := json.Marshal(knownItemNumbers(numbers))
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketNameKnownItems)).Put([]byte(clRef.String()), buf)
+	})
+	return errors.Wrap(err, "SetKnownItemNumbers")
+}
+
+// GetSlackUserLink implements coreRepository.GetSlackUserLink.
+func (r boltCoreRepository) GetSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error) {
+	var link *prchecklist.SlackUserLink
+	err := r.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket([]byte(boltBucketNameSlackUserLinks)).Get([]byte(slackUserLinkKey(teamID, userID)))
+		if buf == nil {
+			return nil
+		}
+		return json.Unmarshal(buf, &link)
+	})
+	return link, errors.Wrap(err, "GetSlackUserLink")
+}
+
+// PutSlackUserLink implements coreRepository.PutSlackUserLink.
+func (r boltCoreRepository) PutSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error {
+	buf, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketNameSlackUserLinks)).Put([]byte(slackUserLinkKey(link.TeamID, link.UserID)), buf)
+	})
+	return errors.Wrap(err, "PutSlackUserLink")
+}
+
+// GetSlackUserLinksOf implements coreRepository.GetSlackUserLinksOf.
+// Links are scanned, which are few.
+func (r boltCoreRepository) GetSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error) {
+	var links []prchecklist.SlackUserLink
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketNameSlackUserLinks)).ForEach(func(_, buf []byte) error {
+			var link prchecklist.SlackUserLink
+			if err := json.Unmarshal(buf, &link); err != nil {
+				return err
+			}
+			if link.GitHubHost == host && link.GitHubUserID == githubUserID {
+				links = append(links, link)
+			}
+			return nil
+		})
+	})
+	return links, errors.Wrap(err, "GetSlackUserLinksOf")
+}
@@ -20,4 +20,8 @@ func TestBoltRepository(t *testing.T) {
 
 	testUsers(t, repo)
 	testChecks(t, repo)
+	testOutbox(t, repo)
+	testSummaryComment(t, repo)
+	testKnownItems(t, repo)
+	testSlackUserLinks(t, repo)
 }
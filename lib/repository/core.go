@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/motemen/prchecklist/v2"
 	"github.com/pkg/errors"
@@ -14,7 +17,68 @@ type coreRepository interface {
 	RemoveCheck(ctx context.Context, clRef prchecklist.ChecklistRef, key string, user prchecklist.GitHubUser) error
 
 	AddUser(ctx context.Context, user prchecklist.GitHubUser) error
-	GetUsers(ctx context.Context, userIDs []int) (map[int]prchecklist.GitHubUser, error)
+	GetUsers(ctx context.Context, host string, userIDs []int) (map[int]prchecklist.GitHubUser, error)
+
+	PutOutboxNotification(ctx context.Context, n prchecklist.OutboxNotification) error
+	GetOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error)
+	GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error)
+	ClaimOutboxNotifications(ctx context.Context, now time.Time, lease time.Duration) ([]*prchecklist.OutboxNotification, error)
+	UpdateOutboxNotification(ctx context.Context, id string, update func(n *prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error)
+	RemoveOutboxNotification(ctx context.Context, id string) error
+
+	GetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef) (int64, error)
+	SetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef, commentID int64) error
+
+	GetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef) ([]int, error)
+	SetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) error
+
+	GetSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error)
+	PutSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error
+	GetSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error)
+}
+
+// knownItemNumbers makes numbers non-nil so that recorded empty lists are told from unrecorded ones.
+func knownItemNumbers(numbers []int) []int {
+	if numbers == nil {
+		return []int{}
+	}
+	return numbers
+}
+
+// claimOutboxNotification leases n for the duration if it is pending and due at now,
+// reporting whether it is claimed.
+// The notification is retried after the lease if the worker fails to finish delivering it.
+func claimOutboxNotification(n *prchecklist.OutboxNotification, now time.Time, lease time.Duration) bool {
+	if n.State != prchecklist.OutboxStatePending || n.NextAttemptAt.After(now) || n.Leased(now) {
+		return false
+	}
+	n.LeasedUntil = now.Add(lease)
+	n.NextAttemptAt = n.LeasedUntil
+	return true
+}
+
+// userKey is the key of the user on the GitHub host, as user IDs are unique only in a host.
+// It is the ID itself for the default host.
+func userKey(host string, id int) string {
+	key := strconv.Itoa(id)
+	if host != "" {
+		key = host + ":" + key
+	}
+	return key
+}
+
+func slackUserLinkKey(teamID, userID string) string {
+	return teamID + ":" + userID
+}
+
+// summaryCommentKey is the key of the summary comment for the pull request pointed by ref,
+// which is shared among the stages.
+func summaryCommentKey(ref prchecklist.ChecklistRef) string {
+	key := fmt.Sprintf("%s/%s#%d", ref.Owner, ref.Repo, ref.Number)
+	if ref.Host != "" {
+		key = ref.Host + "/" + key
+	}
+	return key
 }
 
 var registry = map[string]coreRepositoryBuilder{}
@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"cloud.google.com/go/datastore"
+	"golang.org/x/oauth2"
 
 	"github.com/motemen/prchecklist/v2"
 )
@@ -16,8 +19,12 @@ type datastoreRepository struct {
 }
 
 const (
-	datastoreKindUser  = "User"
-	datastoreKindCheck = "Check"
+	datastoreKindUser               = "User"
+	datastoreKindCheck              = "Check"
+	datastoreKindOutboxNotification = "OutboxNotification"
+	datastoreKindSummaryComment     = "SummaryComment"
+	datastoreKindKnownItems         = "KnownItems"
+	datastoreKindSlackUserLink      = "SlackUserLink"
 )
 
 func init() {
@@ -35,15 +42,23 @@ func NewDatastoreCore(datasource string) (coreRepository, error) {
 }
 
 func (r datastoreRepository) AddUser(ctx context.Context, user prchecklist.GitHubUser) error {
-	key := datastore.IDKey(datastoreKindUser, int64(user.ID), nil)
-	_, err := r.client.Put(ctx, key, &user)
+	_, err := r.client.Put(ctx, datastoreUserKey(user.Host, user.ID), &user)
 	return err
 }
 
-func (r datastoreRepository) GetUsers(ctx context.Context, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
+// datastoreUserKey is the key of the user on the GitHub host.
+// Users of the default host are keyed by their IDs as before hosts are introduced.
+func datastoreUserKey(host string, id int) *datastore.Key {
+	if host == "" {
+		return datastore.IDKey(datastoreKindUser, int64(id), nil)
+	}
+	return datastore.NameKey(datastoreKindUser, userKey(host, id), nil)
+}
+
+func (r datastoreRepository) GetUsers(ctx context.Context, host string, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
 	keys := make([]*datastore.Key, len(userIDs))
 	for i, id := range userIDs {
-		keys[i] = datastore.IDKey(datastoreKindUser, int64(id), nil)
+		keys[i] = datastoreUserKey(host, id)
 	}
 
 	users := make([]prchecklist.GitHubUser, len(userIDs))
@@ -121,6 +136,180 @@ func (r datastoreRepository) RemoveCheck(ctx context.Context, clRef prchecklist.
 	return errors.WithStack(err)
 }
 
+func (r datastoreRepository) PutOutboxNotification(ctx context.Context, n prchecklist.OutboxNotification) error {
+	key := datastore.NameKey(datastoreKindOutboxNotification, n.ID, nil)
+	_, err := r.client.Put(ctx, key, newDatastoreOutboxNotification(n))
+	return errors.WithStack(err)
+}
+
+func (r datastoreRepository) GetOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error) {
+	var e datastoreOutboxNotification
+	key := datastore.NameKey(datastoreKindOutboxNotification, id, nil)
+	err := r.client.Get(ctx, key, &e)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return e.notification(id), nil
+}
+
+func (r datastoreRepository) GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
+	var entities []datastoreOutboxNotification
+	q := datastore.NewQuery(datastoreKindOutboxNotification).Filter("State =", string(state))
+	keys, err := r.client.GetAll(ctx, q, &entities)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	notifications := make([]*prchecklist.OutboxNotification, len(entities))
+	for i, e := range entities {
+		notifications[i] = e.notification(keys[i].Name)
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID < notifications[j].ID
+	})
+
+	return notifications, nil
+}
+
+func (r datastoreRepository) ClaimOutboxNotifications(ctx context.Context, now time.Time, lease time.Duration) ([]*prchecklist.OutboxNotification, error) {
+	q := datastore.NewQuery(datastoreKindOutboxNotification).Filter("State =", string(prchecklist.OutboxStatePending)).KeysOnly()
+	keys, err := r.client.GetAll(ctx, q, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	notifications := []*prchecklist.OutboxNotification{}
+	for _, key := range keys {
+		// Claim each in a transaction so that others cannot claim it at once
+		var n *prchecklist.OutboxNotification
+		_, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			n = nil
+
+			var e datastoreOutboxNotification
+			err := tx.Get(key, &e)
+			if err == datastore.ErrNoSuchEntity {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			claimed := e.notification(key.Name)
+			if !claimOutboxNotification(claimed, now, lease) {
+				return nil
+			}
+
+			_, err = tx.Put(key, newDatastoreOutboxNotification(*claimed))
+			n = claimed
+			return err
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n != nil {
+			notifications = append(notifications, n)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID < notifications[j].ID
+	})
+
+	return notifications, nil
+}
+
+func (r datastoreRepository) UpdateOutboxNotification(ctx context.Context, id string, update func(n *prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+	key := datastore.NameKey(datastoreKindOutboxNotification, id, nil)
+
+	var n *prchecklist.OutboxNotification
+	_, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		n = nil
+
+		var e datastoreOutboxNotification
+		err := tx.Get(key, &e)
+		if err == datastore.ErrNoSuchEntity {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		updated := e.notification(id)
+		if err := update(updated); err != nil {
+			return err
+		}
+
+		_, err = tx.Put(key, newDatastoreOutboxNotification(*updated))
+		n = updated
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return n, nil
+}
+
+func (r datastoreRepository) RemoveOutboxNotification(ctx context.Context, id string) error {
+	key := datastore.NameKey(datastoreKindOutboxNotification, id, nil)
+	return errors.WithStack(r.client.Delete(ctx, key))
+}
+
+// datastoreOutboxNotification is the entity for prchecklist.OutboxNotification,
+// whose ID is stored as the key name.
+type datastoreOutboxNotification struct {
+	Checklist     string `datastore:",noindex"`
+	Channel       string `datastore:",noindex"`
+	URL           string `datastore:",noindex"`
+	ContentType   string `datastore:",noindex"`
+	Body          []byte `datastore:",noindex"`
+	SecretRef     string `datastore:",noindex"`
+	Repository    string `datastore:",noindex"`
+	State         string
+	Attempts      int       `datastore:",noindex"`
+	NextAttemptAt time.Time `datastore:",noindex"`
+	LeasedUntil   time.Time `datastore:",noindex"`
+	LastError     string    `datastore:",noindex"`
+	CreatedAt     time.Time `datastore:",noindex"`
+}
+
+func newDatastoreOutboxNotification(n prchecklist.OutboxNotification) *datastoreOutboxNotification {
+	return &datastoreOutboxNotification{
+		Checklist:     n.Checklist,
+		Channel:       n.Channel,
+		URL:           n.URL,
+		ContentType:   n.ContentType,
+		Body:          n.Body,
+		SecretRef:     n.SecretRef,
+		Repository:    n.Repository,
+		State:         string(n.State),
+		Attempts:      n.Attempts,
+		NextAttemptAt: n.NextAttemptAt,
+		LeasedUntil:   n.LeasedUntil,
+		LastError:     n.LastError,
+		CreatedAt:     n.CreatedAt,
+	}
+}
+
+func (e datastoreOutboxNotification) notification(id string) *prchecklist.OutboxNotification {
+	return &prchecklist.OutboxNotification{
+		ID:            id,
+		Checklist:     e.Checklist,
+		Channel:       e.Channel,
+		URL:           e.URL,
+		ContentType:   e.ContentType,
+		Body:          e.Body,
+		SecretRef:     e.SecretRef,
+		Repository:    e.Repository,
+		State:         prchecklist.OutboxState(e.State),
+		Attempts:      e.Attempts,
+		NextAttemptAt: e.NextAttemptAt,
+		LeasedUntil:   e.LeasedUntil,
+		LastError:     e.LastError,
+		CreatedAt:     e.CreatedAt,
+	}
+}
+
 type datastoreChecksBridge struct {
 	checks prchecklist.Checks
 }
@@ -171,3 +360,129 @@ func interfaceSliceToIntSlice(ifaces []interface{}) []int {
 	}
 	return ints
 }
+
+// datastoreSummaryComment is the entity for the summary comment of a pull request,
+// whose key name is summaryCommentKey.
+type datastoreSummaryComment struct {
+	CommentID int64 `datastore:",noindex"`
+}
+
+func (r datastoreRepository) GetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef) (int64, error) {
+	var e datastoreSummaryComment
+	key := datastore.NameKey(datastoreKindSummaryComment, summaryCommentKey(ref), nil)
+	err := r.client.Get(ctx, key, &e)
+	if err == datastore.ErrNoSuchEntity {
+		return 0, nil
+	}
+	return e.CommentID, errors.WithStack(err)
+}
+
+func (r datastoreRepository) SetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef, commentID int64) error {
+	key := datastore.NameKey(datastoreKindSummaryComment, summaryCommentKey(ref), nil)
+	_, err := r.client.Put(ctx, key, &datastoreSummaryComment{CommentID: commentID})
+	return errors.WithStack(err)
+}
+
+// datastoreKnownItems is the entity for the known items of a checklist,
+// whose key name is the string representation of its ChecklistRef.
+type datastoreKnownItems struct {
+	Numbers []int `datastore:",noindex"`
+}
+
+func (r datastoreRepository) GetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef) ([]int, error) {
+	var e datastoreKnownItems
+	key := datastore.NameKey(datastoreKindKnownItems, clRef.String(), nil)
+	err := r.client.Get(ctx, key, &e)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return knownItemNumbers(e.Numbers), nil
+}
+
+func (r datastoreRepository) SetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) error {
+	key := datastore.NameKey(datastoreKindKnownItems, clRef.String(), nil)
+	_, err := r.client.Put(ctx, key, &datastoreKnownItems{Numbers: numbers})
+	return errors.WithStack(err)
+}
+
+// datastoreSlackUserLink is the entity for prchecklist.SlackUserLink,
+// whose key name is slackUserLinkKey.
+type datastoreSlackUserLink struct {
+	TeamID       string    `datastore:",noindex"`
+	UserID       string    `datastore:",noindex"`
+	GitHubUserID int       `datastore:",noindex"`
+	GitHubLogin  string    `datastore:",noindex"`
+	GitHubHost   string    `datastore:",noindex"`
+	AccessToken  string    `datastore:",noindex"`
+	TokenType    string    `datastore:",noindex"`
+	RefreshToken string    `datastore:",noindex"`
+	Expiry       time.Time `datastore:",noindex"`
+}
+
+func (r datastoreRepository) GetSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error) {
+	var e datastoreSlackUserLink
+	key := datastore.NameKey(datastoreKindSlackUserLink, slackUserLinkKey(teamID, userID), nil)
+	err := r.client.Get(ctx, key, &e)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	link := e.slackUserLink()
+	return &link, nil
+}
+
+func (e datastoreSlackUserLink) slackUserLink() prchecklist.SlackUserLink {
+	return prchecklist.SlackUserLink{
+		TeamID:       e.TeamID,
+		UserID:       e.UserID,
+		GitHubUserID: e.GitHubUserID,
+		GitHubLogin:  e.GitHubLogin,
+		GitHubHost:   e.GitHubHost,
+		Token: &oauth2.Token{
+			AccessToken:  e.AccessToken,
+			TokenType:    e.TokenType,
+			RefreshToken: e.RefreshToken,
+			Expiry:       e.Expiry,
+		},
+	}
+}
+
+// GetSlackUserLinksOf scans the links, which are few and whose properties are not indexed.
+func (r datastoreRepository) GetSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error) {
+	var entities []datastoreSlackUserLink
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(datastoreKindSlackUserLink), &entities); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var links []prchecklist.SlackUserLink
+	for _, e := range entities {
+		if e.GitHubHost == host && e.GitHubUserID == githubUserID {
+			links = append(links, e.slackUserLink())
+		}
+	}
+	return links, nil
+}
+
+func (r datastoreRepository) PutSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error {
+	e := datastoreSlackUserLink{
+		TeamID:       link.TeamID,
+		UserID:       link.UserID,
+		GitHubUserID: link.GitHubUserID,
+		GitHubLogin:  link.GitHubLogin,
+		GitHubHost:   link.GitHubHost,
+	}
+	if link.Token != nil {
+		e.AccessToken = link.Token.AccessToken
+		e.TokenType = link.Token.TokenType
+		e.RefreshToken = link.Token.RefreshToken
+		e.Expiry = link.Token.Expiry
+	}
+
+	key := datastore.NameKey(datastoreKindSlackUserLink, slackUserLinkKey(link.TeamID, link.UserID), nil)
+	_, err := r.client.Put(ctx, key, &e)
+	return errors.WithStack(err)
+}
@@ -23,4 +23,8 @@ func TestDatastoreRepository(t *testing.T) {
 
 	testUsers(t, repo)
 	testChecks(t, repo)
+	testOutbox(t, repo)
+	testSummaryComment(t, repo)
+	testKnownItems(t, repo)
+	testSlackUserLinks(t, repo)
 }
@@ -4,9 +4,12 @@ import "testing"
 
 import (
 	"context"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
 
 	"github.com/motemen/prchecklist/v2"
 )
@@ -29,18 +32,30 @@ func testUsers(t *testing.T, repo coreRepository) {
 			Login: "user2",
 		}
 
-		_, err := repo.GetUsers(ctx, []int{1, 2})
+		_, err := repo.GetUsers(ctx, "", []int{1, 2})
 		require.Error(err, "should be an error: GetUsers for nonexistent users")
 
 		require.NoError(repo.AddUser(ctx, u1))
 		require.NoError(repo.AddUser(ctx, u2))
 
-		users, err := repo.GetUsers(ctx, []int{1, 2})
+		users, err := repo.GetUsers(ctx, "", []int{1, 2})
 		require.NoError(err)
 
 		assert.Equal(1, users[1].ID)
 		assert.Equal(2, users[2].ID)
 		assert.Equal("user1", users[1].Login)
+
+		// IDs are unique only in a host
+		require.NoError(repo.AddUser(ctx, prchecklist.GitHubUser{ID: 1, Login: "ghe-user1", Host: "ghe.example.com"}))
+
+		users, err = repo.GetUsers(ctx, "ghe.example.com", []int{1})
+		require.NoError(err)
+		assert.Equal("ghe-user1", users[1].Login)
+		assert.Equal("ghe.example.com", users[1].Host)
+
+		users, err = repo.GetUsers(ctx, "", []int{1})
+		require.NoError(err)
+		assert.Equal("user1", users[1].Login)
 	})
 }
 
@@ -99,3 +114,231 @@ func testChecks(t *testing.T, repo coreRepository) {
 		assert.Equal([]int{u2.ID}, checks["101"])
 	})
 }
+
+func testOutbox(t *testing.T, repo coreRepository) {
+	t.Helper()
+
+	t.Run("Outbox", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ctx := context.Background()
+
+		now := time.Now()
+		n1 := prchecklist.OutboxNotification{
+			ID:          prchecklist.NewOutboxID(now),
+			Checklist:   "test/repo#1::default",
+			Channel:     "default",
+			URL:         "https://hooks.slack.com/services/XXX",
+			ContentType: "application/x-www-form-urlencoded",
+			Body:        []byte("payload=%7B%7D"),
+			State:       prchecklist.OutboxStatePending,
+			CreatedAt:   now,
+		}
+		n2 := n1
+		n2.ID = prchecklist.NewOutboxID(now.Add(time.Second))
+
+		n, err := repo.GetOutboxNotification(ctx, n1.ID)
+		require.NoError(err)
+		assert.Nil(n)
+
+		require.NoError(repo.PutOutboxNotification(ctx, n2))
+		require.NoError(repo.PutOutboxNotification(ctx, n1))
+
+		pending, err := repo.GetOutboxNotifications(ctx, prchecklist.OutboxStatePending)
+		require.NoError(err)
+		require.Equal(2, len(pending))
+		assert.Equal(n1.ID, pending[0].ID)
+		assert.Equal(n2.ID, pending[1].ID)
+		assert.Equal(n1.Body, pending[0].Body)
+
+		n1.State = prchecklist.OutboxStateDead
+		n1.Attempts = 3
+		n1.LastError = "500 Internal Server Error"
+		require.NoError(repo.PutOutboxNotification(ctx, n1))
+
+		n, err = repo.GetOutboxNotification(ctx, n1.ID)
+		require.NoError(err)
+		require.NotNil(n)
+		assert.Equal(prchecklist.OutboxStateDead, n.State)
+		assert.Equal(3, n.Attempts)
+		assert.Equal("500 Internal Server Error", n.LastError)
+
+		dead, err := repo.GetOutboxNotifications(ctx, prchecklist.OutboxStateDead)
+		require.NoError(err)
+		assert.Equal(1, len(dead))
+
+		n2.NextAttemptAt = now
+		require.NoError(repo.PutOutboxNotification(ctx, n2))
+
+		claimed, err := repo.ClaimOutboxNotifications(ctx, now.Add(-time.Second), time.Minute)
+		require.NoError(err)
+		assert.Equal(0, len(claimed), "not due yet")
+
+		claimed, err = repo.ClaimOutboxNotifications(ctx, now, time.Minute)
+		require.NoError(err)
+		require.Equal(1, len(claimed), "dead ones are not claimed")
+		assert.Equal(n2.ID, claimed[0].ID)
+		assert.True(claimed[0].Leased(now))
+
+		claimed, err = repo.ClaimOutboxNotifications(ctx, now.Add(time.Second), time.Minute)
+		require.NoError(err)
+		assert.Equal(0, len(claimed), "claimed only once during the lease")
+
+		claimed, err = repo.ClaimOutboxNotifications(ctx, now.Add(2*time.Minute), time.Minute)
+		require.NoError(err)
+		assert.Equal(1, len(claimed), "claimed again after the lease")
+
+		errLeased := errors.New("leased")
+		n, err = repo.UpdateOutboxNotification(ctx, n2.ID, func(n *prchecklist.OutboxNotification) error {
+			return errLeased
+		})
+		assert.True(errors.Is(err, errLeased), err)
+
+		n, err = repo.UpdateOutboxNotification(ctx, n2.ID, func(n *prchecklist.OutboxNotification) error {
+			n.LeasedUntil = time.Time{}
+			n.Attempts = 1
+			return nil
+		})
+		require.NoError(err)
+		require.NotNil(n)
+		assert.Equal(1, n.Attempts)
+
+		n, err = repo.GetOutboxNotification(ctx, n2.ID)
+		require.NoError(err)
+		assert.Equal(1, n.Attempts)
+		assert.False(n.Leased(now))
+
+		n, err = repo.UpdateOutboxNotification(ctx, "none", func(n *prchecklist.OutboxNotification) error {
+			return nil
+		})
+		require.NoError(err)
+		assert.Nil(n)
+
+		require.NoError(repo.RemoveOutboxNotification(ctx, n1.ID))
+		require.NoError(repo.RemoveOutboxNotification(ctx, n2.ID))
+
+		pending, err = repo.GetOutboxNotifications(ctx, prchecklist.OutboxStatePending)
+		require.NoError(err)
+		assert.Equal(0, len(pending))
+	})
+}
+
+func testSummaryComment(t *testing.T, repo coreRepository) {
+	t.Helper()
+
+	t.Run("SummaryComment", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ctx := context.Background()
+
+		ref := prchecklist.ChecklistRef{Owner: "test", Repo: "summary", Number: 1, Stage: "qa"}
+
+		id, err := repo.GetSummaryCommentID(ctx, ref)
+		require.NoError(err)
+		assert.Equal(int64(0), id)
+
+		require.NoError(repo.SetSummaryCommentID(ctx, ref, 123456789012))
+
+		ref.Stage = "production"
+		id, err = repo.GetSummaryCommentID(ctx, ref)
+		require.NoError(err)
+		assert.Equal(int64(123456789012), id, "shared among stages")
+
+		ref.Number = 2
+		id, err = repo.GetSummaryCommentID(ctx, ref)
+		require.NoError(err)
+		assert.Equal(int64(0), id)
+	})
+}
+
+func testKnownItems(t *testing.T, repo coreRepository) {
+	t.Helper()
+
+	t.Run("KnownItems", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ctx := context.Background()
+
+		clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "known", Number: 1, Stage: "qa"}
+
+		numbers, err := repo.GetKnownItemNumbers(ctx, clRef)
+		require.NoError(err)
+		assert.Nil(numbers)
+
+		require.NoError(repo.SetKnownItemNumbers(ctx, clRef, []int{2, 3}))
+
+		numbers, err = repo.GetKnownItemNumbers(ctx, clRef)
+		require.NoError(err)
+		assert.Equal([]int{2, 3}, numbers)
+
+		require.NoError(repo.SetKnownItemNumbers(ctx, clRef, nil))
+
+		numbers, err = repo.GetKnownItemNumbers(ctx, clRef)
+		require.NoError(err)
+		assert.NotNil(numbers, "recorded empty list")
+		assert.Equal(0, len(numbers))
+
+		clRef.Stage = "production"
+		numbers, err = repo.GetKnownItemNumbers(ctx, clRef)
+		require.NoError(err)
+		assert.Nil(numbers)
+	})
+}
+
+func testSlackUserLinks(t *testing.T, repo coreRepository) {
+	t.Helper()
+
+	t.Run("SlackUserLinks", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ctx := context.Background()
+
+		link, err := repo.GetSlackUserLink(ctx, "T1", "U1")
+		require.NoError(err)
+		assert.Nil(link)
+
+		require.NoError(repo.PutSlackUserLink(ctx, prchecklist.SlackUserLink{
+			TeamID:       "T1",
+			UserID:       "U1",
+			GitHubUserID: 1,
+			GitHubLogin:  "alice",
+			GitHubHost:   "ghe.example.com",
+			Token:        &oauth2.Token{AccessToken: "token-alice"},
+		}))
+
+		link, err = repo.GetSlackUserLink(ctx, "T1", "U1")
+		require.NoError(err)
+		require.NotNil(link)
+		assert.Equal("alice", link.GitHubLogin)
+		assert.Equal("ghe.example.com", link.GitHubHost)
+		assert.Equal("ghe.example.com", link.GitHubUser().Host)
+		assert.Equal(1, link.GitHubUser().ID)
+		assert.Equal("token-alice", link.GitHubUser().Token.AccessToken)
+
+		link, err = repo.GetSlackUserLink(ctx, "T2", "U1")
+		require.NoError(err)
+		assert.Nil(link)
+
+		require.NoError(repo.PutSlackUserLink(ctx, prchecklist.SlackUserLink{
+			TeamID:       "T2",
+			UserID:       "U2",
+			GitHubUserID: 1,
+			GitHubLogin:  "alice",
+			Token:        &oauth2.Token{AccessToken: "token-alice"},
+		}))
+
+		links, err := repo.GetSlackUserLinksOf(ctx, "ghe.example.com", 1)
+		require.NoError(err)
+		require.Equal(1, len(links))
+		assert.Equal("U1", links[0].UserID)
+
+		links, err = repo.GetSlackUserLinksOf(ctx, "", 1)
+		require.NoError(err)
+		require.Equal(1, len(links))
+		assert.Equal("U2", links[0].UserID)
+	})
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"net/url"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 	"github.com/pkg/errors"
@@ -13,8 +14,13 @@ import (
 )
 
 const (
-	redisKeyPrefixUser  = "user:"
-	redisKeyPrefixCheck = "check:"
+	redisKeyPrefixUser      = "user:"
+	redisKeyPrefixCheck     = "check:"
+	redisKeyOutbox          = "outbox"
+	redisKeyOutboxDue       = "outbox:due"
+	redisKeySummaryComments = "summaryComments"
+	redisKeyKnownItems      = "knownItems"
+	redisKeySlackUserLinks  = "slackUserLinks"
 )
 
 type redisCoreRepository struct {
@@ -67,14 +73,14 @@ func (r redisCoreRepository) AddUser(ctx context.Context, user prchecklist.GitHu
 			return err
 		}
 
-		_, err = conn.Do("SET", redisKeyPrefixUser+strconv.FormatInt(int64(user.ID), 10), buf)
+		_, err = conn.Do("SET", redisKeyPrefixUser+userKey(user.Host, user.ID), buf)
 		return err
 	})
 	return errors.Wrap(err, "AddUser")
 }
 
 // GetUsers implements coreRepository.GetUser.
-func (r redisCoreRepository) GetUsers(ctx context.Context, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
+func (r redisCoreRepository) GetUsers(ctx context.Context, host string, userIDs []int) (map[int]prchecklist.GitHubUser, error) {
 	users := make(map[int]prchecklist.GitHubUser, len(userIDs))
 	if len(userIDs) == 0 {
 		return users, nil
@@ -83,7 +89,7 @@ func (r redisCoreRepository) GetUsers(ctx context.Context, userIDs []int) (map[i
 	err := r.withConn(func(conn redis.Conn) error {
 		keys := make([]interface{}, len(userIDs))
 		for i, id := range userIDs {
-			keys[i] = redisKeyPrefixUser + strconv.FormatInt(int64(id), 10)
+			keys[i] = redisKeyPrefixUser + userKey(host, id)
 		}
 		bufs, err := redis.ByteSlices(conn.Do("MGET", keys...))
 		if err != nil {
@@ -197,3 +203,318 @@ func (r redisCoreRepository) RemoveCheck(ctx context.Context, clRef prchecklist.
 		return err
 	})
 }
+
+// PutOutboxNotification implements coreRepository.PutOutboxNotification.
+func (r redisCoreRepository) PutOutboxNotification(ctx context.Context, n prchecklist.OutboxNotification) error {
+	err := r.withConn(func(conn redis.Conn) error {
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+		if err := sendPutOutboxNotification(conn, &n); err != nil {
+			return err
+		}
+		_, err := conn.Do("EXEC")
+		return err
+	})
+	return errors.Wrap(err, "PutOutboxNotification")
+}
+
+// sendPutOutboxNotification queues the commands to store n in the outbox,
+// and to index pending ones by the time of the next attempt in the sorted set.
+func sendPutOutboxNotification(conn redis.Conn, n *prchecklist.OutboxNotification) error {
+	buf, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Send("HSET", redisKeyOutbox, n.ID, buf); err != nil {
+		return err
+	}
+	if n.State == prchecklist.OutboxStatePending {
+		return conn.Send("ZADD", redisKeyOutboxDue, redisOutboxScore(n.NextAttemptAt), n.ID)
+	}
+	return conn.Send("ZREM", redisKeyOutboxDue, n.ID)
+}
+
+func redisOutboxScore(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// redisOutboxMaxRetries is the number of retries of updateOutbox when the outbox is modified concurrently.
+const redisOutboxMaxRetries = 10
+
+// updateOutbox stores the notifications update returns, only if the outbox is not modified
+// since update started reading it, retrying otherwise.
+func (r redisCoreRepository) updateOutbox(conn redis.Conn, update func() ([]*prchecklist.OutboxNotification, error)) error {
+	for i := 0; i < redisOutboxMaxRetries; i++ {
+		if _, err := conn.Do("WATCH", redisKeyOutbox); err != nil {
+			return err
+		}
+
+		notifications, err := update()
+		if err != nil || len(notifications) == 0 {
+			if _, err := conn.Do("UNWATCH"); err != nil {
+				return err
+			}
+			return err
+		}
+
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if err := sendPutOutboxNotification(conn, n); err != nil {
+				return err
+			}
+		}
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			return nil
+		}
+	}
+
+	return errors.New("outbox is modified concurrently")
+}
+
+// GetOutboxNotification implements coreRepository.GetOutboxNotification.
+func (r redisCoreRepository) GetOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error) {
+	var n *prchecklist.OutboxNotification
+	err := r.withConn(func(conn redis.Conn) error {
+		buf, err := redis.Bytes(conn.Do("HGET", redisKeyOutbox, id))
+		if err == redis.ErrNil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(buf, &n)
+	})
+	return n, errors.Wrap(err, "GetOutboxNotification")
+}
+
+// GetOutboxNotifications implements coreRepository.GetOutboxNotifications.
+func (r redisCoreRepository) GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
+	notifications := []*prchecklist.OutboxNotification{}
+	err := r.withConn(func(conn redis.Conn) error {
+		bufs, err := redis.ByteSlices(conn.Do("HVALS", redisKeyOutbox))
+		if err != nil {
+			return err
+		}
+
+		for _, buf := range bufs {
+			var n prchecklist.OutboxNotification
+			if err := json.Unmarshal(buf, &n); err != nil {
+				return err
+			}
+			if n.State == state {
+				notifications = append(notifications, &n)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID < notifications[j].ID
+	})
+
+	return notifications, errors.Wrap(err, "GetOutboxNotifications")
+}
+
+// ClaimOutboxNotifications implements coreRepository.ClaimOutboxNotifications.
+func (r redisCoreRepository) ClaimOutboxNotifications(ctx context.Context, now time.Time, lease time.Duration) ([]*prchecklist.OutboxNotification, error) {
+	var notifications []*prchecklist.OutboxNotification
+	err := r.withConn(func(conn redis.Conn) error {
+		return r.updateOutbox(conn, func() ([]*prchecklist.OutboxNotification, error) {
+			notifications = []*prchecklist.OutboxNotification{}
+
+			ids, err := redis.Values(conn.Do("ZRANGEBYSCORE", redisKeyOutboxDue, "-inf", redisOutboxScore(now)))
+			if err != nil || len(ids) == 0 {
+				return nil, err
+			}
+
+			bufs, err := redis.ByteSlices(conn.Do("HMGET", redis.Args{redisKeyOutbox}.Add(ids...)...))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, buf := range bufs {
+				if buf == nil {
+					continue
+				}
+
+				var n prchecklist.OutboxNotification
+				if err := json.Unmarshal(buf, &n); err != nil {
+					return nil, err
+				}
+				if claimOutboxNotification(&n, now, lease) {
+					notifications = append(notifications, &n)
+				}
+			}
+
+			return notifications, nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "ClaimOutboxNotifications")
+	}
+	return notifications, nil
+}
+
+// UpdateOutboxNotification implements coreRepository.UpdateOutboxNotification.
+func (r redisCoreRepository) UpdateOutboxNotification(ctx context.Context, id string, update func(n *prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+	var n *prchecklist.OutboxNotification
+	err := r.withConn(func(conn redis.Conn) error {
+		return r.updateOutbox(conn, func() ([]*prchecklist.OutboxNotification, error) {
+			n = nil
+
+			buf, err := redis.Bytes(conn.Do("HGET", redisKeyOutbox, id))
+			if err == redis.ErrNil {
+				return nil, nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			if err := json.Unmarshal(buf, &n); err != nil {
+				return nil, err
+			}
+			if err := update(n); err != nil {
+				return nil, err
+			}
+
+			return []*prchecklist.OutboxNotification{n}, nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "UpdateOutboxNotification")
+	}
+	return n, nil
+}
+
+// RemoveOutboxNotification implements coreRepository.RemoveOutboxNotification.
+func (r redisCoreRepository) RemoveOutboxNotification(ctx context.Context, id string) error {
+	err := r.withConn(func(conn redis.Conn) error {
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+		if err := conn.Send("HDEL", redisKeyOutbox, id); err != nil {
+			return err
+		}
+		if err := conn.Send("ZREM", redisKeyOutboxDue, id); err != nil {
+			return err
+		}
+		_, err := conn.Do("EXEC")
+		return err
+	})
+	return errors.Wrap(err, "RemoveOutboxNotification")
+}
+
+// GetSummaryCommentID implements coreRepository.GetSummaryCommentID.
+func (r redisCoreRepository) GetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef) (int64, error) {
+	var id int64
+	err := r.withConn(func(conn redis.Conn) error {
+		var err error
+		id, err = redis.Int64(conn.Do("HGET", redisKeySummaryComments, summaryCommentKey(ref)))
+		if err == redis.ErrNil {
+			return nil
+		}
+		return err
+	})
+	return id, errors.Wrap(err, "GetSummaryCommentID")
+}
+
+// SetSummaryCommentID implements coreRepository.SetSummaryCommentID.
+func (r redisCoreRepository) SetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef, commentID int64) error {
+	err := r.withConn(func(conn redis.Conn) error {
+		_, err := conn.Do("HSET", redisKeySummaryComments, summaryCommentKey(ref), commentID)
+		return err
+	})
+	return errors.Wrap(err, "SetSummaryCommentID")
+}
+
+// GetKnownItemNumbers implements coreRepository.GetKnownItemNumbers.
+func (r redisCoreRepository) GetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef) ([]int, error) {
+	var numbers []int
+	err := r.withConn(func(conn redis.Conn) error {
+		buf, err := redis.Bytes(conn.Do("HGET", redisKeyKnownItems, clRef.String()))
+		if err == redis.ErrNil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(buf, &numbers)
+	})
+	return numbers, errors.Wrap(err, "GetKnownItemNumbers")
+}
+
+// SetKnownItemNumbers implements coreRepository.SetKnownItemNumbers.
+func (r redisCoreRepository) SetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) error {
+	err := r.withConn(func(conn redis.Conn) error {
+		buf, err := json.Marshal(knownItemNumbers(numbers))
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Do("HSET", redisKeyKnownItems, clRef.String(), buf)
+		return err
+	})
+	return errors.Wrap(err, "SetKnownItemNumbers")
+}
+
+// GetSlackUserLink implements coreRepository.GetSlackUserLink.
+func (r redisCoreRepository) GetSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error) {
+	var link *prchecklist.SlackUserLink
+	err := r.withConn(func(conn redis.Conn) error {
+		buf, err := redis.Bytes(conn.Do("HGET", redisKeySlackUserLinks, slackUserLinkKey(teamID, userID)))
+		if err == redis.ErrNil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(buf, &link)
+	})
+	return link, errors.Wrap(err, "GetSlackUserLink")
+}
+
+// PutSlackUserLink implements coreRepository.PutSlackUserLink.
+func (r redisCoreRepository) PutSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error {
+	err := r.withConn(func(conn redis.Conn) error {
+		buf, err := json.Marshal(link)
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Do("HSET", redisKeySlackUserLinks, slackUserLinkKey(link.TeamID, link.UserID), buf)
+		return err
+	})
+	return errors.Wrap(err, "PutSlackUserLink")
+}
+
+// GetSlackUserLinksOf implements coreRepository.GetSlackUserLinksOf.
+// Links are scanned, which are few.
+func (r redisCoreRepository) GetSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error) {
+	var links []prchecklist.SlackUserLink
+	err := r.withConn(func(conn redis.Conn) error {
+		bufs, err := redis.ByteSlices(conn.Do("HVALS", redisKeySlackUserLinks))
+		if err != nil {
+			return err
+		}
+
+		for _, buf := range bufs {
+			var link prchecklist.SlackUserLink
+			if err := json.Unmarshal(buf, &link); err != nil {
+				return err
+			}
+			if link.GitHubHost == host && link.GitHubUserID == githubUserID {
+				links = append(links, link)
+			}
+		}
+		return nil
+	})
+	return links, errors.Wrap(err, "GetSlackUserLinksOf")
+}
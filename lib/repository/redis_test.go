@@ -24,4 +24,8 @@ func TestRedisRepository(t *testing.T) {
 
 	testUsers(t, repo)
 	testChecks(t, repo)
+	testOutbox(t, repo)
+	testSummaryComment(t, repo)
+	testKnownItems(t, repo)
+	testSlackUserLinks(t, repo)
 }
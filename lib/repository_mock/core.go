@@ -6,35 +6,37 @@ package repository_mock
 
 import (
 	context "context"
+	reflect "reflect"
+	time "time"
+
 	gomock "github.com/golang/mock/gomock"
 	prchecklist "github.com/motemen/prchecklist/v2"
-	reflect "reflect"
 )
 
-// MockCoreRepository is a mock of CoreRepository interface
+// MockCoreRepository is a mock of CoreRepository interface.
 type MockCoreRepository struct {
 	ctrl     *gomock.Controller
 	recorder *MockCoreRepositoryMockRecorder
 }
 
-// MockCoreRepositoryMockRecorder is the mock recorder for MockCoreRepository
+// MockCoreRepositoryMockRecorder is the mock recorder for MockCoreRepository.
 type MockCoreRepositoryMockRecorder struct {
 	mock *MockCoreRepository
 }
 
-// NewMockCoreRepository creates a new mock instance
+// NewMockCoreRepository creates a new mock instance.
 func NewMockCoreRepository(ctrl *gomock.Controller) *MockCoreRepository {
 	mock := &MockCoreRepository{ctrl: ctrl}
 	mock.recorder = &MockCoreRepositoryMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockCoreRepository) EXPECT() *MockCoreRepositoryMockRecorder {
 	return m.recorder
 }
 
-// AddCheck mocks base method
+// AddCheck mocks base method.
 func (m *MockCoreRepository) AddCheck(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 string, arg3 prchecklist.GitHubUser) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddCheck", arg0, arg1, arg2, arg3)
@@ -42,13 +44,13 @@ func (m *MockCoreRepository) AddCheck(arg0 context.Context, arg1 prchecklist.Che
 	return ret0
 }
 
-// AddCheck indicates an expected call of AddCheck
+// AddCheck indicates an expected call of AddCheck.
 func (mr *MockCoreRepositoryMockRecorder) AddCheck(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCheck", reflect.TypeOf((*MockCoreRepository)(nil).AddCheck), arg0, arg1, arg2, arg3)
 }
 
-// AddUser mocks base method
+// AddUser mocks base method.
 func (m *MockCoreRepository) AddUser(arg0 context.Context, arg1 prchecklist.GitHubUser) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddUser", arg0, arg1)
@@ -56,13 +58,28 @@ func (m *MockCoreRepository) AddUser(arg0 context.Context, arg1 prchecklist.GitH
 	return ret0
 }
 
-// AddUser indicates an expected call of AddUser
+// AddUser indicates an expected call of AddUser.
 func (mr *MockCoreRepositoryMockRecorder) AddUser(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockCoreRepository)(nil).AddUser), arg0, arg1)
 }
 
-// GetChecks mocks base method
+// ClaimOutboxNotifications mocks base method.
+func (m *MockCoreRepository) ClaimOutboxNotifications(arg0 context.Context, arg1 time.Time, arg2 time.Duration) ([]*prchecklist.OutboxNotification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOutboxNotifications", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*prchecklist.OutboxNotification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOutboxNotifications indicates an expected call of ClaimOutboxNotifications.
+func (mr *MockCoreRepositoryMockRecorder) ClaimOutboxNotifications(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOutboxNotifications", reflect.TypeOf((*MockCoreRepository)(nil).ClaimOutboxNotifications), arg0, arg1, arg2)
+}
+
+// GetChecks mocks base method.
 func (m *MockCoreRepository) GetChecks(arg0 context.Context, arg1 prchecklist.ChecklistRef) (prchecklist.Checks, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetChecks", arg0, arg1)
@@ -71,28 +88,146 @@ func (m *MockCoreRepository) GetChecks(arg0 context.Context, arg1 prchecklist.Ch
 	return ret0, ret1
 }
 
-// GetChecks indicates an expected call of GetChecks
+// GetChecks indicates an expected call of GetChecks.
 func (mr *MockCoreRepositoryMockRecorder) GetChecks(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChecks", reflect.TypeOf((*MockCoreRepository)(nil).GetChecks), arg0, arg1)
 }
 
-// GetUsers mocks base method
-func (m *MockCoreRepository) GetUsers(arg0 context.Context, arg1 []int) (map[int]prchecklist.GitHubUser, error) {
+// GetKnownItemNumbers mocks base method.
+func (m *MockCoreRepository) GetKnownItemNumbers(arg0 context.Context, arg1 prchecklist.ChecklistRef) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKnownItemNumbers", arg0, arg1)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKnownItemNumbers indicates an expected call of GetKnownItemNumbers.
+func (mr *MockCoreRepositoryMockRecorder) GetKnownItemNumbers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKnownItemNumbers", reflect.TypeOf((*MockCoreRepository)(nil).GetKnownItemNumbers), arg0, arg1)
+}
+
+// GetOutboxNotification mocks base method.
+func (m *MockCoreRepository) GetOutboxNotification(arg0 context.Context, arg1 string) (*prchecklist.OutboxNotification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOutboxNotification", arg0, arg1)
+	ret0, _ := ret[0].(*prchecklist.OutboxNotification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOutboxNotification indicates an expected call of GetOutboxNotification.
+func (mr *MockCoreRepositoryMockRecorder) GetOutboxNotification(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutboxNotification", reflect.TypeOf((*MockCoreRepository)(nil).GetOutboxNotification), arg0, arg1)
+}
+
+// GetOutboxNotifications mocks base method.
+func (m *MockCoreRepository) GetOutboxNotifications(arg0 context.Context, arg1 prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetOutboxNotifications", arg0, arg1)
+	ret0, _ := ret[0].([]*prchecklist.OutboxNotification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOutboxNotifications indicates an expected call of GetOutboxNotifications.
+func (mr *MockCoreRepositoryMockRecorder) GetOutboxNotifications(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutboxNotifications", reflect.TypeOf((*MockCoreRepository)(nil).GetOutboxNotifications), arg0, arg1)
+}
+
+// GetSlackUserLink mocks base method.
+func (m *MockCoreRepository) GetSlackUserLink(arg0 context.Context, arg1, arg2 string) (*prchecklist.SlackUserLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackUserLink", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*prchecklist.SlackUserLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSlackUserLink indicates an expected call of GetSlackUserLink.
+func (mr *MockCoreRepositoryMockRecorder) GetSlackUserLink(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackUserLink", reflect.TypeOf((*MockCoreRepository)(nil).GetSlackUserLink), arg0, arg1, arg2)
+}
+
+// GetSlackUserLinksOf mocks base method.
+func (m *MockCoreRepository) GetSlackUserLinksOf(arg0 context.Context, arg1 string, arg2 int) ([]prchecklist.SlackUserLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackUserLinksOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]prchecklist.SlackUserLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSlackUserLinksOf indicates an expected call of GetSlackUserLinksOf.
+func (mr *MockCoreRepositoryMockRecorder) GetSlackUserLinksOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackUserLinksOf", reflect.TypeOf((*MockCoreRepository)(nil).GetSlackUserLinksOf), arg0, arg1, arg2)
+}
+
+// GetSummaryCommentID mocks base method.
+func (m *MockCoreRepository) GetSummaryCommentID(arg0 context.Context, arg1 prchecklist.ChecklistRef) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSummaryCommentID", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSummaryCommentID indicates an expected call of GetSummaryCommentID.
+func (mr *MockCoreRepositoryMockRecorder) GetSummaryCommentID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSummaryCommentID", reflect.TypeOf((*MockCoreRepository)(nil).GetSummaryCommentID), arg0, arg1)
+}
+
+// GetUsers mocks base method.
+func (m *MockCoreRepository) GetUsers(arg0 context.Context, arg1 string, arg2 []int) (map[int]prchecklist.GitHubUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", arg0, arg1, arg2)
 	ret0, _ := ret[0].(map[int]prchecklist.GitHubUser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUsers indicates an expected call of GetUsers
-func (mr *MockCoreRepositoryMockRecorder) GetUsers(arg0, arg1 interface{}) *gomock.Call {
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockCoreRepositoryMockRecorder) GetUsers(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockCoreRepository)(nil).GetUsers), arg0, arg1, arg2)
+}
+
+// PutOutboxNotification mocks base method.
+func (m *MockCoreRepository) PutOutboxNotification(arg0 context.Context, arg1 prchecklist.OutboxNotification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutOutboxNotification", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutOutboxNotification indicates an expected call of PutOutboxNotification.
+func (mr *MockCoreRepositoryMockRecorder) PutOutboxNotification(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockCoreRepository)(nil).GetUsers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutOutboxNotification", reflect.TypeOf((*MockCoreRepository)(nil).PutOutboxNotification), arg0, arg1)
 }
 
-// RemoveCheck mocks base method
+// PutSlackUserLink mocks base method.
+func (m *MockCoreRepository) PutSlackUserLink(arg0 context.Context, arg1 prchecklist.SlackUserLink) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutSlackUserLink", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutSlackUserLink indicates an expected call of PutSlackUserLink.
+func (mr *MockCoreRepositoryMockRecorder) PutSlackUserLink(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutSlackUserLink", reflect.TypeOf((*MockCoreRepository)(nil).PutSlackUserLink), arg0, arg1)
+}
+
+// RemoveCheck mocks base method.
 func (m *MockCoreRepository) RemoveCheck(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 string, arg3 prchecklist.GitHubUser) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "RemoveCheck", arg0, arg1, arg2, arg3)
@@ -100,8 +235,65 @@ func (m *MockCoreRepository) RemoveCheck(arg0 context.Context, arg1 prchecklist.
 	return ret0
 }
 
-// RemoveCheck indicates an expected call of RemoveCheck
+// RemoveCheck indicates an expected call of RemoveCheck.
 func (mr *MockCoreRepositoryMockRecorder) RemoveCheck(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveCheck", reflect.TypeOf((*MockCoreRepository)(nil).RemoveCheck), arg0, arg1, arg2, arg3)
 }
+
+// RemoveOutboxNotification mocks base method.
+func (m *MockCoreRepository) RemoveOutboxNotification(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveOutboxNotification", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveOutboxNotification indicates an expected call of RemoveOutboxNotification.
+func (mr *MockCoreRepositoryMockRecorder) RemoveOutboxNotification(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveOutboxNotification", reflect.TypeOf((*MockCoreRepository)(nil).RemoveOutboxNotification), arg0, arg1)
+}
+
+// SetKnownItemNumbers mocks base method.
+func (m *MockCoreRepository) SetKnownItemNumbers(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetKnownItemNumbers", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetKnownItemNumbers indicates an expected call of SetKnownItemNumbers.
+func (mr *MockCoreRepositoryMockRecorder) SetKnownItemNumbers(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetKnownItemNumbers", reflect.TypeOf((*MockCoreRepository)(nil).SetKnownItemNumbers), arg0, arg1, arg2)
+}
+
+// SetSummaryCommentID mocks base method.
+func (m *MockCoreRepository) SetSummaryCommentID(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSummaryCommentID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSummaryCommentID indicates an expected call of SetSummaryCommentID.
+func (mr *MockCoreRepositoryMockRecorder) SetSummaryCommentID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSummaryCommentID", reflect.TypeOf((*MockCoreRepository)(nil).SetSummaryCommentID), arg0, arg1, arg2)
+}
+
+// UpdateOutboxNotification mocks base method.
+func (m *MockCoreRepository) UpdateOutboxNotification(arg0 context.Context, arg1 string, arg2 func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOutboxNotification", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*prchecklist.OutboxNotification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOutboxNotification indicates an expected call of UpdateOutboxNotification.
+func (mr *MockCoreRepositoryMockRecorder) UpdateOutboxNotification(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOutboxNotification", reflect.TypeOf((*MockCoreRepository)(nil).UpdateOutboxNotification), arg0, arg1, arg2)
+}
@@ -0,0 +1,27 @@
+// Package signature provides HMAC-SHA256 signatures
+// shared by OAuth callback forwarding and webhook payloads.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sign returns the HMAC-SHA256 of message with secret.
+func Sign(secret, message []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// SignHex returns the hex-encoded HMAC-SHA256 of message with secret.
+func SignHex(secret, message []byte) string {
+	return hex.EncodeToString(Sign(secret, message))
+}
+
+// Verify reports whether sig is a valid HMAC-SHA256 of message with secret,
+// in constant time.
+func Verify(secret, message, sig []byte) bool {
+	return hmac.Equal(Sign(secret, message), sig)
+}
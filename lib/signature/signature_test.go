@@ -0,0 +1,27 @@
+package signature
+
+import "testing"
+
+func TestSignHex(t *testing.T) {
+	// echo -n 'Hello, World!' | openssl dgst -sha256 -hmac "It's a Secret to Everybody"
+	got := SignHex([]byte("It's a Secret to Everybody"), []byte("Hello, World!"))
+	expected := "757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != expected {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	sig := Sign(secret, []byte("message"))
+
+	if !Verify(secret, []byte("message"), sig) {
+		t.Error("should be valid")
+	}
+	if Verify(secret, []byte("message2"), sig) {
+		t.Error("should be invalid")
+	}
+	if Verify([]byte("secret2"), []byte("message"), sig) {
+		t.Error("should be invalid")
+	}
+}
@@ -0,0 +1,336 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+type digestKey struct {
+	checklist string
+	channel   string
+}
+
+func outboxDigestKey(n *prchecklist.OutboxNotification) digestKey {
+	return digestKey{checklist: n.Checklist, channel: n.Channel}
+}
+
+// digestEntry is an event collected for a digest,
+// persisted as the body of a notification in the outbox in the state prchecklist.OutboxStateDigest
+// until the digest is sent.
+type digestEntry struct {
+	Event string `json:"event"`
+	// User is the login of the user of the event,
+	// or the mention of them for on_complete_checks_of_user.
+	User string `json:"user,omitempty"`
+	// Line describes the event in the digest,
+	// rendered by the template for the event if configured.
+	Line string `json:"line"`
+	// Format is the format of the channel at the event.
+	Format string `json:"format"`
+	// Link, Header and Remaining describe the checklist at the event.
+	Link      string       `json:"link"`
+	Header    []slackBlock `json:"header,omitempty"`
+	Remaining []slackBlock `json:"remaining,omitempty"`
+}
+
+// addDigestEvent puts event to the outbox to be sent in the digest for the channel chName of checklist.
+// The digest is sent after the window of the channel passes since its first event.
+func (u Usecase) addDigestEvent(ctx context.Context, checklist *prchecklist.Checklist, chName string, ch prchecklist.NotificationChannel, m slackMentions, event notificationEvent) error {
+	window, err := time.ParseDuration(ch.Digest)
+	if err != nil {
+		return err
+	}
+
+	entry := digestEntry{
+		Event:     event.eventType().String(),
+		Line:      digestLine(event, m),
+		Format:    ch.Format,
+		Link:      fmt.Sprintf("<%s|%s>", prchecklist.BuildURL(ctx, checklist.Path()), checklist),
+		Header:    slackChecklistHeaderBlocks(ctx, checklist),
+		Remaining: slackRemainingBlocks(checklist),
+	}
+	switch e := event.(type) {
+	case addCheckEvent:
+		entry.User = e.user.Login
+	case removeCheckEvent:
+		entry.User = e.user.Login
+	case completeChecksOfUserEvent:
+		entry.User = m.mention(e.user.Login)
+	}
+
+	text, ok, err := renderNotificationTemplate(ctx, checklist.Config, ch, event, m)
+	if err != nil {
+		log.Printf("rendering notification template: %s", err)
+	}
+	if ok {
+		entry.Line = text
+	}
+
+	body, err := json.Marshal(&entry)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	now := time.Now()
+	n := newOutboxNotification(checklist, chName, ch, "application/json", body, now)
+	n.State = prchecklist.OutboxStateDigest
+	n.NextAttemptAt = now.Add(window)
+	return u.coreRepo.PutOutboxNotification(ctx, n)
+}
+
+// digestLine describes event in a line of a digest.
+func digestLine(event notificationEvent, m slackMentions) string {
+	switch e := event.(type) {
+	case addCheckEvent:
+		return fmt.Sprintf(":white_check_mark: %s checked by %s", slackItemText(e.item), slackEscape(e.user.Login))
+	case removeCheckEvent:
+		return fmt.Sprintf(":heavy_multiplication_x: %s check removed by %s", slackItemText(e.item), slackEscape(e.user.Login))
+	case completeChecksOfUserEvent:
+		return fmt.Sprintf(":ballot_box_with_check: completed checks of %s", m.mention(e.user.Login))
+	case itemAddedEvent:
+		return fmt.Sprintf(":new: %s added", slackItemText(e.item))
+	case itemRemovedEvent:
+		return fmt.Sprintf(":wastebasket: %s removed", slackItemText(e.item))
+	default:
+		return ""
+	}
+}
+
+// flushDigest sends the digest for the channel chName of checklist immediately, if any.
+func (u Usecase) flushDigest(ctx context.Context, checklist *prchecklist.Checklist, chName string) error {
+	key := digestKey{checklist: checklist.String(), channel: chName}
+	return u.flushDigests(ctx, func(k digestKey, _ []*prchecklist.OutboxNotification) bool {
+		return k == key
+	})
+}
+
+// flushDueDigests sends the digests whose windows have passed at now.
+func (u Usecase) flushDueDigests(ctx context.Context, now time.Time) error {
+	return u.flushDigests(ctx, func(_ digestKey, entries []*prchecklist.OutboxNotification) bool {
+		for _, n := range entries {
+			if !n.NextAttemptAt.After(now) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// flushDigests sends the digests in the outbox selected by the function given.
+func (u Usecase) flushDigests(ctx context.Context, selected func(digestKey, []*prchecklist.OutboxNotification) bool) error {
+	notifications, err := u.coreRepo.GetOutboxNotifications(ctx, prchecklist.OutboxStateDigest)
+	if err != nil {
+		return errors.Wrap(err, "GetOutboxNotifications")
+	}
+
+	keys := []digestKey{}
+	entriesByKey := map[digestKey][]*prchecklist.OutboxNotification{}
+	for _, n := range notifications {
+		key := outboxDigestKey(n)
+		if _, ok := entriesByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		entriesByKey[key] = append(entriesByKey[key], n)
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !selected(key, entriesByKey[key]) {
+			continue
+		}
+		if err := u.sendDigest(ctx, entriesByKey[key]); err != nil {
+			log.Printf("sendDigest(%v): %s", key, err)
+		}
+	}
+
+	return nil
+}
+
+// sendDigest enqueues the digest of the entries, which are for the same checklist and channel,
+// and removes them from the outbox.
+// Each entry is leased first not to be sent by other processes twice.
+func (u Usecase) sendDigest(ctx context.Context, entries []*prchecklist.OutboxNotification) error {
+	now := time.Now()
+
+	var claimed []*prchecklist.OutboxNotification
+	for _, entry := range entries {
+		n, err := u.coreRepo.UpdateOutboxNotification(ctx, entry.ID, func(n *prchecklist.OutboxNotification) error {
+			if n.State != prchecklist.OutboxStateDigest || n.Leased(now) {
+				return prchecklist.ErrOutboxNotificationLeased
+			}
+			n.LeasedUntil = now.Add(outboxLeaseDuration)
+			return nil
+		})
+		if errors.Is(err, prchecklist.ErrOutboxNotificationLeased) {
+			continue
+		} else if err != nil {
+			return errors.Wrap(err, "UpdateOutboxNotification")
+		}
+		if n != nil {
+			claimed = append(claimed, n)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	var d digest
+	for _, n := range claimed {
+		var entry digestEntry
+		if err := json.Unmarshal(n.Body, &entry); err != nil {
+			log.Printf("digest entry %s: %s", n.ID, err)
+			continue
+		}
+		d = append(d, entry)
+	}
+
+	if len(d) > 0 {
+		message := slackMessagePayload{
+			Text: d.slackMessageText(),
+		}
+		if d[len(d)-1].Format != prchecklist.NotificationFormatText {
+			message.Blocks = d.slackMessageBlocks()
+		}
+
+		payload, err := json.Marshal(&message)
+		if err != nil {
+			return errors.Wrap(err, "json.Marshal")
+		}
+
+		n := *claimed[len(claimed)-1]
+		n.ID = prchecklist.NewOutboxID(now)
+		n.ContentType = "application/x-www-form-urlencoded"
+		n.Body = []byte(url.Values{"payload": {string(payload)}}.Encode())
+		n.State = prchecklist.OutboxStatePending
+		n.Attempts = 0
+		n.NextAttemptAt = now
+		n.LeasedUntil = time.Time{}
+		n.LastError = ""
+		n.CreatedAt = now
+		if err := u.coreRepo.PutOutboxNotification(ctx, n); err != nil {
+			// Left to be sent again after the lease
+			return errors.Wrap(err, "PutOutboxNotification")
+		}
+		u.wakeOutbox()
+	}
+
+	for _, n := range claimed {
+		if err := u.coreRepo.RemoveOutboxNotification(ctx, n.ID); err != nil {
+			log.Printf("RemoveOutboxNotification(%s): %s", n.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// FlushNotificationDigests sends the digests whose windows have passed, until ctx is done.
+// Call this on shutdown not to delay them to the next run.
+// The other digests are left in the outbox, to be sent by the next run.
+func (u Usecase) FlushNotificationDigests(ctx context.Context) {
+	if err := u.flushDueDigests(ctx, time.Now()); err != nil {
+		log.Printf("flushDueDigests: %s", err)
+	}
+}
+
+// digest is the entries of a digest in the order of the events.
+type digest []digestEntry
+
+// summary describes the events in the digest in a line,
+// like "3 items checked by alice, bob; 1 check removed by carol".
+func (d digest) summary() string {
+	var (
+		checked, removed, itemsAdded, itemsRemoved int
+		checkers, removers, completers             loginList
+	)
+	for _, entry := range d {
+		switch entry.Event {
+		case eventTypeOnCheck.String():
+			checked++
+			checkers.add(entry.User)
+		case eventTypeOnRemove.String():
+			removed++
+			removers.add(entry.User)
+		case eventTypeOnCompleteChecksOfUser.String():
+			completers.add(entry.User)
+		case eventTypeOnItemAdded.String():
+			itemsAdded++
+		case eventTypeOnItemRemoved.String():
+			itemsRemoved++
+		}
+	}
+
+	parts := []string{}
+	if checked > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s checked by %s", checked, plural(checked, "item", "items"), checkers))
+	}
+	if removed > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s removed by %s", removed, plural(removed, "check", "checks"), removers))
+	}
+	if len(completers) > 0 {
+		parts = append(parts, fmt.Sprintf("completed checks of %s", completers))
+	}
+	if itemsAdded > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s added", itemsAdded, plural(itemsAdded, "item", "items")))
+	}
+	if itemsRemoved > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s removed", itemsRemoved, plural(itemsRemoved, "item", "items")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// lines lists the lines of the events after the summary.
+func (d digest) lines() string {
+	var text strings.Builder
+	text.WriteString(d.summary())
+	for _, entry := range d {
+		if entry.Line != "" {
+			text.WriteString("\n")
+			text.WriteString(entry.Line)
+		}
+	}
+	return text.String()
+}
+
+func (d digest) slackMessageText() string {
+	return fmt.Sprintf("[%s] %s", d[len(d)-1].Link, d.lines())
+}
+
+func (d digest) slackMessageBlocks() []slackBlock {
+	last := d[len(d)-1]
+	blocks := append(append([]slackBlock{}, last.Header...), slackSectionBlock(d.lines()))
+	return append(blocks, last.Remaining...)
+}
+
+// loginList is a list of unique logins, formatted as "alice, bob".
+type loginList []string
+
+func (l *loginList) add(login string) {
+	for _, s := range *l {
+		if s == login {
+			return
+		}
+	}
+	*l = append(*l, login)
+}
+
+func (l loginList) String() string {
+	return strings.Join(l, ", ")
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
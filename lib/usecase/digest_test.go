@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+// testDigestOutbox is an outbox in memory behind a mock repository.
+type testDigestOutbox struct {
+	mu            sync.Mutex
+	notifications []prchecklist.OutboxNotification
+}
+
+func newTestDigestOutbox(repo *repository_mock.MockCoreRepository) *testDigestOutbox {
+	o := &testDigestOutbox{}
+
+	repo.EXPECT().PutOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, n prchecklist.OutboxNotification) error {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		for i := range o.notifications {
+			if o.notifications[i].ID == n.ID {
+				o.notifications[i] = n
+				return nil
+			}
+		}
+		o.notifications = append(o.notifications, n)
+		return nil
+	}).AnyTimes()
+	repo.EXPECT().GetOutboxNotifications(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
+		return o.get(state), nil
+	}).AnyTimes()
+	repo.EXPECT().UpdateOutboxNotification(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string, update func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		for i := range o.notifications {
+			if o.notifications[i].ID == id {
+				n := o.notifications[i]
+				if err := update(&n); err != nil {
+					return nil, err
+				}
+				o.notifications[i] = n
+				return &n, nil
+			}
+		}
+		return nil, nil
+	}).AnyTimes()
+	repo.EXPECT().RemoveOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) error {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		for i := range o.notifications {
+			if o.notifications[i].ID == id {
+				o.notifications = append(o.notifications[:i], o.notifications[i+1:]...)
+				break
+			}
+		}
+		return nil
+	}).AnyTimes()
+
+	return o
+}
+
+func (o *testDigestOutbox) get(state prchecklist.OutboxState) []*prchecklist.OutboxNotification {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var notifications []*prchecklist.OutboxNotification
+	for _, n := range o.notifications {
+		if n.State == state {
+			n := n
+			notifications = append(notifications, &n)
+		}
+	}
+	return notifications
+}
+
+func (o *testDigestOutbox) payloads(t *testing.T) []string {
+	t.Helper()
+
+	var payloads []string
+	for _, n := range o.get(prchecklist.OutboxStatePending) {
+		form, err := url.ParseQuery(string(n.Body))
+		require.NoError(t, err)
+		payloads = append(payloads, form.Get("payload"))
+	}
+	return payloads
+}
+
+// expire makes the digest entries due.
+func (o *testDigestOutbox) expire() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.notifications {
+		o.notifications[i].NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+func TestUsecase_notifyEvent_digest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	outbox := newTestDigestOutbox(repo)
+
+	cl := makeNotificationTestChecklist()
+	cl.Commits = []prchecklist.Commit{{Oid: "deadbeef"}}
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Events.OnCheck = []string{"digest"}
+	cl.Config.Notification.Events.OnRemove = []string{"digest"}
+	cl.Config.Notification.Events.OnComplete = []string{"digest"}
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"digest": {URL: "https://hooks.slack.com/services/XXX", Format: prchecklist.NotificationFormatText, Digest: "1h"},
+	}
+
+	app := New(github, repo)
+	ctx := notificationTestContext()
+
+	events := []notificationEvent{
+		addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}},
+		addCheckEvent{checklist: cl, item: cl.Item(3), user: prchecklist.GitHubUser{Login: "bob"}},
+		addCheckEvent{checklist: cl, item: cl.Item(4), user: prchecklist.GitHubUser{Login: "alice"}},
+		removeCheckEvent{checklist: cl, item: cl.Item(3), user: prchecklist.GitHubUser{Login: "carol"}},
+	}
+	for _, event := range events {
+		require.NoError(t, app.notifyEvent(ctx, cl, event))
+	}
+
+	entries := outbox.get(prchecklist.OutboxStateDigest)
+	require.Equal(t, 4, len(entries))
+	assert.Equal(t, "digest", entries[0].Channel)
+	assert.True(t, entries[0].NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+	assert.Empty(t, outbox.payloads(t))
+
+	require.NoError(t, app.notifyEvent(ctx, cl, completeEvent{checklist: cl}))
+
+	payloads := outbox.payloads(t)
+	require.Equal(t, 2, len(payloads))
+	assert.Contains(t, payloads[0], "3 items checked by alice, bob; 1 check removed by carol")
+	assert.Contains(t, payloads[0], "Feature B")
+	assert.NotContains(t, payloads[0], `"blocks"`)
+	assert.Contains(t, payloads[1], "Checklist completed!")
+
+	// nothing left
+	assert.Empty(t, outbox.get(prchecklist.OutboxStateDigest))
+}
+
+func TestUsecase_FlushNotificationDigests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	outbox := newTestDigestOutbox(repo)
+
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Events.OnCheck = []string{"digest"}
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"digest": {URL: "https://hooks.slack.com/services/XXX", Format: prchecklist.NotificationFormatBlocks, Digest: "1h"},
+	}
+
+	app := New(nil, repo)
+	ctx := notificationTestContext()
+
+	require.NoError(t, app.notifyEvent(ctx, cl, addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}}))
+
+	// Not due yet
+	app.FlushNotificationDigests(ctx)
+	assert.Empty(t, outbox.payloads(t))
+
+	outbox.expire()
+
+	// Bounded by the context
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	app.FlushNotificationDigests(canceled)
+	assert.Empty(t, outbox.payloads(t))
+
+	app.FlushNotificationDigests(ctx)
+
+	payloads := outbox.payloads(t)
+	require.Equal(t, 1, len(payloads))
+	assert.Contains(t, payloads[0], "1 item checked by alice")
+	assert.Contains(t, payloads[0], `"blocks"`)
+	assert.Empty(t, outbox.get(prchecklist.OutboxStateDigest))
+}
+
+func TestUsecase_notifyEvent_digestTemplates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	outbox := newTestDigestOutbox(repo)
+
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Events.OnCheck = []string{"digest"}
+	cl.Config.Notification.Events.OnItemAdded = []string{"digest"}
+	cl.Config.Notification.Templates.OnItemAdded = `{{ .Item.Title }} joined`
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"digest": {
+			URL:       "https://hooks.slack.com/services/XXX",
+			Format:    prchecklist.NotificationFormatBlocks,
+			Digest:    "1h",
+			Templates: prchecklist.NotificationTemplates{OnCheck: `{{ .User.Login }} verified {{ .Item.Title }}`},
+		},
+	}
+
+	app := New(nil, repo)
+	ctx := notificationTestContext()
+
+	require.NoError(t, app.notifyEvent(ctx, cl, addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}}))
+	require.NoError(t, app.notifyEvent(ctx, cl, itemAddedEvent{checklist: cl, item: cl.Item(3)}))
+
+	outbox.expire()
+	app.FlushNotificationDigests(ctx)
+
+	payloads := outbox.payloads(t)
+	require.Equal(t, 1, len(payloads))
+	assert.Contains(t, payloads[0], "1 item checked by alice; 1 item added")
+	assert.Contains(t, payloads[0], "alice verified Feature A")
+	assert.Contains(t, payloads[0], "Feature B joined")
+}
@@ -6,35 +6,66 @@ package usecase
 
 import (
 	context "context"
+	http "net/http"
+	reflect "reflect"
+
 	gomock "github.com/golang/mock/gomock"
 	prchecklist "github.com/motemen/prchecklist/v2"
-	reflect "reflect"
+	oauth2 "golang.org/x/oauth2"
 )
 
-// MockGitHubGateway is a mock of GitHubGateway interface
+// MockGitHubGateway is a mock of GitHubGateway interface.
 type MockGitHubGateway struct {
 	ctrl     *gomock.Controller
 	recorder *MockGitHubGatewayMockRecorder
 }
 
-// MockGitHubGatewayMockRecorder is the mock recorder for MockGitHubGateway
+// MockGitHubGatewayMockRecorder is the mock recorder for MockGitHubGateway.
 type MockGitHubGatewayMockRecorder struct {
 	mock *MockGitHubGateway
 }
 
-// NewMockGitHubGateway creates a new mock instance
+// NewMockGitHubGateway creates a new mock instance.
 func NewMockGitHubGateway(ctrl *gomock.Controller) *MockGitHubGateway {
 	mock := &MockGitHubGateway{ctrl: ctrl}
 	mock.recorder = &MockGitHubGatewayMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockGitHubGateway) EXPECT() *MockGitHubGatewayMockRecorder {
 	return m.recorder
 }
 
-// GetBlob mocks base method
+// CanCreateCheckRun mocks base method.
+func (m *MockGitHubGateway) CanCreateCheckRun(arg0 context.Context) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanCreateCheckRun", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanCreateCheckRun indicates an expected call of CanCreateCheckRun.
+func (mr *MockGitHubGatewayMockRecorder) CanCreateCheckRun(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanCreateCheckRun", reflect.TypeOf((*MockGitHubGateway)(nil).CanCreateCheckRun), arg0)
+}
+
+// CreateCheckRun mocks base method.
+func (m *MockGitHubGateway) CreateCheckRun(arg0 context.Context, arg1, arg2 string, arg3 prchecklist.CheckRun) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCheckRun", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCheckRun indicates an expected call of CreateCheckRun.
+func (mr *MockGitHubGatewayMockRecorder) CreateCheckRun(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCheckRun", reflect.TypeOf((*MockGitHubGateway)(nil).CreateCheckRun), arg0, arg1, arg2, arg3)
+}
+
+// GetBlob mocks base method.
 func (m *MockGitHubGateway) GetBlob(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 string) ([]byte, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetBlob", arg0, arg1, arg2)
@@ -43,13 +74,28 @@ func (m *MockGitHubGateway) GetBlob(arg0 context.Context, arg1 prchecklist.Check
 	return ret0, ret1
 }
 
-// GetBlob indicates an expected call of GetBlob
+// GetBlob indicates an expected call of GetBlob.
 func (mr *MockGitHubGatewayMockRecorder) GetBlob(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlob", reflect.TypeOf((*MockGitHubGateway)(nil).GetBlob), arg0, arg1, arg2)
 }
 
-// GetPullRequest mocks base method
+// GetFeaturePullRequests mocks base method.
+func (m *MockGitHubGateway) GetFeaturePullRequests(arg0 context.Context, arg1, arg2 string, arg3 []int) (map[int]*prchecklist.PullRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeaturePullRequests", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(map[int]*prchecklist.PullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeaturePullRequests indicates an expected call of GetFeaturePullRequests.
+func (mr *MockGitHubGatewayMockRecorder) GetFeaturePullRequests(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeaturePullRequests", reflect.TypeOf((*MockGitHubGateway)(nil).GetFeaturePullRequests), arg0, arg1, arg2, arg3)
+}
+
+// GetPullRequest mocks base method.
 func (m *MockGitHubGateway) GetPullRequest(arg0 context.Context, arg1 prchecklist.ChecklistRef, arg2 bool) (*prchecklist.PullRequest, context.Context, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPullRequest", arg0, arg1, arg2)
@@ -59,37 +105,109 @@ func (m *MockGitHubGateway) GetPullRequest(arg0 context.Context, arg1 prchecklis
 	return ret0, ret1, ret2
 }
 
-// GetPullRequest indicates an expected call of GetPullRequest
+// GetPullRequest indicates an expected call of GetPullRequest.
 func (mr *MockGitHubGatewayMockRecorder) GetPullRequest(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequest", reflect.TypeOf((*MockGitHubGateway)(nil).GetPullRequest), arg0, arg1, arg2)
 }
 
-// GetRecentPullRequests mocks base method
-func (m *MockGitHubGateway) GetRecentPullRequests(arg0 context.Context) (map[string][]*prchecklist.PullRequest, error) {
+// GetPullRequestNumbersByHead mocks base method.
+func (m *MockGitHubGateway) GetPullRequestNumbersByHead(arg0 context.Context, arg1, arg2, arg3 string) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPullRequestNumbersByHead", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequestNumbersByHead indicates an expected call of GetPullRequestNumbersByHead.
+func (mr *MockGitHubGatewayMockRecorder) GetPullRequestNumbersByHead(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestNumbersByHead", reflect.TypeOf((*MockGitHubGateway)(nil).GetPullRequestNumbersByHead), arg0, arg1, arg2, arg3)
+}
+
+// GetRecentPullRequests mocks base method.
+func (m *MockGitHubGateway) GetRecentPullRequests(arg0 context.Context, arg1 prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecentPullRequests", arg0, arg1)
+	ret0, _ := ret[0].(*prchecklist.RecentPullRequests)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecentPullRequests indicates an expected call of GetRecentPullRequests.
+func (mr *MockGitHubGatewayMockRecorder) GetRecentPullRequests(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecentPullRequests", reflect.TypeOf((*MockGitHubGateway)(nil).GetRecentPullRequests), arg0, arg1)
+}
+
+// InvalidatePullRequest mocks base method.
+func (m *MockGitHubGateway) InvalidatePullRequest(arg0 context.Context, arg1, arg2 string, arg3 int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InvalidatePullRequest", arg0, arg1, arg2, arg3)
+}
+
+// InvalidatePullRequest indicates an expected call of InvalidatePullRequest.
+func (mr *MockGitHubGatewayMockRecorder) InvalidatePullRequest(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePullRequest", reflect.TypeOf((*MockGitHubGateway)(nil).InvalidatePullRequest), arg0, arg1, arg2, arg3)
+}
+
+// PutIssueComment mocks base method.
+func (m *MockGitHubGateway) PutIssueComment(arg0 context.Context, arg1, arg2 string, arg3 int, arg4 int64, arg5 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutIssueComment", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutIssueComment indicates an expected call of PutIssueComment.
+func (mr *MockGitHubGatewayMockRecorder) PutIssueComment(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutIssueComment", reflect.TypeOf((*MockGitHubGateway)(nil).PutIssueComment), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// RefreshToken mocks base method.
+func (m *MockGitHubGateway) RefreshToken(arg0 context.Context, arg1 *oauth2.Token) (*oauth2.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(*oauth2.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshToken indicates an expected call of RefreshToken.
+func (mr *MockGitHubGatewayMockRecorder) RefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshToken", reflect.TypeOf((*MockGitHubGateway)(nil).RefreshToken), arg0, arg1)
+}
+
+// ServiceHTTPClient mocks base method.
+func (m *MockGitHubGateway) ServiceHTTPClient(arg0 context.Context, arg1, arg2 string) (*http.Client, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRecentPullRequests", arg0)
-	ret0, _ := ret[0].(map[string][]*prchecklist.PullRequest)
+	ret := m.ctrl.Call(m, "ServiceHTTPClient", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*http.Client)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetRecentPullRequests indicates an expected call of GetRecentPullRequests
-func (mr *MockGitHubGatewayMockRecorder) GetRecentPullRequests(arg0 interface{}) *gomock.Call {
+// ServiceHTTPClient indicates an expected call of ServiceHTTPClient.
+func (mr *MockGitHubGatewayMockRecorder) ServiceHTTPClient(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecentPullRequests", reflect.TypeOf((*MockGitHubGateway)(nil).GetRecentPullRequests), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceHTTPClient", reflect.TypeOf((*MockGitHubGateway)(nil).ServiceHTTPClient), arg0, arg1, arg2)
 }
 
-// SetRepositoryStatusAs mocks base method
-func (m *MockGitHubGateway) SetRepositoryStatusAs(arg0 context.Context, arg1, arg2, arg3, arg4, arg5, arg6 string) error {
+// SetRepositoryStatusAs mocks base method.
+func (m *MockGitHubGateway) SetRepositoryStatusAs(arg0 context.Context, arg1, arg2, arg3, arg4, arg5, arg6, arg7 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetRepositoryStatusAs", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	ret := m.ctrl.Call(m, "SetRepositoryStatusAs", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SetRepositoryStatusAs indicates an expected call of SetRepositoryStatusAs
-func (mr *MockGitHubGatewayMockRecorder) SetRepositoryStatusAs(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
+// SetRepositoryStatusAs indicates an expected call of SetRepositoryStatusAs.
+func (mr *MockGitHubGatewayMockRecorder) SetRepositoryStatusAs(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRepositoryStatusAs", reflect.TypeOf((*MockGitHubGateway)(nil).SetRepositoryStatusAs), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRepositoryStatusAs", reflect.TypeOf((*MockGitHubGateway)(nil).SetRepositoryStatusAs), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 }
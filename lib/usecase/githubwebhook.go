@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+var githubWebhookSecret = os.Getenv("PRCHECKLIST_GITHUB_WEBHOOK_SECRET")
+
+func init() {
+	flag.StringVar(&githubWebhookSecret, "github-webhook-secret", githubWebhookSecret, "secret of GitHub webhooks, which enables /webhook/github (PRCHECKLIST_GITHUB_WEBHOOK_SECRET)")
+}
+
+// GitHubWebhookEnabled reports whether webhooks from GitHub are accepted,
+// that is, the webhook secret is given.
+func GitHubWebhookEnabled() bool {
+	return githubWebhookSecret != ""
+}
+
+// VerifyGitHubWebhook verifies the X-Hub-Signature-256 header of a webhook from GitHub.
+// https://docs.github.com/en/developers/webhooks-and-events/webhooks/securing-your-webhooks
+func VerifyGitHubWebhook(header http.Header, body []byte) error {
+	if !GitHubWebhookEnabled() {
+		return errors.New("GitHub webhook secret is not set")
+	}
+
+	sig := header.Get("X-Hub-Signature-256")
+	if !strings.HasPrefix(sig, "sha256=") {
+		return errors.New("invalid signature")
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	if !signature.Verify([]byte(githubWebhookSecret), body, sigBytes) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+// githubWebhookPayload is a subset of the payloads of pull_request, pull_request_review and push events.
+// https://docs.github.com/en/developers/webhooks-and-events/webhooks/webhook-events-and-payloads
+type githubWebhookPayload struct {
+	Action      string `json:"action"`
+	Ref         string `json:"ref"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// HandleGitHubWebhook handles a webhook payload of event from GitHub, which must be verified by VerifyGitHubWebhook.
+// Checklists of the affected pull requests are refreshed in background,
+// so that item changes and progress are published without anyone visiting them.
+func (u Usecase) HandleGitHubWebhook(ctx context.Context, event string, payload []byte) error {
+	var p githubWebhookPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errors.Wrap(err, "json.Unmarshal")
+	}
+
+	owner, repo := p.Repository.Owner.Login, p.Repository.Name
+
+	var refresh func(ctx context.Context) error
+	switch event {
+	case "pull_request", "pull_request_review":
+		if p.PullRequest.Number == 0 {
+			return errors.New("pull_request missing")
+		}
+		refresh = func(ctx context.Context) error {
+			return u.refreshPullRequest(ctx, owner, repo, p.PullRequest.Number)
+		}
+
+	case "push":
+		if !strings.HasPrefix(p.Ref, "refs/heads/") {
+			return nil
+		}
+		refresh = func(ctx context.Context) error {
+			return u.refreshPullRequestsByHead(ctx, owner, repo, strings.TrimPrefix(p.Ref, "refs/heads/"))
+		}
+
+	default:
+		return nil
+	}
+
+	ctx = prchecklist.NewContextWithValuesOf(ctx)
+	go func() {
+		if err := refresh(ctx); err != nil {
+			log.Printf("HandleGitHubWebhook(%s, %s/%s): %s", event, owner, repo, err)
+		}
+	}()
+
+	return nil
+}
+
+// refreshPullRequestsByHead refreshes the checklists of the open pull requests whose head is branch.
+func (u Usecase) refreshPullRequestsByHead(ctx context.Context, owner, repo, branch string) error {
+	client, err := u.github.ServiceHTTPClient(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	numbers, err := u.github.GetPullRequestNumbersByHead(context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, client), owner, repo, branch)
+	if err != nil {
+		return errors.Wrap(err, "GetPullRequestNumbersByHead")
+	}
+
+	for _, number := range numbers {
+		if err := u.refreshPullRequest(ctx, owner, repo, number); err != nil {
+			log.Printf("refreshPullRequest(%s/%s#%d): %s", owner, repo, number, err)
+		}
+	}
+
+	return nil
+}
+
+// refreshPullRequest discards the cached pull request and retrieves its checklists of all stages freshly,
+// then notifies item changes and updates the status and the summary comment.
+func (u Usecase) refreshPullRequest(ctx context.Context, owner, repo string, number int) error {
+	u.github.InvalidatePullRequest(ctx, owner, repo, number)
+
+	client, err := u.github.ServiceHTTPClient(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, client)
+
+	clRef := prchecklist.ChecklistRef{Host: prchecklist.ContextHost(ctx), Owner: owner, Repo: repo, Number: number, Stage: "default"}
+	checklist, err := u.GetChecklist(ctx, clRef)
+	if err != nil {
+		return errors.Wrap(err, "GetChecklist")
+	}
+	if checklist.Config == nil {
+		// Not a release pull request
+		return nil
+	}
+	if len(checklist.Items) == 0 {
+		// Feature pull requests have prchecklist.yml as well but merge no pull requests.
+		// Release pull requests whose items have all been removed are still refreshed
+		known, err := u.coreRepo.GetKnownItemNumbers(ctx, checklist.Ref())
+		if err != nil {
+			return errors.Wrap(err, "GetKnownItemNumbers")
+		}
+		if len(known) == 0 {
+			return nil
+		}
+	}
+
+	stages := checklist.Config.Stages
+	if len(stages) == 0 {
+		stages = []string{"default"}
+	}
+
+	for _, stage := range stages {
+		if stage != checklist.Stage {
+			clRef.Stage = stage
+			checklist, err = u.GetChecklist(ctx, clRef)
+			if err != nil {
+				return errors.Wrap(err, "GetChecklist")
+			}
+		}
+
+		if err := u.SyncChecklistItems(ctx, checklist); err != nil {
+			log.Printf("SyncChecklistItems(%s): %s", checklist, err)
+		}
+		if err := u.SyncChecklistStatus(ctx, checklist); err != nil {
+			log.Printf("SyncChecklistStatus(%s): %s", checklist, err)
+		}
+	}
+
+	return u.syncSummaryComment(ctx, checklist)
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+func TestVerifyGitHubWebhook(t *testing.T) {
+	githubWebhookSecret = "s3cret"
+	defer func() { githubWebhookSecret = "" }()
+
+	body := []byte(`{"action":"synchronize"}`)
+
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+signature.SignHex([]byte(githubWebhookSecret), body))
+
+	assert.NoError(t, VerifyGitHubWebhook(header, body))
+	assert.Error(t, VerifyGitHubWebhook(header, []byte(`{"action":"opened"}`)))
+
+	header.Set("X-Hub-Signature-256", "sha1=00")
+	assert.Error(t, VerifyGitHubWebhook(header, body))
+}
+
+func TestUsecase_refreshPullRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "default"}
+
+	github.EXPECT().InvalidatePullRequest(gomock.Any(), "test", "test", 1)
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(http.DefaultClient, nil)
+	setupMocks(clRef, github, repo)
+
+	assert.NoError(t, app.refreshPullRequest(context.Background(), "test", "test", 1))
+
+	github.EXPECT().InvalidatePullRequest(gomock.Any(), "test", "test", 1)
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(nil, errors.New("no token"))
+
+	assert.Error(t, app.refreshPullRequest(context.Background(), "test", "test", 1))
+}
+
+func TestUsecase_refreshPullRequest_feature(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 2, Stage: "default"}
+
+	github.EXPECT().InvalidatePullRequest(gomock.Any(), "test", "test", 2)
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(http.DefaultClient, nil)
+	github.EXPECT().GetPullRequest(gomock.Any(), clRef, true).Return(&prchecklist.PullRequest{
+		Owner:        "test",
+		Repo:         "test",
+		Number:       2,
+		ConfigBlobID: "CONFIG",
+		Commits:      []prchecklist.Commit{{Message: "Add a feature"}},
+	}, context.Background(), nil)
+	github.EXPECT().GetBlob(gomock.Any(), clRef, "CONFIG").Return([]byte("summary_comment: true\n"), nil)
+	repo.EXPECT().GetChecks(gomock.Any(), clRef).Return(prchecklist.Checks{}, nil)
+	repo.EXPECT().GetUsers(gomock.Any(), "", gomock.Len(0)).Return(map[int]prchecklist.GitHubUser{}, nil)
+	repo.EXPECT().GetKnownItemNumbers(gomock.Any(), clRef).Return(nil, nil)
+
+	// No status nor comment is written, which the mocks would fail on
+	assert.NoError(t, app.refreshPullRequest(context.Background(), "test", "test", 2))
+}
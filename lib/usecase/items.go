@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// SyncChecklistItems compares the items of checklist with the ones recorded last time,
+// and notifies on_item_added and on_item_removed events for the differences.
+// Call this whenever checklists are retrieved freshly from GitHub, such as on views.
+// Nothing is notified the first time, as there is nothing to compare with.
+func (u Usecase) SyncChecklistItems(ctx context.Context, checklist *prchecklist.Checklist) error {
+	if checklist.Config == nil {
+		return nil
+	}
+
+	clRef := checklist.Ref()
+
+	numbers := make([]int, len(checklist.Items))
+	for i, item := range checklist.Items {
+		numbers[i] = item.Number
+	}
+	sort.Ints(numbers)
+
+	known, err := u.swapKnownItemNumbers(ctx, clRef, numbers)
+	if err != nil || known == nil {
+		return err
+	}
+
+	added, removed := diffItemNumbers(known, numbers)
+
+	events := []notificationEvent{}
+	for _, n := range added {
+		events = append(events, itemAddedEvent{checklist: checklist, item: checklist.Item(n)})
+	}
+	for _, n := range removed {
+		events = append(events, itemRemovedEvent{checklist: checklist, item: u.removedItem(ctx, checklist, n)})
+	}
+	for _, event := range events {
+		if err := u.notifyEvent(ctx, checklist, event); err != nil {
+			log.Printf("notifyEvent(%v): %s", event, err)
+		}
+	}
+
+	if len(added) > 0 {
+		// The checklist may have been completed before, and must not stay so with unchecked items
+		if err := u.SyncChecklistStatus(ctx, checklist); err != nil {
+			log.Printf("SyncChecklistStatus(%s): %s", checklist, err)
+		}
+	}
+
+	return nil
+}
+
+// swapKnownItemNumbers records numbers as the known items of the checklist pointed by clRef
+// and returns the ones recorded last time, or nil if none.
+// It is serialized for each checklist not to notify the same changes twice.
+func (u Usecase) swapKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) ([]int, error) {
+	if u.itemsMu != nil {
+		unlock := u.itemsMu.lock(clRef.String())
+		defer unlock()
+	}
+
+	known, err := u.coreRepo.GetKnownItemNumbers(ctx, clRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetKnownItemNumbers")
+	}
+
+	added, removed := diffItemNumbers(known, numbers)
+	if known != nil && len(added) == 0 && len(removed) == 0 {
+		return known, nil
+	}
+
+	if err := u.coreRepo.SetKnownItemNumbers(ctx, clRef, numbers); err != nil {
+		return nil, errors.Wrap(err, "SetKnownItemNumbers")
+	}
+
+	return known, nil
+}
+
+// keyedMutex is a set of mutexes for each key, which are removed while unused.
+type keyedMutex struct {
+	mu      sync.Mutex
+	entries map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{
+		entries: map[string]*keyedMutexEntry{},
+	}
+}
+
+// lock locks the mutex for key and returns the function to unlock it.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	e, ok := k.entries[key]
+	if !ok {
+		e = &keyedMutexEntry{}
+		k.entries[key] = e
+	}
+	e.refs++
+	k.mu.Unlock()
+
+	e.Lock()
+
+	return func() {
+		e.Unlock()
+
+		k.mu.Lock()
+		e.refs--
+		if e.refs == 0 {
+			delete(k.entries, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// removedItem builds a ChecklistItem for the feature pull request number n no longer in checklist.
+func (u Usecase) removedItem(ctx context.Context, checklist *prchecklist.Checklist, n int) *prchecklist.ChecklistItem {
+	ref := prchecklist.ChecklistRef{Host: checklist.Host, Owner: checklist.Owner, Repo: checklist.Repo, Number: n}
+	pr, _, err := u.github.GetPullRequest(ctx, ref, false)
+	if err != nil {
+		log.Printf("GetPullRequest(%s): %s", ref, err)
+		pr = &prchecklist.PullRequest{Owner: checklist.Owner, Repo: checklist.Repo, Number: n}
+	}
+
+	return &prchecklist.ChecklistItem{
+		PullRequest: pr,
+		CheckedBy:   []prchecklist.GitHubUser{},
+	}
+}
+
+// diffItemNumbers returns the numbers in b but not in a, and the ones in a but not in b.
+func diffItemNumbers(a, b []int) (added, removed []int) {
+	inA := make(map[int]bool, len(a))
+	for _, n := range a {
+		inA[n] = true
+	}
+	inB := make(map[int]bool, len(b))
+	for _, n := range b {
+		inB[n] = true
+		if !inA[n] {
+			added = append(added, n)
+		}
+	}
+	for _, n := range a {
+		if !inB[n] {
+			removed = append(removed, n)
+		}
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+func TestDiffItemNumbers(t *testing.T) {
+	added, removed := diffItemNumbers([]int{1, 2, 3}, []int{2, 3, 4, 5})
+	assert.Equal(t, []int{4, 5}, added)
+	assert.Equal(t, []int{1}, removed)
+
+	added, removed = diffItemNumbers([]int{1}, []int{1})
+	assert.Nil(t, added)
+	assert.Nil(t, removed)
+}
+
+func TestUsecase_SyncChecklistItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+	ctx := notificationTestContext()
+
+	cl := makeNotificationTestChecklist()
+	cl.Commits = []prchecklist.Commit{{Oid: "deadbeef"}}
+	cl.Config = &prchecklist.ChecklistConfig{Status: prchecklist.StatusCommitStatus}
+	cl.Config.Notification.Events.OnItemAdded = []string{"default"}
+	cl.Config.Notification.Events.OnItemRemoved = []string{"default"}
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"default": {URL: "https://hooks.slack.com/services/XXX", Format: prchecklist.NotificationFormatText},
+	}
+
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "qa"}
+
+	// First time: only recorded
+	repo.EXPECT().GetKnownItemNumbers(gomock.Any(), clRef).Return(nil, nil)
+	repo.EXPECT().SetKnownItemNumbers(gomock.Any(), clRef, []int{2, 3, 4}).Return(nil)
+	require.NoError(t, app.SyncChecklistItems(ctx, cl))
+
+	// Unchanged
+	repo.EXPECT().GetKnownItemNumbers(gomock.Any(), clRef).Return([]int{2, 3, 4}, nil)
+	require.NoError(t, app.SyncChecklistItems(ctx, cl))
+
+	// #4 added and #5 removed
+	repo.EXPECT().GetKnownItemNumbers(gomock.Any(), clRef).Return([]int{2, 3, 5}, nil)
+	repo.EXPECT().SetKnownItemNumbers(gomock.Any(), clRef, []int{2, 3, 4}).Return(nil)
+	github.EXPECT().GetPullRequest(gomock.Any(), prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 5}, false).
+		Return(&prchecklist.PullRequest{Number: 5, Title: "Feature E"}, context.Background(), nil)
+
+	var texts []string
+	repo.EXPECT().PutOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, n prchecklist.OutboxNotification) error {
+		form, err := url.ParseQuery(string(n.Body))
+		require.NoError(t, err)
+		texts = append(texts, form.Get("payload"))
+		return nil
+	}).Times(2)
+
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(&http.Client{}, nil)
+	github.EXPECT().SetRepositoryStatusAs(gomock.Any(), "test", "test", "deadbeef", "prchecklist/qa/completed", "pending", "1/3 checked", gomock.Any()).Return(nil)
+
+	require.NoError(t, app.SyncChecklistItems(ctx, cl))
+
+	require.Equal(t, 2, len(texts))
+	assert.Contains(t, texts[0], `#4 \"Feature C\" added to the checklist, cc bar`)
+	assert.Contains(t, texts[1], `#5 \"Feature E\" removed from the checklist`)
+}
+
+func TestUsecase_SyncChecklistItems_status(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+	ctx := notificationTestContext()
+
+	serviceClient := &http.Client{}
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(serviceClient, nil).AnyTimes()
+
+	cl := makeNotificationTestChecklist()
+	cl.Commits = []prchecklist.Commit{{Oid: "deadbeef"}}
+	cl.Config = &prchecklist.ChecklistConfig{Status: prchecklist.StatusCommitStatus}
+	for _, item := range cl.Items {
+		item.CheckedBy = []prchecklist.GitHubUser{{Login: "alice"}}
+	}
+
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "qa"}
+
+	// Completed
+	github.EXPECT().SetRepositoryStatusAs(gomock.Any(), "test", "test", "deadbeef", "prchecklist/qa/completed", "success", "3/3 checked", gomock.Any()).Return(nil)
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	// #5 added, and the status goes back to pending as prchecklist
+	cl.Items = append(cl.Items, &prchecklist.ChecklistItem{
+		PullRequest: &prchecklist.PullRequest{Number: 5, Title: "Feature D", User: prchecklist.GitHubUserSimple{Login: "bar"}},
+		CheckedBy:   []prchecklist.GitHubUser{},
+	})
+	repo.EXPECT().GetKnownItemNumbers(gomock.Any(), clRef).Return([]int{2, 3, 4}, nil)
+	repo.EXPECT().SetKnownItemNumbers(gomock.Any(), clRef, []int{2, 3, 4, 5}).Return(nil)
+	github.EXPECT().SetRepositoryStatusAs(contextClientMatcher{serviceClient}, "test", "test", "deadbeef", "prchecklist/qa/completed", "pending", "3/4 checked", gomock.Any()).Return(nil)
+	require.NoError(t, app.SyncChecklistItems(ctx, cl))
+}
+
+func TestKeyedMutex(t *testing.T) {
+	k := newKeyedMutex()
+
+	unlockA := k.lock("a")
+
+	// Other keys are not blocked
+	done := make(chan struct{})
+	go func() {
+		k.lock("b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock(b) blocked by lock(a)")
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		k.lock("a")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock(a) not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlockA()
+	<-locked
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	assert.Empty(t, k.entries, "unused mutexes are removed")
+}
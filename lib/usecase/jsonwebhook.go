@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+// Headers of signed JSON webhook requests.
+// The signature is "sha256=" followed by the hex-encoded HMAC-SHA256 of
+// the timestamp, a period and the request body, keyed with the channel secret.
+const (
+	webhookHeaderSignature = "X-Prchecklist-Signature-256"
+	webhookHeaderTimestamp = "X-Prchecklist-Timestamp"
+	webhookHeaderDelivery  = "X-Prchecklist-Delivery"
+)
+
+// jsonWebhookPayload is the body of notifications sent to JSON webhook channels.
+type jsonWebhookPayload struct {
+	Event     string               `json:"event"`
+	Checklist jsonWebhookChecklist `json:"checklist"`
+	Item      *jsonWebhookItem     `json:"item,omitempty"`
+	User      *jsonWebhookUser     `json:"user,omitempty"`
+}
+
+type jsonWebhookChecklist struct {
+	Owner          string `json:"owner"`
+	Repo           string `json:"repo"`
+	Number         int    `json:"number"`
+	Stage          string `json:"stage"`
+	Title          string `json:"title"`
+	URL            string `json:"url"`
+	PullRequestURL string `json:"pull_request_url"`
+	HeadCommitID   string `json:"head_commit_id,omitempty"`
+	Completed      bool   `json:"completed"`
+	Checked        int    `json:"checked"`
+	Total          int    `json:"total"`
+}
+
+type jsonWebhookUser struct {
+	Login string `json:"login"`
+}
+
+type jsonWebhookItem struct {
+	Number    int      `json:"number"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Author    string   `json:"author"`
+	CheckedBy []string `json:"checked_by"`
+}
+
+func newJSONWebhookPayload(ctx context.Context, event notificationEvent) jsonWebhookPayload {
+	data := event.templateData(ctx)
+	checklist := data.Checklist
+
+	payload := jsonWebhookPayload{
+		Event: event.eventType().String(),
+		Checklist: jsonWebhookChecklist{
+			Owner:          checklist.Owner,
+			Repo:           checklist.Repo,
+			Number:         checklist.Number,
+			Stage:          checklist.Stage,
+			Title:          checklist.Title,
+			URL:            data.URL,
+			PullRequestURL: checklist.URL,
+			Completed:      checklist.Completed,
+			Checked:        checklist.Checked,
+			Total:          checklist.Total,
+		},
+	}
+
+	if len(checklist.Commits) > 0 {
+		payload.Checklist.HeadCommitID = checklist.Commits[len(checklist.Commits)-1].Oid
+	}
+
+	if item := data.Item; item != nil {
+		payload.Item = &jsonWebhookItem{
+			Number:    item.Number,
+			Title:     item.Title,
+			URL:       item.URL,
+			Author:    item.User.Login,
+			CheckedBy: make([]string, len(item.CheckedBy)),
+		}
+		for i, user := range item.CheckedBy {
+			payload.Item.CheckedBy[i] = user.Login
+		}
+	}
+
+	if data.User.Login != "" {
+		payload.User = &jsonWebhookUser{Login: data.User.Login}
+	}
+
+	return payload
+}
+
+// signWebhookRequest sets the headers to req for the receiver to verify
+// the body was sent by prchecklist.
+func signWebhookRequest(req *http.Request, secret []byte, deliveryID string, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	message := append([]byte(timestamp+"."), body...)
+
+	req.Header.Set(webhookHeaderSignature, "sha256="+signature.SignHex(secret, message))
+	req.Header.Set(webhookHeaderTimestamp, timestamp)
+	req.Header.Set(webhookHeaderDelivery, deliveryID)
+}
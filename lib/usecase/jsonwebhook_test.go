@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+func TestNewJSONWebhookPayload(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+
+	payload := newJSONWebhookPayload(ctx, addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}})
+
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	var v map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &v))
+
+	assert.Equal(t, "on_check", v["event"])
+	assert.Equal(t, map[string]interface{}{
+		"owner":            "test",
+		"repo":             "test",
+		"number":           float64(1),
+		"stage":            "qa",
+		"title":            "Release <2020-06-23>",
+		"url":              "https://prchecklist.test/test/test/pull/1/qa",
+		"pull_request_url": "",
+		"completed":        false,
+		"checked":          float64(1),
+		"total":            float64(3),
+	}, v["checklist"])
+	assert.Equal(t, map[string]interface{}{
+		"number":     float64(2),
+		"title":      "Feature A",
+		"url":        "https://github.com/test/test/pull/2",
+		"author":     "foo",
+		"checked_by": []interface{}{"alice"},
+	}, v["item"])
+	assert.Equal(t, "alice", v["user"].(map[string]interface{})["login"])
+
+	payload = newJSONWebhookPayload(ctx, completeEvent{checklist: cl})
+	assert.Equal(t, "on_complete", payload.Event)
+	assert.Nil(t, payload.Item)
+	assert.Nil(t, payload.User)
+}
+
+func TestUsecase_postNotification_signed(t *testing.T) {
+	webhookAllowPrivateNetwork = true
+	defer func() { webhookAllowPrivateNetwork = false }()
+
+	secret := "s3cret"
+	body := `{"event":"on_check"}`
+
+	var received bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = true
+
+		b, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(b))
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "delivery-id", req.Header.Get(webhookHeaderDelivery))
+
+		timestamp := req.Header.Get(webhookHeaderTimestamp)
+		assert.NotEmpty(t, timestamp)
+
+		sig := req.Header.Get(webhookHeaderSignature)
+		require.True(t, strings.HasPrefix(sig, "sha256="), sig)
+		sigBytes, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		require.NoError(t, err)
+		assert.True(t, signature.Verify([]byte(secret), []byte(timestamp+"."+body), sigBytes))
+	}))
+	defer s.Close()
+
+	u := New(nil, nil)
+	u.SetSecrets(map[string]Secret{"HOOK": {Value: secret, Repos: []string{"test/test"}}})
+
+	err := u.postNotification(context.Background(), &prchecklist.OutboxNotification{
+		ID:          "delivery-id",
+		URL:         s.URL,
+		ContentType: "application/json",
+		Body:        []byte(body),
+		SecretRef:   "secret:HOOK",
+		Repository:  "test/test",
+	})
+	require.NoError(t, err)
+	assert.True(t, received)
+}
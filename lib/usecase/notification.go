@@ -1,16 +1,16 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
+	"text/template"
 
 	"github.com/pkg/errors"
 
-	"github.com/motemen/go-nuts/httputil"
 	"github.com/motemen/prchecklist/v2"
 )
 
@@ -22,22 +22,227 @@ const (
 	eventTypeOnComplete
 	eventTypeOnCompleteChecksOfUser
 	eventTypeOnRemove
+	eventTypeOnItemAdded
+	eventTypeOnItemRemoved
 )
 
+func (t eventType) String() string {
+	switch t {
+	case eventTypeOnCheck:
+		return "on_check"
+	case eventTypeOnComplete:
+		return "on_complete"
+	case eventTypeOnCompleteChecksOfUser:
+		return "on_complete_checks_of_user"
+	case eventTypeOnRemove:
+		return "on_remove"
+	case eventTypeOnItemAdded:
+		return "on_item_added"
+	case eventTypeOnItemRemoved:
+		return "on_item_removed"
+	default:
+		return "invalid"
+	}
+}
+
+func (t eventType) template(templates prchecklist.NotificationTemplates) string {
+	switch t {
+	case eventTypeOnCheck:
+		return templates.OnCheck
+	case eventTypeOnComplete:
+		return templates.OnComplete
+	case eventTypeOnCompleteChecksOfUser:
+		return templates.OnCompleteChecksOfUser
+	case eventTypeOnRemove:
+		return templates.OnRemove
+	case eventTypeOnItemAdded:
+		return templates.OnItemAdded
+	case eventTypeOnItemRemoved:
+		return templates.OnItemRemoved
+	default:
+		return ""
+	}
+}
+
 type notificationEvent interface {
-	slackMessageText(ctx context.Context) string
+	slackMessageText(ctx context.Context, m slackMentions) string
+	slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock
+	templateData(ctx context.Context) NotificationTemplateData
 	eventType() eventType
 }
 
+// NotificationTemplateData is the data passed to the notification templates
+// specified in prchecklist.yml.
+// As the templates are written in repositories, it only has what is shown to the viewers of the checklist,
+// and not the configuration, which has the webhook URLs resolved from the secrets of the server.
+type NotificationTemplateData struct {
+	// Checklist is the checklist the event occurred on.
+	Checklist *NotificationTemplateChecklist
+	// Item is the checked, unchecked, added or removed item.
+	// It is nil for on_complete and on_complete_checks_of_user events.
+	Item *NotificationTemplateItem
+	// User is who checked or unchecked the item.
+	// For on_complete_checks_of_user events it is the author of the completed items,
+	// and is empty for on_complete, on_item_added and on_item_removed events.
+	User prchecklist.GitHubUserSimple
+	// URL is the URL of the checklist page.
+	URL string
+	// Stage is the stage of the checklist.
+	Stage string
+	// Event is the name of the event, such as "on_check".
+	Event string
+}
+
+// NotificationTemplateChecklist is a checklist seen from the notification templates.
+type NotificationTemplateChecklist struct {
+	*prchecklist.PullRequest
+	Stage     string
+	Items     []*NotificationTemplateItem
+	Completed bool
+	// Checked is the number of the items checked by any user out of Total.
+	Checked int
+	Total   int
+
+	name string
+}
+
+// String returns the name of the checklist, such as "owner/repo#1::qa".
+func (c NotificationTemplateChecklist) String() string {
+	return c.name
+}
+
+// NotificationTemplateItem is an item of a checklist seen from the notification templates.
+type NotificationTemplateItem struct {
+	*prchecklist.PullRequest
+	CheckedBy []prchecklist.GitHubUserSimple
+}
+
+func newNotificationTemplateItem(item *prchecklist.ChecklistItem) *NotificationTemplateItem {
+	if item == nil {
+		return nil
+	}
+
+	v := &NotificationTemplateItem{
+		PullRequest: item.PullRequest,
+		CheckedBy:   make([]prchecklist.GitHubUserSimple, len(item.CheckedBy)),
+	}
+	for i, user := range item.CheckedBy {
+		v.CheckedBy[i] = prchecklist.GitHubUserSimple{Login: user.Login}
+	}
+	return v
+}
+
+func newNotificationTemplateData(ctx context.Context, checklist *prchecklist.Checklist) NotificationTemplateData {
+	checked, total := checklist.Progress()
+	v := &NotificationTemplateChecklist{
+		PullRequest: checklist.PullRequest,
+		Stage:       checklist.Stage,
+		Items:       make([]*NotificationTemplateItem, len(checklist.Items)),
+		Completed:   checklist.Completed(),
+		Checked:     checked,
+		Total:       total,
+		name:        checklist.String(),
+	}
+	for i, item := range checklist.Items {
+		v.Items[i] = newNotificationTemplateItem(item)
+	}
+
+	return NotificationTemplateData{
+		Checklist: v,
+		URL:       prchecklist.BuildURL(ctx, checklist.Path()).String(),
+		Stage:     checklist.Stage,
+	}
+}
+
+// maxNotificationTemplateOutput is the maximum size of the text rendered by a notification template,
+// which is the limit of Slack messages.
+const maxNotificationTemplateOutput = 40000
+
+// errNotificationTemplateTooLong is returned when a template renders more than maxNotificationTemplateOutput.
+var errNotificationTemplateTooLong = errors.Errorf("notification template rendered more than %d bytes", maxNotificationTemplateOutput)
+
+// limitedBuffer is a bytes.Buffer which fails on writing more than max bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errNotificationTemplateTooLong
+	}
+	return b.Buffer.Write(p)
+}
+
+func parseNotificationTemplate(name, text string, m slackMentions) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"escape":  slackEscape,
+		"mention": m.mention,
+	}).Parse(text)
+}
+
+func validateNotificationTemplates(templates prchecklist.NotificationTemplates) error {
+	for _, t := range []eventType{eventTypeOnCheck, eventTypeOnComplete, eventTypeOnCompleteChecksOfUser, eventTypeOnRemove, eventTypeOnItemAdded, eventTypeOnItemRemoved} {
+		if text := t.template(templates); text != "" {
+			if _, err := parseNotificationTemplate("", text, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderNotificationTemplate renders the template for event configured for the channel ch,
+// or the notification-wide one if the channel does not have one.
+// It returns false if no template is configured.
+func renderNotificationTemplate(ctx context.Context, config *prchecklist.ChecklistConfig, ch prchecklist.NotificationChannel, event notificationEvent, m slackMentions) (string, bool, error) {
+	text := event.eventType().template(ch.Templates)
+	if text == "" {
+		text = event.eventType().template(config.Notification.Templates)
+	}
+	if text == "" {
+		return "", false, nil
+	}
+
+	tmpl, err := parseNotificationTemplate("", text, m)
+	if err != nil {
+		return "", false, err
+	}
+
+	data := event.templateData(ctx)
+	data.Event = event.eventType().String()
+
+	buf := limitedBuffer{max: maxNotificationTemplateOutput}
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", false, err
+	}
+
+	return buf.String(), true, nil
+}
+
 type removeCheckEvent struct {
 	checklist *prchecklist.Checklist
 	item      *prchecklist.ChecklistItem
 	user      prchecklist.GitHubUser
 }
 
-func (e removeCheckEvent) slackMessageText(ctx context.Context) string {
+func (e removeCheckEvent) slackMessageText(ctx context.Context, m slackMentions) string {
 	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
-	return fmt.Sprintf("[<%s|%s>] #%d %q check removed by %s", u, e.checklist, e.item.Number, e.item.Title, e.user.Login)
+	return fmt.Sprintf("[<%s|%s>] #%d %q check removed by %s, cc %s", u, e.checklist, e.item.Number, e.item.Title, e.user.Login, m.mention(e.item.User.Login))
+}
+
+func (e removeCheckEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	text := fmt.Sprintf(":heavy_multiplication_x: %s\ncheck removed by %s, cc %s", slackItemText(e.item), slackEscape(e.user.Login), m.mention(e.item.User.Login))
+	blocks := append(slackChecklistBlocks(ctx, e.checklist, text), slackItemButtonBlock(e.checklist, e.item, true)...)
+	return append(blocks, slackRemainingBlocks(e.checklist)...)
+}
+
+func (e removeCheckEvent) templateData(ctx context.Context) NotificationTemplateData {
+	data := newNotificationTemplateData(ctx, e.checklist)
+	data.Item = newNotificationTemplateItem(e.item)
+	data.User = prchecklist.GitHubUserSimple{Login: e.user.Login}
+	return data
 }
 
 func (e removeCheckEvent) eventType() eventType {
@@ -50,22 +255,43 @@ type addCheckEvent struct {
 	user      prchecklist.GitHubUser
 }
 
-func (e addCheckEvent) slackMessageText(ctx context.Context) string {
+func (e addCheckEvent) slackMessageText(ctx context.Context, m slackMentions) string {
 	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
 	return fmt.Sprintf("[<%s|%s>] #%d %q checked by %s", u, e.checklist, e.item.Number, e.item.Title, e.user.Login)
 }
 
+func (e addCheckEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	text := fmt.Sprintf(":white_check_mark: %s\nchecked by %s", slackItemText(e.item), slackEscape(e.user.Login))
+	blocks := append(slackChecklistBlocks(ctx, e.checklist, text), slackItemButtonBlock(e.checklist, e.item, false)...)
+	return append(blocks, slackRemainingBlocks(e.checklist)...)
+}
+
+func (e addCheckEvent) templateData(ctx context.Context) NotificationTemplateData {
+	data := newNotificationTemplateData(ctx, e.checklist)
+	data.Item = newNotificationTemplateItem(e.item)
+	data.User = prchecklist.GitHubUserSimple{Login: e.user.Login}
+	return data
+}
+
 func (e addCheckEvent) eventType() eventType { return eventTypeOnCheck }
 
 type completeEvent struct {
 	checklist *prchecklist.Checklist
 }
 
-func (e completeEvent) slackMessageText(ctx context.Context) string {
+func (e completeEvent) slackMessageText(ctx context.Context, m slackMentions) string {
 	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
 	return fmt.Sprintf("[<%s|%s>] Checklist completed! :tada:", u, e.checklist)
 }
 
+func (e completeEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	return append(slackChecklistBlocks(ctx, e.checklist, "Checklist completed! :tada:"), slackSignedOffBlocks(e.checklist)...)
+}
+
+func (e completeEvent) templateData(ctx context.Context) NotificationTemplateData {
+	return newNotificationTemplateData(ctx, e.checklist)
+}
+
 func (e completeEvent) eventType() eventType { return eventTypeOnComplete }
 
 type completeChecksOfUserEvent struct {
@@ -73,13 +299,71 @@ type completeChecksOfUserEvent struct {
 	user      prchecklist.GitHubUserSimple
 }
 
-func (e completeChecksOfUserEvent) slackMessageText(ctx context.Context) string {
+func (e completeChecksOfUserEvent) slackMessageText(ctx context.Context, m slackMentions) string {
 	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
-	return fmt.Sprintf("[<%s|%s>] completed checks of %s", u, e.checklist, e.user.Login)
+	return fmt.Sprintf("[<%s|%s>] completed checks of %s", u, e.checklist, m.mention(e.user.Login))
+}
+
+func (e completeChecksOfUserEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	text := fmt.Sprintf(":ballot_box_with_check: completed checks of %s", m.mention(e.user.Login))
+	return append(slackChecklistBlocks(ctx, e.checklist, text), slackRemainingBlocks(e.checklist)...)
+}
+
+func (e completeChecksOfUserEvent) templateData(ctx context.Context) NotificationTemplateData {
+	data := newNotificationTemplateData(ctx, e.checklist)
+	data.User = e.user
+	return data
 }
 
 func (e completeChecksOfUserEvent) eventType() eventType { return eventTypeOnCompleteChecksOfUser }
 
+type itemAddedEvent struct {
+	checklist *prchecklist.Checklist
+	item      *prchecklist.ChecklistItem
+}
+
+func (e itemAddedEvent) slackMessageText(ctx context.Context, m slackMentions) string {
+	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
+	return fmt.Sprintf("[<%s|%s>] #%d %q added to the checklist, cc %s", u, e.checklist, e.item.Number, e.item.Title, m.mention(e.item.User.Login))
+}
+
+func (e itemAddedEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	text := fmt.Sprintf(":new: %s\nadded to the checklist, cc %s", slackItemText(e.item), m.mention(e.item.User.Login))
+	blocks := append(slackChecklistBlocks(ctx, e.checklist, text), slackItemButtonBlock(e.checklist, e.item, true)...)
+	return append(blocks, slackRemainingBlocks(e.checklist)...)
+}
+
+func (e itemAddedEvent) templateData(ctx context.Context) NotificationTemplateData {
+	data := newNotificationTemplateData(ctx, e.checklist)
+	data.Item = newNotificationTemplateItem(e.item)
+	return data
+}
+
+func (e itemAddedEvent) eventType() eventType { return eventTypeOnItemAdded }
+
+type itemRemovedEvent struct {
+	checklist *prchecklist.Checklist
+	item      *prchecklist.ChecklistItem
+}
+
+func (e itemRemovedEvent) slackMessageText(ctx context.Context, m slackMentions) string {
+	u := prchecklist.BuildURL(ctx, e.checklist.Path()).String()
+	return fmt.Sprintf("[<%s|%s>] #%d %q removed from the checklist", u, e.checklist, e.item.Number, e.item.Title)
+}
+
+func (e itemRemovedEvent) slackMessageBlocks(ctx context.Context, m slackMentions) []slackBlock {
+	text := fmt.Sprintf(":wastebasket: %s\nremoved from the checklist", slackItemText(e.item))
+	return append(slackChecklistBlocks(ctx, e.checklist, text), slackRemainingBlocks(e.checklist)...)
+}
+
+func (e itemRemovedEvent) templateData(ctx context.Context) NotificationTemplateData {
+	data := newNotificationTemplateData(ctx, e.checklist)
+	data.Item = newNotificationTemplateItem(e.item)
+	return data
+}
+
+func (e itemRemovedEvent) eventType() eventType { return eventTypeOnItemRemoved }
+
 func (u Usecase) notifyEvent(ctx context.Context, checklist *prchecklist.Checklist, event notificationEvent) error {
 	config := checklist.Config
 	if config == nil {
@@ -96,43 +380,74 @@ func (u Usecase) notifyEvent(ctx context.Context, checklist *prchecklist.Checkli
 		chNames = config.Notification.Events.OnCompleteChecksOfUser
 	case eventTypeOnComplete:
 		chNames = config.Notification.Events.OnComplete
-		lastCommitID := checklist.Commits[len(checklist.Commits)-1].Oid
-		if err := u.setRepositoryCompletedStatusAs(ctx, checklist.Owner, checklist.Repo, lastCommitID, "success", checklist.Stage, prchecklist.BuildURL(ctx, checklist.Path()).String()); err != nil {
-			log.Printf("Failed to SetRepositoryStatusAs: %s (%+v)", err, err)
-		}
+	case eventTypeOnItemAdded:
+		chNames = config.Notification.Events.OnItemAdded
+	case eventTypeOnItemRemoved:
+		chNames = config.Notification.Events.OnItemRemoved
 	default:
 		return errors.Errorf("unknown event type: %v", event.eventType())
 	}
 
+	mentions := u.slackMentions(config)
+
 	for _, name := range chNames {
-		name := name
 		ch, ok := config.Notification.Channels[name]
 		if !ok {
 			continue
 		}
 
-		go func() {
-			payload, err := json.Marshal(&slackMessagePayload{
-				Text: event.slackMessageText(ctx),
-			})
+		if ch.Format == prchecklist.NotificationFormatJSON {
+			body, err := json.Marshal(newJSONWebhookPayload(ctx, event))
 			if err != nil {
-				log.Printf("json.Marshal: %s", err)
-				return
+				return errors.Wrap(err, "json.Marshal")
 			}
 
-			v := url.Values{"payload": {string(payload)}}
-
-			_, err = httputil.Successful(http.PostForm(ch.URL, v))
+			err = u.enqueueNotification(ctx, checklist, name, ch, "application/json", body)
 			if err != nil {
-				log.Printf("posting Slack webhook: %s", err)
-				return
+				return errors.Wrapf(err, "enqueueNotification(%s)", name)
+			}
+			continue
+		}
+
+		if ch.Digest != "" {
+			if event.eventType() != eventTypeOnComplete {
+				if err := u.addDigestEvent(ctx, checklist, name, ch, mentions, event); err != nil {
+					return errors.Wrapf(err, "addDigestEvent(%s)", name)
+				}
+				continue
+			}
+
+			// Send the events so far before the completion
+			if err := u.flushDigest(ctx, checklist, name); err != nil {
+				log.Printf("flushDigest: %s", err)
+			}
+		}
+
+		var message slackMessagePayload
+		text, ok, err := renderNotificationTemplate(ctx, config, ch, event, mentions)
+		if err != nil {
+			log.Printf("rendering notification template: %s", err)
+		}
+		if ok {
+			message.Text = text
+		} else {
+			message.Text = event.slackMessageText(ctx, mentions)
+			if ch.Format != prchecklist.NotificationFormatText {
+				message.Blocks = event.slackMessageBlocks(ctx, mentions)
 			}
-		}()
+		}
+
+		payload, err := json.Marshal(&message)
+		if err != nil {
+			return errors.Wrap(err, "json.Marshal")
+		}
+
+		body := url.Values{"payload": {string(payload)}}.Encode()
+		err = u.enqueueNotification(ctx, checklist, name, ch, "application/x-www-form-urlencoded", []byte(body))
+		if err != nil {
+			return errors.Wrapf(err, "enqueueNotification(%s)", name)
+		}
 	}
 
 	return nil
 }
-
-type slackMessagePayload struct {
-	Text string `json:"text"`
-}
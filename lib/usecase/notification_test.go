@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func makeNotificationTestChecklist() *prchecklist.Checklist {
+	return &prchecklist.Checklist{
+		PullRequest: &prchecklist.PullRequest{
+			Owner:  "test",
+			Repo:   "test",
+			Number: 1,
+			Title:  "Release <2020-06-23>",
+		},
+		Stage: "qa",
+		Items: []*prchecklist.ChecklistItem{
+			{
+				PullRequest: &prchecklist.PullRequest{Number: 2, Title: "Feature A", URL: "https://github.com/test/test/pull/2", User: prchecklist.GitHubUserSimple{Login: "foo"}},
+				CheckedBy:   []prchecklist.GitHubUser{{Login: "alice"}},
+			},
+			{
+				PullRequest: &prchecklist.PullRequest{Number: 3, Title: "Feature B", URL: "https://github.com/test/test/pull/3", User: prchecklist.GitHubUserSimple{Login: "foo"}},
+				CheckedBy:   []prchecklist.GitHubUser{},
+			},
+			{
+				PullRequest: &prchecklist.PullRequest{Number: 4, Title: "Feature C", URL: "https://github.com/test/test/pull/4", User: prchecklist.GitHubUserSimple{Login: "bar"}},
+				CheckedBy:   []prchecklist.GitHubUser{},
+			},
+		},
+	}
+}
+
+func notificationTestContext() context.Context {
+	return prchecklist.RequestContext(httptest.NewRequest("GET", "https://prchecklist.test/", nil))
+}
+
+func blocksText(t *testing.T, blocks []slackBlock) string {
+	t.Helper()
+
+	b, err := json.Marshal(blocks)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestAddCheckEvent_slackMessageBlocks(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+
+	blocks := addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}}.slackMessageBlocks(ctx, nil)
+
+	require.True(t, len(blocks) > 3)
+	assert.Equal(t, "header", blocks[0].Type)
+	assert.Equal(t, "Release <2020-06-23>", blocks[0].Text.Text)
+
+	text := blocksText(t, blocks)
+	assert.Contains(t, text, "https://prchecklist.test/test/test/pull/1/qa")
+	assert.Contains(t, text, "*Progress:* 1/3 checked")
+	assert.Contains(t, text, "checked by alice")
+
+	remaining := blocks[len(blocks)-1].Text.Text
+	assert.True(t, strings.HasPrefix(remaining, "*Remaining*"))
+	assert.True(t, strings.Index(remaining, "*bar*") < strings.Index(remaining, "*foo*"), "grouped by authors in order")
+	assert.Contains(t, remaining, "Feature B")
+	assert.NotContains(t, remaining, "Feature A")
+}
+
+func TestCompleteEvent_slackMessageBlocks(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	for _, item := range cl.Items {
+		item.CheckedBy = append(item.CheckedBy, prchecklist.GitHubUser{Login: "bob"})
+	}
+
+	blocks := completeEvent{checklist: cl}.slackMessageBlocks(ctx, nil)
+
+	signedOff := blocks[len(blocks)-1].Text.Text
+	assert.Contains(t, signedOff, "*Signed off*")
+	assert.Contains(t, signedOff, "Feature A (foo): alice, bob")
+	assert.Contains(t, signedOff, "Feature C (bar): bob")
+	assert.NotContains(t, blocksText(t, blocks), "*Remaining*")
+}
+
+func TestSlackEscape(t *testing.T) {
+	assert.Equal(t, "a &lt;b&gt; &amp; c", slackEscape("a <b> & c"))
+}
+
+func TestRenderNotificationTemplate(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Templates.OnCheck = `{{.Item.Title}} を {{.User.Login}} がチェックしました ({{.Stage}}) {{.URL}}`
+
+	event := addCheckEvent{checklist: cl, item: cl.Item(3), user: prchecklist.GitHubUser{Login: "alice"}}
+
+	text, ok, err := renderNotificationTemplate(ctx, cl.Config, prchecklist.NotificationChannel{}, event, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "Feature B を alice がチェックしました (qa) https://prchecklist.test/test/test/pull/1/qa", text)
+
+	ch := prchecklist.NotificationChannel{}
+	ch.Templates.OnCheck = `:ok: {{escape .Checklist.Title}}`
+	text, ok, err = renderNotificationTemplate(ctx, cl.Config, ch, event, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ":ok: Release &lt;2020-06-23&gt;", text, "channel templates take precedence")
+
+	_, ok, err = renderNotificationTemplate(ctx, cl.Config, ch, completeEvent{checklist: cl}, nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRenderNotificationTemplate_noConfig(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"default": {URL: "https://hooks.slack.com/services/SECRET"},
+	}
+
+	event := addCheckEvent{checklist: cl, item: cl.Item(3), user: prchecklist.GitHubUser{Login: "alice"}}
+
+	ch := prchecklist.NotificationChannel{}
+	ch.Templates.OnCheck = `{{range .Checklist.Config.Notification.Channels}}{{.URL}}{{end}}`
+	text, _, err := renderNotificationTemplate(ctx, cl.Config, ch, event, nil)
+	assert.Error(t, err, "channel URLs cannot be reached from templates")
+	assert.NotContains(t, text, "SECRET")
+
+	ch.Templates.OnCheck = `{{.Event}} {{.Checklist}} {{.Checklist.Checked}}/{{.Checklist.Total}}{{range .Item.CheckedBy}} {{.Login}}{{end}}`
+	text, _, err = renderNotificationTemplate(ctx, cl.Config, ch, event, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "on_check test/test#1::qa 1/3", text)
+
+	ch.Templates.OnCheck = `{{range $i := .Checklist.Items}}{{printf "%050000d" 0}}{{end}}`
+	_, _, err = renderNotificationTemplate(ctx, cl.Config, ch, event, nil)
+	assert.Error(t, err, "output is limited")
+}
+
+func TestUsecase_loadConfig_templates(t *testing.T) {
+	config, err := Usecase{}.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`
+notification:
+  channels:
+    default:
+      url: https://hooks.slack.com/services/XXX
+      templates:
+        on_check: '{{.Item.Title'
+`))
+	require.NoError(t, err)
+	assert.NotContains(t, config.Notification.Channels, "default")
+	assert.Equal(t, 1, len(config.Warnings))
+
+	config, err = Usecase{}.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`
+notification:
+  templates:
+    on_complete: '{{.Checklist.Title}} 完了'
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "{{.Checklist.Title}} 完了", config.Notification.Templates.OnComplete)
+}
+
+func TestUsecase_slackMentions(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Mentions = map[string]string{"foo": "U0FOO", "qa": "<!subteam^S0QA|qa>"}
+
+	u := New(nil, nil)
+	u.SetMentions(map[string]string{"foo": "U0OLD", "bar": "U0BAR"})
+
+	m := u.slackMentions(cl.Config)
+	assert.Equal(t, "<@U0FOO>", m.mention("foo"), "prchecklist.yml takes precedence")
+	assert.Equal(t, "<@U0BAR>", m.mention("bar"))
+	assert.Equal(t, "<!subteam^S0QA|qa>", m.mention("qa"))
+	assert.Equal(t, "baz", m.mention("baz"))
+
+	text := removeCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}}.slackMessageText(ctx, m)
+	assert.Contains(t, text, "check removed by alice, cc <@U0FOO>")
+
+	text = completeChecksOfUserEvent{checklist: cl, user: prchecklist.GitHubUserSimple{Login: "bar"}}.slackMessageText(ctx, m)
+	assert.Contains(t, text, "completed checks of <@U0BAR>")
+
+	cl.Config.Notification.Templates.OnRemove = `{{mention .Item.User.Login}} {{.Item.Title}} unchecked`
+	text, _, err := renderNotificationTemplate(ctx, cl.Config, prchecklist.NotificationChannel{}, removeCheckEvent{checklist: cl, item: cl.Item(2)}, m)
+	require.NoError(t, err)
+	assert.Equal(t, "<@U0FOO> Feature A unchecked", text)
+}
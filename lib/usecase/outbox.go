@@ -0,0 +1,254 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-nuts/httputil"
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+const (
+	outboxPollInterval  = 10 * time.Second
+	outboxLeaseDuration = 2 * time.Minute
+	outboxBackoffBase   = 10 * time.Second
+	outboxBackoffMax    = 1 * time.Hour
+	outboxPostTimeout   = 30 * time.Second
+)
+
+var (
+	outboxWorkers     int
+	outboxMaxAttempts int
+)
+
+func getenvInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func init() {
+	flag.IntVar(&outboxWorkers, "outbox-workers", getenvInt("PRCHECKLIST_OUTBOX_WORKERS", 4), "number of workers delivering notifications (PRCHECKLIST_OUTBOX_WORKERS)")
+	flag.IntVar(&outboxMaxAttempts, "outbox-max-attempts", getenvInt("PRCHECKLIST_OUTBOX_MAX_ATTEMPTS", 8), "number of attempts to deliver a notification before giving up (PRCHECKLIST_OUTBOX_MAX_ATTEMPTS)")
+}
+
+var notificationHTTPClient = newWebhookHTTPClient()
+
+// outboxBackoff returns the delay before the next attempt after attempts failures.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBackoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= outboxBackoffMax {
+			return outboxBackoffMax
+		}
+	}
+	return d
+}
+
+// enqueueNotification persists a notification for the channel chName of checklist
+// to the outbox, which is delivered by RunOutbox.
+func (u Usecase) enqueueNotification(ctx context.Context, checklist *prchecklist.Checklist, chName string, ch prchecklist.NotificationChannel, contentType string, body []byte) error {
+	err := u.coreRepo.PutOutboxNotification(ctx, newOutboxNotification(checklist, chName, ch, contentType, body, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	u.wakeOutbox()
+	return nil
+}
+
+// newOutboxNotification builds a pending notification for the channel chName of checklist, due at now.
+func newOutboxNotification(checklist *prchecklist.Checklist, chName string, ch prchecklist.NotificationChannel, contentType string, body []byte, now time.Time) prchecklist.OutboxNotification {
+	return prchecklist.OutboxNotification{
+		ID:            prchecklist.NewOutboxID(now),
+		Checklist:     checklist.String(),
+		Channel:       chName,
+		URL:           ch.URL,
+		ContentType:   contentType,
+		Body:          body,
+		SecretRef:     ch.SecretFrom,
+		Repository:    repositoryPath(checklist.Ref()),
+		State:         prchecklist.OutboxStatePending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (u Usecase) wakeOutbox() {
+	select {
+	case u.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutbox delivers the notifications in the outbox until ctx is done.
+// Failed deliveries are retried with exponential backoff,
+// and notifications which failed too many times are marked dead.
+// Digests are sent as their windows pass.
+// It returns after the deliveries in progress finish.
+func (u Usecase) RunOutbox(ctx context.Context) {
+	jobs := make(chan *prchecklist.OutboxNotification)
+
+	var wg sync.WaitGroup
+	for i := 0; i < outboxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				// Do not abort deliveries in progress on shutdown
+				u.deliverOutboxNotification(context.Background(), n)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := u.flushDueDigests(ctx, time.Now()); err != nil {
+			log.Printf("flushDueDigests: %s", err)
+		}
+
+		if err := u.dispatchOutbox(ctx, jobs); err != nil {
+			log.Printf("dispatchOutbox: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-u.outboxWake:
+		}
+	}
+}
+
+// dispatchOutbox sends pending notifications due to be delivered to jobs.
+// Each notification is leased atomically for a while so that it is not dispatched twice,
+// even by other processes.
+func (u Usecase) dispatchOutbox(ctx context.Context, jobs chan<- *prchecklist.OutboxNotification) error {
+	notifications, err := u.coreRepo.ClaimOutboxNotifications(ctx, time.Now(), outboxLeaseDuration)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		select {
+		case jobs <- n:
+		case <-ctx.Done():
+			// Left to be claimed again after the lease
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (u Usecase) deliverOutboxNotification(ctx context.Context, n *prchecklist.OutboxNotification) {
+	err := u.postNotification(ctx, n)
+	n.Attempts++
+	n.LeasedUntil = time.Time{}
+	if err == nil {
+		if err := u.coreRepo.RemoveOutboxNotification(ctx, n.ID); err != nil {
+			log.Printf("RemoveOutboxNotification(%s): %s", n.ID, err)
+		}
+		return
+	}
+
+	log.Printf("delivering notification %s for %s to %s (attempt %d): %s", n.ID, n.Checklist, n.Channel, n.Attempts, err)
+
+	n.LastError = err.Error()
+	if isWebhookRejected(err) || n.Attempts >= outboxMaxAttempts {
+		n.State = prchecklist.OutboxStateDead
+		log.Printf("notification %s for %s to %s is dead", n.ID, n.Checklist, n.Channel)
+	} else {
+		n.NextAttemptAt = time.Now().Add(outboxBackoff(n.Attempts))
+	}
+
+	if err := u.coreRepo.PutOutboxNotification(ctx, *n); err != nil {
+		log.Printf("PutOutboxNotification(%s): %s", n.ID, err)
+	}
+}
+
+func (u Usecase) postNotification(ctx context.Context, n *prchecklist.OutboxNotification) error {
+	if err := validateWebhookURL(n.URL); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(n.Body))
+	if err != nil {
+		return err
+	}
+
+	if webhookProxy != "" {
+		if err := checkWebhookResolvedHost(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Content-Type", n.ContentType)
+
+	if n.SecretRef != "" {
+		secret, err := u.resolveSecretRef(n.SecretRef, n.Repository)
+		if err != nil {
+			return err
+		}
+		signWebhookRequest(req, []byte(secret), n.ID, n.Body, time.Now())
+	}
+
+	resp, err := httputil.Successful(notificationHTTPClient.Do(req.WithContext(ctx)))
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// GetOutboxNotifications lists the notifications in the outbox with the state.
+func (u Usecase) GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error) {
+	return u.coreRepo.GetOutboxNotifications(ctx, state)
+}
+
+// ReplayOutboxNotification schedules the notification in the outbox specified by id
+// to be delivered again immediately, resetting its attempts.
+// For an event collected for a digest, the digest is sent immediately.
+// Returns nil if the notification is not found,
+// or prchecklist.ErrOutboxNotificationLeased if it is being delivered.
+func (u Usecase) ReplayOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error) {
+	now := time.Now()
+	n, err := u.coreRepo.UpdateOutboxNotification(ctx, id, func(n *prchecklist.OutboxNotification) error {
+		if n.Leased(now) {
+			return prchecklist.ErrOutboxNotificationLeased
+		}
+
+		if n.State != prchecklist.OutboxStateDigest {
+			n.State = prchecklist.OutboxStatePending
+		}
+		n.Attempts = 0
+		n.NextAttemptAt = now
+		return nil
+	})
+	if err != nil || n == nil {
+		return nil, errors.Wrap(err, "UpdateOutboxNotification")
+	}
+
+	u.wakeOutbox()
+
+	return n, nil
+}
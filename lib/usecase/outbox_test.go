@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, outboxBackoff(1))
+	assert.Equal(t, 20*time.Second, outboxBackoff(2))
+	assert.Equal(t, 80*time.Second, outboxBackoff(4))
+	assert.Equal(t, time.Hour, outboxBackoff(20))
+}
+
+func TestUsecase_notifyEvent_enqueues(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{}
+	cl.Config.Notification.Events.OnCheck = []string{"default", "nonexistent"}
+	cl.Config.Notification.Channels = map[string]prchecklist.NotificationChannel{
+		"default": {URL: "https://hooks.slack.com/services/XXX", Format: prchecklist.NotificationFormatText},
+	}
+
+	repo.EXPECT().PutOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, n prchecklist.OutboxNotification) error {
+		assert.Equal(t, "https://hooks.slack.com/services/XXX", n.URL)
+		assert.Equal(t, "default", n.Channel)
+		assert.Equal(t, "test/test#1::qa", n.Checklist)
+		assert.Equal(t, prchecklist.OutboxStatePending, n.State)
+
+		form, err := url.ParseQuery(string(n.Body))
+		require.NoError(t, err)
+		assert.Contains(t, form.Get("payload"), "checked by alice")
+		return nil
+	})
+
+	app := New(nil, repo)
+	err := app.notifyEvent(notificationTestContext(), cl, addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}})
+	assert.NoError(t, err)
+}
+
+func TestUsecase_deliverOutboxNotification(t *testing.T) {
+	webhookAllowPrivateNetwork = true
+	defer func() { webhookAllowPrivateNetwork = false }()
+
+	var status int
+	var body string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	app := New(nil, repo)
+	ctx := context.Background()
+
+	newNotification := func() *prchecklist.OutboxNotification {
+		return &prchecklist.OutboxNotification{
+			ID:          "1",
+			URL:         s.URL,
+			ContentType: "application/x-www-form-urlencoded",
+			Body:        []byte("payload=x"),
+			State:       prchecklist.OutboxStatePending,
+		}
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		status = http.StatusOK
+		repo.EXPECT().RemoveOutboxNotification(gomock.Any(), "1")
+
+		app.deliverOutboxNotification(ctx, newNotification())
+		assert.Equal(t, "payload=x", body)
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		repo.EXPECT().PutOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, n prchecklist.OutboxNotification) error {
+			assert.Equal(t, prchecklist.OutboxStatePending, n.State)
+			assert.Equal(t, 1, n.Attempts)
+			assert.NotEmpty(t, n.LastError)
+			assert.True(t, n.NextAttemptAt.After(time.Now()))
+			return nil
+		})
+
+		app.deliverOutboxNotification(ctx, newNotification())
+	})
+
+	t.Run("dead", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		repo.EXPECT().PutOutboxNotification(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, n prchecklist.OutboxNotification) error {
+			assert.Equal(t, prchecklist.OutboxStateDead, n.State)
+			assert.Equal(t, outboxMaxAttempts, n.Attempts)
+			return nil
+		})
+
+		n := newNotification()
+		n.Attempts = outboxMaxAttempts - 1
+		app.deliverOutboxNotification(ctx, n)
+	})
+}
+
+func TestUsecase_ReplayOutboxNotification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	app := New(nil, repo)
+	ctx := context.Background()
+
+	updateOutboxNotification := func(n prchecklist.OutboxNotification) func(context.Context, string, func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+		return func(_ context.Context, _ string, update func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+			return &n, update(&n)
+		}
+	}
+
+	repo.EXPECT().UpdateOutboxNotification(gomock.Any(), "dead", gomock.Any()).DoAndReturn(updateOutboxNotification(prchecklist.OutboxNotification{
+		ID:       "dead",
+		State:    prchecklist.OutboxStateDead,
+		Attempts: 8,
+	}))
+	repo.EXPECT().UpdateOutboxNotification(gomock.Any(), "leased", gomock.Any()).DoAndReturn(updateOutboxNotification(prchecklist.OutboxNotification{
+		ID:          "leased",
+		State:       prchecklist.OutboxStatePending,
+		LeasedUntil: time.Now().Add(time.Minute),
+	}))
+	repo.EXPECT().UpdateOutboxNotification(gomock.Any(), "digest", gomock.Any()).DoAndReturn(updateOutboxNotification(prchecklist.OutboxNotification{
+		ID:            "digest",
+		State:         prchecklist.OutboxStateDigest,
+		NextAttemptAt: time.Now().Add(time.Hour),
+	}))
+	repo.EXPECT().UpdateOutboxNotification(gomock.Any(), "none", gomock.Any()).Return(nil, nil)
+
+	n, err := app.ReplayOutboxNotification(ctx, "dead")
+	require.NoError(t, err)
+	require.NotNil(t, n)
+	assert.Equal(t, prchecklist.OutboxStatePending, n.State)
+	assert.Equal(t, 0, n.Attempts)
+
+	// The digest gets due
+	n, err = app.ReplayOutboxNotification(ctx, "digest")
+	require.NoError(t, err)
+	assert.Equal(t, prchecklist.OutboxStateDigest, n.State)
+	assert.False(t, n.NextAttemptAt.After(time.Now()))
+
+	_, err = app.ReplayOutboxNotification(ctx, "leased")
+	assert.True(t, errors.Is(err, prchecklist.ErrOutboxNotificationLeased), err)
+
+	n, err = app.ReplayOutboxNotification(ctx, "none")
+	require.NoError(t, err)
+	assert.Nil(t, n)
+}
+
+func TestUsecase_dispatchOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	app := New(nil, repo)
+
+	n := &prchecklist.OutboxNotification{ID: "1", State: prchecklist.OutboxStatePending}
+	repo.EXPECT().ClaimOutboxNotifications(gomock.Any(), gomock.Any(), outboxLeaseDuration).Return([]*prchecklist.OutboxNotification{n}, nil)
+
+	jobs := make(chan *prchecklist.OutboxNotification, 1)
+	require.NoError(t, app.dispatchOutbox(context.Background(), jobs))
+	assert.Equal(t, n, <-jobs)
+}
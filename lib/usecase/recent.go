@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+var recentBaseBranches = os.Getenv("PRCHECKLIST_RECENT_BASE_BRANCHES")
+
+func init() {
+	flag.StringVar(&recentBaseBranches, "recent-base-branches", recentBaseBranches, "comma-separated base branches of pull requests listed on the top page; the default branch of each repository if empty (PRCHECKLIST_RECENT_BASE_BRANCHES)")
+}
+
+// GetRecentPullRequests list recent pullrequests the user may be interested in.
+// Crafted for the top page.
+// Base branches of q default to the ones by -recent-base-branches.
+func (u Usecase) GetRecentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+	if len(q.BaseBranches) == 0 {
+		for _, b := range strings.Split(recentBaseBranches, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				q.BaseBranches = append(q.BaseBranches, b)
+			}
+		}
+	}
+
+	result, err := u.github.GetRecentPullRequests(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.ReleaseOnly {
+		for repo, pullReqs := range result.PullRequests {
+			releases := []*prchecklist.PullRequest{}
+			for _, pullReq := range pullReqs {
+				if len(u.mergedPullRequestRefs(pullReq)) > 0 {
+					releases = append(releases, pullReq)
+				}
+				// Not needed by the top page
+				pullReq.Commits = nil
+			}
+			if len(releases) == 0 {
+				delete(result.PullRequests, repo)
+			} else {
+				result.PullRequests[repo] = releases
+			}
+		}
+	}
+
+	return result, nil
+}
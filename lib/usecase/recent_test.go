@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func TestUsecase_GetRecentPullRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	recentBaseBranches = "master, main"
+	defer func() { recentBaseBranches = "" }()
+
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, nil)
+
+	github.EXPECT().GetRecentPullRequests(gomock.Any(), prchecklist.RecentPullRequestsQuery{
+		BaseBranches: []string{"master", "main"},
+		ReleaseOnly:  true,
+	}).Return(&prchecklist.RecentPullRequests{
+		PullRequests: map[string][]*prchecklist.PullRequest{
+			"owner/a": {
+				{Number: 1, Commits: []prchecklist.Commit{{Message: "Merge pull request #10 from owner/feature"}}},
+				{Number: 2, Commits: []prchecklist.Commit{{Message: "Fix typo"}}},
+			},
+			"owner/b": {
+				{Number: 3, Commits: []prchecklist.Commit{}},
+			},
+		},
+		NextCursor: "CURSOR",
+	}, nil)
+
+	result, err := app.GetRecentPullRequests(context.Background(), prchecklist.RecentPullRequestsQuery{ReleaseOnly: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "CURSOR", result.NextCursor)
+	assert.Equal(t, 1, len(result.PullRequests))
+	require.Equal(t, 1, len(result.PullRequests["owner/a"]))
+	assert.Equal(t, 1, result.PullRequests["owner/a"][0].Number)
+	assert.Nil(t, result.PullRequests["owner/a"][0].Commits)
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+const (
+	secretRefPrefix      = "secret:"
+	secretEnvPrefix      = "PRCHECKLIST_SECRET_"
+	secretEnvReposSuffix = "_REPOS"
+)
+
+// Secret is a server-side secret which prchecklist.yml can refer to.
+type Secret struct {
+	Value string `yaml:"value"`
+	// Repos are the patterns of the repositories allowed to refer to the secret,
+	// such as "owner/repo" or "owner/*", prefixed by the host for GitHub Enterprise.
+	Repos []string `yaml:"repos"`
+}
+
+// SetSecrets sets the server-side secrets which prchecklist.yml can refer to
+// by "secret:NAME", such as webhook URLs.
+func (u *Usecase) SetSecrets(secrets map[string]Secret) {
+	u.secrets = secrets
+}
+
+// repositoryPath returns the path of the repository of clRef matched against Secret.Repos.
+func repositoryPath(clRef prchecklist.ChecklistRef) string {
+	p := clRef.Owner + "/" + clRef.Repo
+	if clRef.Host != "" {
+		p = clRef.Host + "/" + p
+	}
+	return p
+}
+
+// resolveSecretRef resolves a reference like "secret:SLACK_QA" from the repository repo to the value of the secret,
+// which is looked up from the secrets given by SetSecrets
+// and then the environment variables PRCHECKLIST_SECRET_SLACK_QA and PRCHECKLIST_SECRET_SLACK_QA_REPOS,
+// the comma-separated patterns of the repositories allowed.
+func (u Usecase) resolveSecretRef(ref string, repo string) (string, error) {
+	if !strings.HasPrefix(ref, secretRefPrefix) {
+		return "", errors.Errorf("invalid secret reference: %q", ref)
+	}
+
+	name := ref[len(secretRefPrefix):]
+	if name == "" {
+		return "", errors.Errorf("invalid secret reference: %q", ref)
+	}
+
+	secret, ok := u.secrets[name]
+	if !ok {
+		secret.Value, ok = os.LookupEnv(secretEnvPrefix + name)
+		if repos := os.Getenv(secretEnvPrefix + name + secretEnvReposSuffix); repos != "" {
+			secret.Repos = strings.Split(repos, ",")
+		}
+	}
+	if !ok {
+		return "", errors.Errorf("secret not found: %q", name)
+	}
+
+	for _, pattern := range secret.Repos {
+		if matchRepoPattern(strings.TrimSpace(pattern), repo) {
+			return secret.Value, nil
+		}
+	}
+
+	return "", errors.Errorf("secret %q is not allowed for %s", name, repo)
+}
+
+// matchRepoPattern reports whether repo matches pattern, which is matched by path.Match,
+// except that a trailing "/**" matches any path segments following, such as nested GitLab groups and their projects.
+func matchRepoPattern(pattern, repo string) bool {
+	prefix := strings.TrimSuffix(pattern, "/**")
+	if prefix == pattern {
+		matched, _ := path.Match(pattern, repo)
+		return matched
+	}
+
+	n := strings.Count(prefix, "/") + 1
+	segments := strings.SplitN(repo, "/", n+1)
+	if len(segments) <= n || segments[n] == "" {
+		return false
+	}
+	matched, _ := path.Match(prefix, strings.Join(segments[:n], "/"))
+	return matched
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func TestUsecase_loadConfig_urlFrom(t *testing.T) {
+	os.Setenv("PRCHECKLIST_SECRET_SLACK_ENV", "https://hooks.slack.com/services/ENV")
+	defer os.Unsetenv("PRCHECKLIST_SECRET_SLACK_ENV")
+	os.Setenv("PRCHECKLIST_SECRET_SLACK_ENV_REPOS", "test/*")
+	defer os.Unsetenv("PRCHECKLIST_SECRET_SLACK_ENV_REPOS")
+
+	u := New(nil, nil)
+	u.SetSecrets(map[string]Secret{
+		"SLACK_QA": {Value: "https://hooks.slack.com/services/QA", Repos: []string{"test/test"}},
+	})
+
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "default"}
+
+	config, err := u.loadConfig(context.Background(), clRef, []byte(`
+notification:
+  channels:
+    qa:
+      url_from: secret:SLACK_QA
+    env:
+      url_from: secret:SLACK_ENV
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.slack.com/services/QA", config.Notification.Channels["qa"].URL)
+	assert.Equal(t, "https://hooks.slack.com/services/ENV", config.Notification.Channels["env"].URL)
+
+	config, err = u.loadConfig(context.Background(), clRef, []byte(`
+notification:
+  channels:
+    nonexistent:
+      url_from: secret:NONEXISTENT
+    env:
+      url_from: env:HOME
+    both:
+      url: https://hooks.slack.com/services/XXX
+      url_from: secret:SLACK_QA
+`))
+	require.NoError(t, err)
+	assert.Empty(t, config.Notification.Channels)
+	assert.Equal(t, 3, len(config.Warnings), "only secret: is supported")
+}
+
+func TestUsecase_resolveSecretRef(t *testing.T) {
+	os.Setenv("PRCHECKLIST_SECRET_UNSCOPED", "unscoped")
+	defer os.Unsetenv("PRCHECKLIST_SECRET_UNSCOPED")
+
+	u := New(nil, nil)
+	u.SetSecrets(map[string]Secret{
+		"REPO":  {Value: "repo", Repos: []string{"test/test"}},
+		"OWNER": {Value: "owner", Repos: []string{"test/*", "ghe.example.com/test/*"}},
+		"GROUP": {Value: "https://hooks.example.com/group", Repos: []string{"gitlab.example.com/group/**"}},
+	})
+
+	tests := []struct {
+		ref, repo string
+		value     string
+	}{
+		{"secret:REPO", "test/test", "repo"},
+		{"secret:REPO", "test/other", ""},
+		{"secret:REPO", "other/test", ""},
+		{"secret:REPO", "ghe.example.com/test/test", ""},
+		{"secret:OWNER", "test/other", "owner"},
+		{"secret:OWNER", "ghe.example.com/test/other", "owner"},
+		{"secret:OWNER", "other/test", ""},
+		{"secret:OWNER", "", ""},
+		{"secret:OWNER", "gitlab.example.com/test/sub/repo", ""},
+		{"secret:GROUP", "gitlab.example.com/group/repo", "https://hooks.example.com/group"},
+		{"secret:GROUP", "gitlab.example.com/group/sub/repo", "https://hooks.example.com/group"},
+		{"secret:GROUP", "gitlab.example.com/group", ""},
+		{"secret:GROUP", "gitlab.example.com/group/", ""},
+		{"secret:GROUP", "gitlab.example.com/other/group/repo", ""},
+		{"secret:GROUP", "group/repo", ""},
+		{"secret:UNSCOPED", "test/test", ""},
+	}
+	for _, test := range tests {
+		value, err := u.resolveSecretRef(test.ref, test.repo)
+		if test.value == "" {
+			assert.Error(t, err, "%s from %q", test.ref, test.repo)
+		} else {
+			assert.NoError(t, err, "%s from %q", test.ref, test.repo)
+		}
+		assert.Equal(t, test.value, value, "%s from %q", test.ref, test.repo)
+	}
+
+	config, err := u.loadConfig(context.Background(), prchecklist.ChecklistRef{Owner: "other", Repo: "test"}, []byte(`
+notification:
+  channels:
+    qa:
+      url_from: secret:REPO
+`))
+	require.NoError(t, err)
+	assert.Empty(t, config.Notification.Channels)
+	require.Equal(t, 1, len(config.Warnings))
+	assert.Contains(t, config.Warnings[0], `secret "REPO" is not allowed for other/test`)
+
+	config, err = u.loadConfig(context.Background(), prchecklist.ChecklistRef{Host: "gitlab.example.com", Owner: "group/sub", Repo: "repo"}, []byte(`
+notification:
+  channels:
+    qa:
+      url_from: secret:GROUP
+`))
+	require.NoError(t, err)
+	assert.Empty(t, config.Warnings)
+	assert.Equal(t, "https://hooks.example.com/group", config.Notification.Channels["qa"].URL)
+}
@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// Limits of text length in Slack blocks.
+const (
+	slackHeaderTextMaxLen  = 150
+	slackSectionTextMaxLen = 3000
+)
+
+type slackMessagePayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks,omitempty"`
+}
+
+// slackBlock is a subset of Slack Block Kit layout blocks.
+// https://api.slack.com/reference/block-kit/blocks
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"` // *slackText or *slackButton
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type     string     `json:"type"`
+	Text     *slackText `json:"text"`
+	ActionID string     `json:"action_id"`
+	Value    string     `json:"value"`
+	Style    string     `json:"style,omitempty"`
+}
+
+func slackHeaderBlock(text string) slackBlock {
+	return slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncateText(text, slackHeaderTextMaxLen)},
+	}
+}
+
+func slackSectionBlock(text string) slackBlock {
+	return slackBlock{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: truncateText(text, slackSectionTextMaxLen)},
+	}
+}
+
+func truncateText(text string, maxLen int) string {
+	if runes := []rune(text); len(runes) > maxLen {
+		return string(runes[:maxLen-1]) + "…"
+	}
+	return text
+}
+
+func slackContextBlock(texts ...string) slackBlock {
+	elements := make([]interface{}, len(texts))
+	for i, text := range texts {
+		elements[i] = &slackText{Type: "mrkdwn", Text: text}
+	}
+	return slackBlock{
+		Type:     "context",
+		Elements: elements,
+	}
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes control characters of Slack's mrkdwn.
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// slackMentions maps GitHub logins to Slack user IDs.
+type slackMentions map[string]string
+
+// mention returns the Slack mention of the GitHub user login,
+// or the escaped login if no Slack user is known.
+// IDs already in the mention format, such as "<!subteam^S0123|qa>", are used as they are.
+func (m slackMentions) mention(login string) string {
+	id := m[login]
+	if id == "" {
+		return slackEscape(login)
+	}
+	if strings.HasPrefix(id, "<") {
+		return id
+	}
+	return "<@" + id + ">"
+}
+
+// slackMentions merges the mappings of the server and of config.
+// The latter takes precedence.
+func (u Usecase) slackMentions(config *prchecklist.ChecklistConfig) slackMentions {
+	m := make(slackMentions, len(u.mentions)+len(config.Notification.Mentions))
+	for login, id := range u.mentions {
+		m[login] = id
+	}
+	for login, id := range config.Notification.Mentions {
+		m[login] = id
+	}
+	return m
+}
+
+// slackItemText formats item as a link to the feature pull request with its author.
+func slackItemText(item *prchecklist.ChecklistItem) string {
+	return fmt.Sprintf("<%s|#%d> %s (%s)", item.URL, item.Number, slackEscape(item.Title), slackEscape(item.User.Login))
+}
+
+// slackChecklistBlocks builds the blocks common to all the events,
+// which describe the checklist and what happened to it by text.
+func slackChecklistBlocks(ctx context.Context, checklist *prchecklist.Checklist, text string) []slackBlock {
+	return append(slackChecklistHeaderBlocks(ctx, checklist), slackSectionBlock(text))
+}
+
+// slackChecklistHeaderBlocks builds the blocks of the title, stage and progress of the checklist.
+func slackChecklistHeaderBlocks(ctx context.Context, checklist *prchecklist.Checklist) []slackBlock {
+	u := prchecklist.BuildURL(ctx, checklist.Path()).String()
+	checked, total := checklist.Progress()
+	return []slackBlock{
+		slackHeaderBlock(checklist.Title),
+		slackContextBlock(
+			fmt.Sprintf("<%s|%s>", u, checklist),
+			fmt.Sprintf("*Stage:* %s", slackEscape(checklist.Stage)),
+			fmt.Sprintf("*Progress:* %d/%d checked", checked, total),
+		),
+	}
+}
+
+// slackRemainingBlocks lists the unchecked items grouped by their authors.
+func slackRemainingBlocks(checklist *prchecklist.Checklist) []slackBlock {
+	itemsByAuthor := map[string][]*prchecklist.ChecklistItem{}
+	authors := []string{}
+	for _, item := range checklist.Items {
+		if len(item.CheckedBy) > 0 {
+			continue
+		}
+		login := item.User.Login
+		if _, ok := itemsByAuthor[login]; !ok {
+			authors = append(authors, login)
+		}
+		itemsByAuthor[login] = append(itemsByAuthor[login], item)
+	}
+
+	if len(authors) == 0 {
+		return nil
+	}
+
+	sort.Strings(authors)
+
+	var b strings.Builder
+	b.WriteString("*Remaining*")
+	for _, login := range authors {
+		fmt.Fprintf(&b, "\n*%s*", slackEscape(login))
+		for _, item := range itemsByAuthor[login] {
+			fmt.Fprintf(&b, "\n• <%s|#%d> %s", item.URL, item.Number, slackEscape(item.Title))
+		}
+	}
+
+	return []slackBlock{
+		{Type: "divider"},
+		slackSectionBlock(b.String()),
+	}
+}
+
+// slackSignedOffBlocks lists all the items along with who checked them.
+func slackSignedOffBlocks(checklist *prchecklist.Checklist) []slackBlock {
+	if len(checklist.Items) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("*Signed off*")
+	for _, item := range checklist.Items {
+		logins := make([]string, len(item.CheckedBy))
+		for i, user := range item.CheckedBy {
+			logins[i] = slackEscape(user.Login)
+		}
+		fmt.Fprintf(&b, "\n• %s: %s", slackItemText(item), strings.Join(logins, ", "))
+	}
+
+	return []slackBlock{
+		{Type: "divider"},
+		slackSectionBlock(b.String()),
+	}
+}
@@ -0,0 +1,416 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-nuts/httputil"
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+const (
+	slackActionIDCheck   = "prchecklist_check"
+	slackActionIDUncheck = "prchecklist_uncheck"
+
+	// slackRequestMaxAge is how old requests from Slack can be, to prevent replays.
+	slackRequestMaxAge = 5 * time.Minute
+	// slackLinkTokenTTL is how long the URLs to link Slack users are valid.
+	slackLinkTokenTTL = 15 * time.Minute
+)
+
+var slackSigningSecret = os.Getenv("PRCHECKLIST_SLACK_SIGNING_SECRET")
+
+func init() {
+	flag.StringVar(&slackSigningSecret, "slack-signing-secret", slackSigningSecret, "signing secret of the Slack app, which enables buttons in notifications (PRCHECKLIST_SLACK_SIGNING_SECRET)")
+}
+
+// SlackInteractivityEnabled reports whether requests from Slack are accepted,
+// that is, the Slack signing secret is given.
+func SlackInteractivityEnabled() bool {
+	return slackSigningSecret != ""
+}
+
+// VerifySlackRequest verifies the signature of a request from Slack
+// by its headers and body.
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifySlackRequest(header http.Header, body []byte, now time.Time) error {
+	if !SlackInteractivityEnabled() {
+		return errors.New("Slack signing secret is not set")
+	}
+
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid timestamp")
+	}
+	if math.Abs(now.Sub(time.Unix(ts, 0)).Seconds()) > slackRequestMaxAge.Seconds() {
+		return errors.Errorf("timestamp too old: %s", timestamp)
+	}
+
+	sig := header.Get("X-Slack-Signature")
+	if !strings.HasPrefix(sig, "v0=") {
+		return errors.New("invalid signature")
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(sig, "v0="))
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	message := append([]byte("v0:"+timestamp+":"), body...)
+	if !signature.Verify([]byte(slackSigningSecret), message, sigBytes) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+// slackActionValue is the value of buttons, which points to a checklist item.
+type slackActionValue struct {
+	Host    string `json:"h,omitempty"`
+	Owner   string `json:"o"`
+	Repo    string `json:"r"`
+	Number  int    `json:"n"`
+	Stage   string `json:"s"`
+	Feature int    `json:"f"`
+}
+
+func (v slackActionValue) checklistRef() prchecklist.ChecklistRef {
+	return prchecklist.ChecklistRef{Host: v.Host, Owner: v.Owner, Repo: v.Repo, Number: v.Number, Stage: v.Stage}
+}
+
+// slackItemButtonBlock builds a block with a button to check or uncheck item,
+// or returns nil if interactivity is not enabled.
+func slackItemButtonBlock(checklist *prchecklist.Checklist, item *prchecklist.ChecklistItem, check bool) []slackBlock {
+	if !SlackInteractivityEnabled() {
+		return nil
+	}
+
+	value, _ := json.Marshal(slackActionValue{
+		Host:    checklist.Host,
+		Owner:   checklist.Owner,
+		Repo:    checklist.Repo,
+		Number:  checklist.Number,
+		Stage:   checklist.Stage,
+		Feature: item.Number,
+	})
+
+	button := &slackButton{
+		Type:     "button",
+		Text:     &slackText{Type: "plain_text", Text: "Check"},
+		ActionID: slackActionIDCheck,
+		Value:    string(value),
+		Style:    "primary",
+	}
+	if !check {
+		button.Text.Text = "Uncheck"
+		button.ActionID = slackActionIDUncheck
+		button.Style = ""
+	}
+
+	return []slackBlock{{Type: "actions", Elements: []interface{}{button}}}
+}
+
+// slackInteractionPayload is a subset of the payloads of interactions from Slack.
+// https://api.slack.com/reference/interaction-payloads/block-actions
+type slackInteractionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		TeamID   string `json:"team_id"`
+	} `json:"user"`
+	Team struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// HandleSlackInteraction handles an interaction payload from Slack, which must be verified by VerifySlackRequest.
+// As Slack requires responses in 3 seconds, the actions are performed in background
+// and the results are posted to the response URL of the interaction.
+func (u Usecase) HandleSlackInteraction(ctx context.Context, payload []byte) error {
+	var p slackInteractionPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errors.Wrap(err, "json.Unmarshal")
+	}
+
+	if p.Type != "block_actions" {
+		return nil
+	}
+
+	ctx = prchecklist.NewContextWithValuesOf(ctx)
+	go func() {
+		if err := u.performSlackActions(ctx, p); err != nil {
+			log.Printf("performSlackActions: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+func (u Usecase) performSlackActions(ctx context.Context, p slackInteractionPayload) error {
+	teamID := p.User.TeamID
+	if teamID == "" {
+		teamID = p.Team.ID
+	}
+
+	for _, action := range p.Actions {
+		if action.ActionID != slackActionIDCheck && action.ActionID != slackActionIDUncheck {
+			continue
+		}
+
+		link, err := u.getSlackUserLink(ctx, teamID, p.User.ID)
+		if err != nil {
+			return err
+		}
+
+		linkURL := prchecklist.BuildURL(ctx, "/slack/link")
+		linkURL.RawQuery = url.Values{"token": {newSlackLinkToken(SlackLinkRequest{
+			TeamID:     teamID,
+			TeamDomain: p.Team.Domain,
+			UserID:     p.User.ID,
+			UserName:   p.User.Username,
+		}, time.Now())}}.Encode()
+		if link == nil {
+			return u.respondSlack(ctx, p.ResponseURL, fmt.Sprintf("Link your Slack account to GitHub first by visiting <%s|this link>, and try again.", linkURL))
+		}
+
+		var v slackActionValue
+		if err := json.Unmarshal([]byte(action.Value), &v); err != nil {
+			return errors.Wrap(err, "json.Unmarshal")
+		}
+
+		user := link.GitHubUser()
+		if user.Host != v.Host {
+			return u.respondSlack(ctx, p.ResponseURL, fmt.Sprintf("Your Slack account is linked to a user of another GitHub than %s.", v.checklistRef()))
+		}
+
+		perform := func(user prchecklist.GitHubUser) (*prchecklist.Checklist, error) {
+			ctx := context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, user.HTTPClient(ctx))
+			ctx = prchecklist.ContextWithUserID(ctx, user.ID)
+			ctx = prchecklist.ContextWithHost(ctx, user.Host)
+			if action.ActionID == slackActionIDCheck {
+				return u.AddCheck(ctx, v.checklistRef(), v.Feature, user)
+			}
+			return u.RemoveCheck(ctx, v.checklistRef(), v.Feature, user)
+		}
+
+		// The token saved in the link may have expired or been revoked since linked,
+		// so refresh it up front or once GitHub rejects it.
+		var checklist *prchecklist.Checklist
+		if user.Token != nil && !user.Token.Expiry.IsZero() && !user.Token.Valid() {
+			user, err = u.RefreshUserToken(ctx, user)
+		}
+		if err == nil {
+			checklist, err = perform(user)
+			if errors.Is(err, prchecklist.ErrNotAuthed) {
+				user, err = u.RefreshUserToken(ctx, user)
+				if err == nil {
+					checklist, err = perform(user)
+				}
+			}
+		}
+		if errors.Is(err, prchecklist.ErrNotAuthed) {
+			return u.respondSlack(ctx, p.ResponseURL, fmt.Sprintf("Your GitHub authorization has expired. Link your Slack account to GitHub again by visiting <%s|this link>, and try again.", linkURL))
+		} else if err != nil {
+			log.Printf("Slack action %s by %s: %s", action.ActionID, user.Login, err)
+			return u.respondSlack(ctx, p.ResponseURL, fmt.Sprintf("Failed: %s", slackEscape(err.Error())))
+		}
+
+		verb := "Checked"
+		if action.ActionID == slackActionIDUncheck {
+			verb = "Unchecked"
+		}
+		text := fmt.Sprintf("%s #%d of %s", verb, v.Feature, checklist)
+		if item := checklist.Item(v.Feature); item != nil {
+			text = fmt.Sprintf("%s %s of %s", verb, slackItemText(item), checklist)
+		}
+		if err := u.respondSlack(ctx, p.ResponseURL, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// respondSlack posts an ephemeral message to the response URL of an interaction.
+func (u Usecase) respondSlack(ctx context.Context, responseURL, text string) error {
+	if err := validateWebhookURL(responseURL); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"response_type":    "ephemeral",
+		"replace_original": false,
+		"text":             text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httputil.Successful(notificationHTTPClient.Do(req.WithContext(ctx)))
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// SlackLinkRequest is the Slack user to be linked to a GitHub user by a link token.
+type SlackLinkRequest struct {
+	TeamID     string
+	TeamDomain string
+	UserID     string
+	UserName   string
+}
+
+// newSlackLinkToken creates a token to link the Slack user to a GitHub user,
+// signed with the Slack signing secret.
+func newSlackLinkToken(r SlackLinkRequest, now time.Time) string {
+	message := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s:%d:%s:%s", r.TeamID, r.UserID, now.Add(slackLinkTokenTTL).Unix(), r.TeamDomain, r.UserName)))
+	return message + "." + signature.SignHex([]byte(slackSigningSecret), []byte(message))
+}
+
+// parseSlackLinkToken verifies the token created by newSlackLinkToken and returns the Slack user in it.
+func parseSlackLinkToken(token string, now time.Time) (*SlackLinkRequest, error) {
+	p := strings.LastIndexByte(token, '.')
+	if p == -1 {
+		return nil, errors.New("malformed token")
+	}
+
+	message, sig := token[:p], token[p+1:]
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil || !signature.Verify([]byte(slackSigningSecret), []byte(message), sigBytes) {
+		return nil, errors.New("invalid token")
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(message)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token")
+	}
+
+	parts := strings.SplitN(string(buf), ":", 5)
+	if len(parts) != 5 {
+		return nil, errors.New("malformed token")
+	}
+
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token")
+	}
+	if now.After(time.Unix(expiry, 0)) {
+		return nil, errors.New("token expired")
+	}
+
+	return &SlackLinkRequest{TeamID: parts[0], UserID: parts[1], TeamDomain: parts[3], UserName: parts[4]}, nil
+}
+
+// ParseSlackLinkToken returns the Slack user to be linked by the token,
+// to confirm the visitor before LinkSlackUser.
+func (u Usecase) ParseSlackLinkToken(token string) (*SlackLinkRequest, error) {
+	if !SlackInteractivityEnabled() {
+		return nil, errors.New("Slack signing secret is not set")
+	}
+
+	return parseSlackLinkToken(token, time.Now())
+}
+
+// LinkSlackUser links the Slack user in token, which is given in the linking URL sent to Slack,
+// to the GitHub user, so that the Slack user can check items from Slack.
+// user must have its token, with which checks from Slack are made.
+func (u Usecase) LinkSlackUser(ctx context.Context, token string, user prchecklist.GitHubUser) error {
+	if !SlackInteractivityEnabled() {
+		return errors.New("Slack signing secret is not set")
+	}
+
+	r, err := parseSlackLinkToken(token, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return u.putSlackUserLink(ctx, prchecklist.SlackUserLink{
+		TeamID:       r.TeamID,
+		UserID:       r.UserID,
+		GitHubUserID: user.ID,
+		GitHubLogin:  user.Login,
+		GitHubHost:   user.Host,
+		Token:        user.Token,
+	})
+}
+
+// RefreshUserToken refreshes the token of user and saves it to the Slack users linked to user,
+// which keep their own copies of the token.
+// Refreshes of the same user are serialized, and the ones by a refresh token already used
+// get the token refreshed by it, as refresh tokens can be used only once.
+// Returns prchecklist.ErrNotAuthed if GitHub does not refresh the token.
+func (u Usecase) RefreshUserToken(ctx context.Context, user prchecklist.GitHubUser) (prchecklist.GitHubUser, error) {
+	if u.tokensMu != nil {
+		unlock := u.tokensMu.lock(fmt.Sprintf("%s:%d", user.Host, user.ID))
+		defer unlock()
+	}
+
+	var refreshToken string
+	if user.Token != nil {
+		refreshToken = user.Token.RefreshToken
+	}
+
+	if u.refreshedTokens != nil && refreshToken != "" {
+		if token := u.refreshedTokens.get(refreshToken, time.Now()); token != nil {
+			user.Token = token
+			return user, nil
+		}
+	}
+
+	token, err := u.github.RefreshToken(prchecklist.ContextWithHost(ctx, user.Host), user.Token)
+	if err != nil {
+		return user, err
+	}
+
+	if u.refreshedTokens != nil && refreshToken != "" {
+		u.refreshedTokens.put(refreshToken, token, time.Now())
+	}
+
+	user.Token = token
+
+	links, err := u.getSlackUserLinksOf(ctx, user.Host, user.ID)
+	if err != nil {
+		return user, err
+	}
+
+	// The refresh token is already used, so return the new token even if links are not updated
+	for _, link := range links {
+		link.Token = token
+		if err := u.putSlackUserLink(ctx, link); err != nil {
+			log.Printf("RefreshUserToken(%s): %s", user.Login, err)
+		}
+	}
+
+	return user, nil
+}
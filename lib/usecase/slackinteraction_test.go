@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+)
+
+func TestVerifySlackRequest(t *testing.T) {
+	slackSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+	defer func() { slackSigningSecret = "" }()
+
+	now := time.Now()
+	body := []byte("payload=%7B%7D")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	header := http.Header{}
+	header.Set("X-Slack-Request-Timestamp", timestamp)
+	header.Set("X-Slack-Signature", "v0="+signature.SignHex([]byte(slackSigningSecret), []byte("v0:"+timestamp+":"+string(body))))
+
+	assert.NoError(t, VerifySlackRequest(header, body, now))
+	assert.Error(t, VerifySlackRequest(header, []byte("payload=%7B%22x%22%7D"), now))
+	assert.Error(t, VerifySlackRequest(header, body, now.Add(10*time.Minute)))
+
+	header.Set("X-Slack-Signature", "v0=00")
+	assert.Error(t, VerifySlackRequest(header, body, now))
+}
+
+func TestSlackLinkToken(t *testing.T) {
+	slackSigningSecret = "s3cret"
+	defer func() { slackSigningSecret = "" }()
+
+	now := time.Now()
+	token := newSlackLinkToken(SlackLinkRequest{TeamID: "T1", TeamDomain: "example", UserID: "U1", UserName: "alice"}, now)
+
+	r, err := parseSlackLinkToken(token, now)
+	require.NoError(t, err)
+	assert.Equal(t, &SlackLinkRequest{TeamID: "T1", TeamDomain: "example", UserID: "U1", UserName: "alice"}, r)
+
+	_, err = parseSlackLinkToken(token, now.Add(time.Hour))
+	assert.Error(t, err, "expired")
+
+	_, err = parseSlackLinkToken("x"+token, now)
+	assert.Error(t, err, "tampered")
+}
+
+func TestAddCheckEvent_slackMessageBlocks_buttons(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	event := addCheckEvent{checklist: cl, item: cl.Item(2), user: prchecklist.GitHubUser{Login: "alice"}}
+
+	assert.NotContains(t, blocksText(t, event.slackMessageBlocks(ctx, nil)), slackActionIDUncheck)
+
+	slackSigningSecret = "s3cret"
+	defer func() { slackSigningSecret = "" }()
+
+	text := blocksText(t, event.slackMessageBlocks(ctx, nil))
+	assert.Contains(t, text, `"action_id":"prchecklist_uncheck"`)
+	assert.Contains(t, text, `"value":"{\"o\":\"test\",\"r\":\"test\",\"n\":1,\"s\":\"qa\",\"f\":2}"`)
+}
+
+func TestUsecase_performSlackActions(t *testing.T) {
+	slackSigningSecret = "s3cret"
+	defer func() { slackSigningSecret = "" }()
+
+	slackTokenKey = "k3y"
+	defer func() { slackTokenKey = "" }()
+
+	webhookAllowPrivateNetwork = true
+	defer func() { webhookAllowPrivateNetwork = false }()
+
+	var responses []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		var v struct{ Text string }
+		require.NoError(t, json.Unmarshal(b, &v))
+		responses = append(responses, v.Text)
+	}))
+	defer s.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+	ctx := notificationTestContext()
+
+	var p slackInteractionPayload
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "type": "block_actions",
+  "user": {"id": "U1", "team_id": "T1"},
+  "actions": [{"action_id": "prchecklist_check", "value": "{\"o\":\"test\",\"r\":\"test\",\"n\":1,\"s\":\"default\",\"f\":2}"}]
+}`), &p))
+	p.ResponseURL = s.URL
+
+	// Not linked yet
+	repo.EXPECT().GetSlackUserLink(gomock.Any(), "T1", "U1").Return(nil, nil)
+	require.NoError(t, app.performSlackActions(ctx, p))
+	require.Equal(t, 1, len(responses))
+	assert.Contains(t, responses[0], "https://prchecklist.test/slack/link?token=")
+
+	// Linked
+	clRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "default"}
+	repo.EXPECT().GetSlackUserLink(gomock.Any(), "T1", "U1").Return(&prchecklist.SlackUserLink{
+		TeamID:       "T1",
+		UserID:       "U1",
+		GitHubUserID: 1,
+		GitHubLogin:  "alice",
+		Token:        &oauth2.Token{AccessToken: "token"},
+	}, nil)
+	repo.EXPECT().AddCheck(gomock.Any(), clRef, "2", prchecklist.GitHubUser{ID: 1, Login: "alice", Token: &oauth2.Token{AccessToken: "token"}}).Return(nil)
+	setupMocks(clRef, github, repo)
+
+	require.NoError(t, app.performSlackActions(ctx, p))
+	require.Equal(t, 2, len(responses))
+	assert.Contains(t, responses[1], "Checked")
+
+	// Token rejected, refreshed and retried
+	link := prchecklist.SlackUserLink{
+		TeamID:       "T1",
+		UserID:       "U1",
+		GitHubUserID: 1,
+		GitHubLogin:  "alice",
+		Token:        &oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh"},
+	}
+	refreshed := &oauth2.Token{AccessToken: "refreshed", RefreshToken: "refresh2"}
+	repo.EXPECT().GetSlackUserLink(gomock.Any(), "T1", "U1").Return(&link, nil)
+	repo.EXPECT().AddCheck(gomock.Any(), clRef, "2", link.GitHubUser()).Return(nil)
+	github.EXPECT().GetPullRequest(gomock.Any(), clRef, true).Return(nil, nil, errors.Wrap(prchecklist.ErrNotAuthed, "GetPullRequest"))
+	github.EXPECT().RefreshToken(gomock.Any(), link.Token).Return(refreshed, nil)
+	repo.EXPECT().GetSlackUserLinksOf(gomock.Any(), "", 1).Return([]prchecklist.SlackUserLink{link}, nil)
+	repo.EXPECT().PutSlackUserLink(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, l prchecklist.SlackUserLink) error {
+		assert.NotEqual(t, "refreshed", l.Token.AccessToken, "sealed")
+		require.NoError(t, openSlackUserLink(&l))
+		assert.Equal(t, refreshed, l.Token)
+		return nil
+	})
+	repo.EXPECT().AddCheck(gomock.Any(), clRef, "2", prchecklist.GitHubUser{ID: 1, Login: "alice", Token: refreshed}).Return(nil)
+	setupMocks(clRef, github, repo)
+
+	require.NoError(t, app.performSlackActions(ctx, p))
+	require.Equal(t, 3, len(responses))
+	assert.Contains(t, responses[2], "Checked")
+
+	// Expired token not refreshed
+	link.Token = &oauth2.Token{AccessToken: "expired", Expiry: time.Now().Add(-time.Minute)}
+	repo.EXPECT().GetSlackUserLink(gomock.Any(), "T1", "U1").Return(&link, nil)
+	github.EXPECT().RefreshToken(gomock.Any(), link.Token).Return(nil, prchecklist.ErrNotAuthed)
+
+	require.NoError(t, app.performSlackActions(ctx, p))
+	require.Equal(t, 4, len(responses))
+	assert.Contains(t, responses[3], "Link your Slack account to GitHub again")
+	assert.Contains(t, responses[3], "https://prchecklist.test/slack/link?token=")
+}
+
+func TestUsecase_LinkSlackUser(t *testing.T) {
+	slackSigningSecret = "s3cret"
+	defer func() { slackSigningSecret = "" }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	app := New(nil, repo)
+
+	token := &oauth2.Token{AccessToken: "token", RefreshToken: "refresh"}
+
+	err := app.LinkSlackUser(context.Background(), newSlackLinkToken(SlackLinkRequest{TeamID: "T1", UserID: "U1"}, time.Now()), prchecklist.GitHubUser{ID: 1, Login: "alice", Token: token})
+	assert.Error(t, err, "without the Slack token key")
+
+	slackTokenKey = "k3y"
+	defer func() { slackTokenKey = "" }()
+
+	repo.EXPECT().PutSlackUserLink(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, link prchecklist.SlackUserLink) error {
+		assert.Equal(t, "T1", link.TeamID)
+		assert.Equal(t, "U1", link.UserID)
+		assert.Equal(t, 1, link.GitHubUserID)
+		assert.True(t, strings.HasPrefix(link.Token.AccessToken, sealedTokenPrefix), link.Token.AccessToken)
+		assert.True(t, strings.HasPrefix(link.Token.RefreshToken, sealedTokenPrefix), link.Token.RefreshToken)
+		return nil
+	})
+
+	err = app.LinkSlackUser(context.Background(), newSlackLinkToken(SlackLinkRequest{TeamID: "T1", UserID: "U1"}, time.Now()), prchecklist.GitHubUser{ID: 1, Login: "alice", Token: token})
+	require.NoError(t, err)
+
+	err = app.LinkSlackUser(context.Background(), "invalid", prchecklist.GitHubUser{ID: 1, Login: "alice", Token: token})
+	assert.Error(t, err)
+}
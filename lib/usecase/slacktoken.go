@@ -0,0 +1,198 @@
+package usecase
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// sealedTokenPrefix marks the tokens in SlackUserLinks encrypted by sealToken.
+// Tokens without it are the ones saved in plaintext before encryption was introduced.
+const sealedTokenPrefix = "sealed:"
+
+// refreshedTokenTTL is how long refreshed tokens are remembered to be reused by concurrent refreshes.
+const refreshedTokenTTL = time.Minute
+
+var slackTokenKey = os.Getenv("PRCHECKLIST_SLACK_TOKEN_KEY")
+
+func init() {
+	flag.StringVar(&slackTokenKey, "slack-token-key", slackTokenKey, "secret to encrypt the GitHub tokens of the Slack users linked, required to link them (PRCHECKLIST_SLACK_TOKEN_KEY)")
+}
+
+func slackTokenCipher() (cipher.AEAD, error) {
+	if slackTokenKey == "" {
+		return nil, errors.New("Slack token key is not set")
+	}
+
+	key := sha256.Sum256([]byte(slackTokenKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSlackUserLink returns link with its access and refresh tokens encrypted by the Slack token key.
+func sealSlackUserLink(link prchecklist.SlackUserLink) (prchecklist.SlackUserLink, error) {
+	if link.Token == nil {
+		return link, nil
+	}
+
+	aead, err := slackTokenCipher()
+	if err != nil {
+		return link, err
+	}
+
+	seal := func(s string) (string, error) {
+		if s == "" {
+			return "", nil
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		// Bind the ciphertext to the link so that it cannot be copied to another
+		sealed := aead.Seal(nonce, nonce, []byte(s), slackUserLinkAdditionalData(link))
+		return sealedTokenPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+	}
+
+	token := *link.Token
+	if token.AccessToken, err = seal(token.AccessToken); err != nil {
+		return link, err
+	}
+	if token.RefreshToken, err = seal(token.RefreshToken); err != nil {
+		return link, err
+	}
+	link.Token = &token
+
+	return link, nil
+}
+
+// openSlackUserLink decrypts the tokens of link sealed by sealSlackUserLink in place.
+func openSlackUserLink(link *prchecklist.SlackUserLink) error {
+	if link == nil || link.Token == nil {
+		return nil
+	}
+
+	open := func(s string) (string, error) {
+		if !strings.HasPrefix(s, sealedTokenPrefix) {
+			return s, nil
+		}
+
+		aead, err := slackTokenCipher()
+		if err != nil {
+			return "", err
+		}
+
+		sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, sealedTokenPrefix))
+		if err != nil {
+			return "", err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return "", errors.New("sealed token too short")
+		}
+
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], slackUserLinkAdditionalData(*link))
+		return string(plain), err
+	}
+
+	token := *link.Token
+	var err error
+	if token.AccessToken, err = open(token.AccessToken); err != nil {
+		return errors.Wrapf(err, "decrypting the token of Slack user %s", link.UserID)
+	}
+	if token.RefreshToken, err = open(token.RefreshToken); err != nil {
+		return errors.Wrapf(err, "decrypting the token of Slack user %s", link.UserID)
+	}
+	link.Token = &token
+
+	return nil
+}
+
+func slackUserLinkAdditionalData(link prchecklist.SlackUserLink) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%s:%d", link.TeamID, link.UserID, link.GitHubHost, link.GitHubUserID))
+}
+
+func (u Usecase) getSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error) {
+	link, err := u.coreRepo.GetSlackUserLink(ctx, teamID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetSlackUserLink")
+	}
+	return link, openSlackUserLink(link)
+}
+
+func (u Usecase) getSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error) {
+	links, err := u.coreRepo.GetSlackUserLinksOf(ctx, host, githubUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetSlackUserLinksOf")
+	}
+	for i := range links {
+		if err := openSlackUserLink(&links[i]); err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+func (u Usecase) putSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error {
+	link, err := sealSlackUserLink(link)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(u.coreRepo.PutSlackUserLink(ctx, link), "PutSlackUserLink")
+}
+
+// refreshedTokens remembers the tokens refreshed recently by the refresh tokens used,
+// as refresh tokens of GitHub Apps can be used only once.
+type refreshedTokens struct {
+	mu     sync.Mutex
+	tokens map[string]refreshedToken
+}
+
+type refreshedToken struct {
+	token *oauth2.Token
+	at    time.Time
+}
+
+func newRefreshedTokens() *refreshedTokens {
+	return &refreshedTokens{
+		tokens: map[string]refreshedToken{},
+	}
+}
+
+func (r *refreshedTokens) get(refreshToken string, now time.Time) *oauth2.Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.tokens[refreshToken]
+	if !ok || now.Sub(t.at) > refreshedTokenTTL {
+		return nil
+	}
+	return t.token
+}
+
+func (r *refreshedTokens) put(refreshToken string, token *oauth2.Token, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, t := range r.tokens {
+		if now.Sub(t.at) > refreshedTokenTTL {
+			delete(r.tokens, k)
+		}
+	}
+	r.tokens[refreshToken] = refreshedToken{token: token, at: now}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+func TestSealSlackUserLink(t *testing.T) {
+	slackTokenKey = "k3y"
+	defer func() { slackTokenKey = "" }()
+
+	token := &oauth2.Token{AccessToken: "token", RefreshToken: "refresh", Expiry: time.Now()}
+	link := prchecklist.SlackUserLink{TeamID: "T1", UserID: "U1", GitHubUserID: 1, Token: token}
+
+	sealed, err := sealSlackUserLink(link)
+	require.NoError(t, err)
+	assert.NotContains(t, sealed.Token.AccessToken, "token")
+	assert.NotContains(t, sealed.Token.RefreshToken, "refresh")
+	assert.Equal(t, "token", link.Token.AccessToken, "not modified")
+
+	opened := sealed
+	require.NoError(t, openSlackUserLink(&opened))
+	assert.Equal(t, token, opened.Token)
+
+	// Bound to the link
+	copied := sealed
+	copied.UserID = "U2"
+	assert.Error(t, openSlackUserLink(&copied))
+
+	// Plaintext ones saved before
+	plain := link
+	require.NoError(t, openSlackUserLink(&plain))
+	assert.Equal(t, token, plain.Token)
+
+	slackTokenKey = "another"
+	assert.Error(t, openSlackUserLink(&sealed))
+}
+
+func TestUsecase_RefreshUserToken_concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repo)
+
+	user := prchecklist.GitHubUser{ID: 1, Login: "alice", Token: &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh"}}
+	refreshed := &oauth2.Token{AccessToken: "refreshed", RefreshToken: "refresh2"}
+
+	// The refresh token is used only once
+	github.EXPECT().RefreshToken(gomock.Any(), user.Token).Return(refreshed, nil)
+	repo.EXPECT().GetSlackUserLinksOf(gomock.Any(), "", 1).Return(nil, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := app.RefreshUserToken(context.Background(), user)
+			assert.NoError(t, err)
+			assert.Equal(t, refreshed, u.Token)
+		}()
+	}
+	wg.Wait()
+}
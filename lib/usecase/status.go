@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// maxPublishedStatuses bounds the number of statuses remembered by publishedStatuses.
+const maxPublishedStatuses = 10000
+
+// publishedStatuses remembers the statuses published by this process,
+// not to publish the same ones on every view.
+type publishedStatuses struct {
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func newPublishedStatuses() *publishedStatuses {
+	return &publishedStatuses{
+		statuses: map[string]string{},
+	}
+}
+
+func (p *publishedStatuses) get(key string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.statuses[key]
+}
+
+func (p *publishedStatuses) set(key, status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.statuses) >= maxPublishedStatuses {
+		p.statuses = map[string]string{}
+	}
+	p.statuses[key] = status
+}
+
+func checklistStatusContext(stage string) string {
+	return fmt.Sprintf("prchecklist/%s/completed", stage)
+}
+
+// SyncChecklistStatus publishes the progress of checklist on the head commit of the release pull request,
+// as a commit status or a check run according to prchecklist.yml.
+// The status is pending until the checklist is completed, and reverted to pending if a check is removed.
+// It does nothing if the same status has already been published by this process.
+// The status is written as prchecklist itself if possible, as the visitor may not be able to write it.
+func (u Usecase) SyncChecklistStatus(ctx context.Context, checklist *prchecklist.Checklist) error {
+	config := checklist.Config
+	if config == nil || config.Status == prchecklist.StatusNone || len(checklist.Commits) == 0 {
+		return nil
+	}
+
+	headSHA := checklist.Commits[len(checklist.Commits)-1].Oid
+	checked, total := checklist.Progress()
+	description := fmt.Sprintf("%d/%d checked", checked, total)
+	completed := checklist.Completed()
+
+	key := fmt.Sprintf("%s/%s@%s::%s", checklist.Owner, checklist.Repo, headSHA, checklist.Stage)
+	status := fmt.Sprintf("%s %v %s", config.Status, completed, description)
+	if u.statuses != nil && u.statuses.get(key) == status {
+		return nil
+	}
+
+	targetURL := prchecklist.BuildURL(ctx, checklist.Path()).String()
+	ctx = u.serviceContext(ctx, checklist.Owner, checklist.Repo)
+
+	switch config.Status {
+	case prchecklist.StatusCheckRun:
+		run := prchecklist.CheckRun{
+			Name:       checklistStatusContext(checklist.Stage),
+			HeadSHA:    headSHA,
+			DetailsURL: targetURL,
+			Title:      description,
+			Summary:    fmt.Sprintf("[%s](%s): %s", checklist, targetURL, description),
+			Text:       checkRunItemTable(checklist),
+		}
+		if completed {
+			run.Conclusion = "success"
+		}
+		if err := u.github.CreateCheckRun(ctx, checklist.Owner, checklist.Repo, run); err != nil {
+			return errors.Wrap(err, "github.CreateCheckRun")
+		}
+
+	default:
+		state := "pending"
+		if completed {
+			state = "success"
+		}
+		if err := u.github.SetRepositoryStatusAs(ctx, checklist.Owner, checklist.Repo, headSHA, checklistStatusContext(checklist.Stage), state, description, targetURL); err != nil {
+			return errors.Wrap(err, "github.SetRepositoryStatusAs")
+		}
+	}
+
+	if u.statuses != nil {
+		u.statuses.set(key, status)
+	}
+
+	return nil
+}
+
+// serviceContext returns ctx with the HTTP client to access the repository as prchecklist itself,
+// or ctx as is if prchecklist cannot access GitHub without visitors.
+func (u Usecase) serviceContext(ctx context.Context, owner, repo string) context.Context {
+	client, err := u.github.ServiceHTTPClient(ctx, owner, repo)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, client)
+}
+
+// checkRunItemTable renders the items of checklist as a Markdown table.
+func checkRunItemTable(checklist *prchecklist.Checklist) string {
+	var b strings.Builder
+	b.WriteString("| | Pull request | Author | Checked by |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, item := range checklist.Items {
+		mark := ":white_large_square:"
+		logins := make([]string, len(item.CheckedBy))
+		for i, user := range item.CheckedBy {
+			logins[i] = user.Login
+		}
+		if len(logins) > 0 {
+			mark = ":ballot_box_with_check:"
+		}
+		title := strings.Replace(item.Title, "|", "\\|", -1)
+		fmt.Fprintf(&b, "| %s | #%d %s | %s | %s |\n", mark, item.Number, title, item.User.Login, strings.Join(logins, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+func TestUsecase_SyncChecklistStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repository_mock.NewMockCoreRepository(ctrl))
+	ctx := notificationTestContext()
+
+	cl := makeNotificationTestChecklist()
+	cl.Commits = []prchecklist.Commit{{Oid: "deadbeef"}}
+	cl.Config = &prchecklist.ChecklistConfig{Status: prchecklist.StatusCommitStatus}
+
+	targetURL := "https://prchecklist.test/test/test/pull/1/qa"
+
+	serviceClient := &http.Client{}
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(serviceClient, nil).AnyTimes()
+
+	// Written as prchecklist rather than the visitor
+	github.EXPECT().SetRepositoryStatusAs(contextClientMatcher{serviceClient}, "test", "test", "deadbeef", "prchecklist/qa/completed", "pending", "1/3 checked", targetURL).Return(nil)
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	// Not published again when viewed
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	for _, item := range cl.Items {
+		item.CheckedBy = []prchecklist.GitHubUser{{Login: "alice"}}
+	}
+	github.EXPECT().SetRepositoryStatusAs(gomock.Any(), "test", "test", "deadbeef", "prchecklist/qa/completed", "success", "3/3 checked", targetURL).Return(nil)
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	cl.Items[0].CheckedBy = []prchecklist.GitHubUser{}
+	github.EXPECT().SetRepositoryStatusAs(gomock.Any(), "test", "test", "deadbeef", "prchecklist/qa/completed", "pending", "2/3 checked", targetURL).Return(nil)
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	cl.Config.Status = prchecklist.StatusNone
+	cl.Items[0].CheckedBy = []prchecklist.GitHubUser{{Login: "alice"}}
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+}
+
+func TestUsecase_SyncChecklistStatus_checkRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, repository_mock.NewMockCoreRepository(ctrl))
+	ctx := notificationTestContext()
+
+	cl := makeNotificationTestChecklist()
+	cl.Commits = []prchecklist.Commit{{Oid: "deadbeef"}}
+	cl.Config = &prchecklist.ChecklistConfig{Status: prchecklist.StatusCheckRun}
+	for _, item := range cl.Items {
+		item.CheckedBy = []prchecklist.GitHubUser{{Login: "alice"}}
+	}
+
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(&http.Client{}, nil)
+
+	var run prchecklist.CheckRun
+	github.EXPECT().CreateCheckRun(gomock.Any(), "test", "test", gomock.Any()).Do(func(_ interface{}, _, _ string, r prchecklist.CheckRun) {
+		run = r
+	}).Return(nil)
+	require.NoError(t, app.SyncChecklistStatus(ctx, cl))
+
+	assert.Equal(t, "prchecklist/qa/completed", run.Name)
+	assert.Equal(t, "deadbeef", run.HeadSHA)
+	assert.Equal(t, "success", run.Conclusion)
+	assert.Equal(t, "3/3 checked", run.Title)
+	assert.Contains(t, run.Text, "| :ballot_box_with_check: | #2 Feature A | foo | alice |\n")
+}
+
+func TestUsecase_loadConfig_status(t *testing.T) {
+	config, err := Usecase{}.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`stages: [qa]`))
+	require.NoError(t, err)
+	assert.Equal(t, prchecklist.StatusCommitStatus, config.Status)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := NewMockGitHubGateway(ctrl)
+	app := New(github, nil)
+
+	github.EXPECT().CanCreateCheckRun(gomock.Any()).Return(true)
+	config, err = app.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`status: check_run`))
+	require.NoError(t, err)
+	assert.Equal(t, prchecklist.StatusCheckRun, config.Status)
+	assert.Empty(t, config.Warnings)
+
+	// Downgraded without a GitHub App
+	github.EXPECT().CanCreateCheckRun(gomock.Any()).Return(false)
+	config, err = app.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`status: check_run`))
+	require.NoError(t, err)
+	assert.Equal(t, prchecklist.StatusCommitStatus, config.Status)
+	assert.Equal(t, 1, len(config.Warnings))
+
+	_, err = Usecase{}.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`status: foo`))
+	assert.Error(t, err)
+}
+
+// contextClientMatcher matches contexts with the HTTP client.
+type contextClientMatcher struct {
+	client *http.Client
+}
+
+func (m contextClientMatcher) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && ctx.Value(prchecklist.ContextKeyHTTPClient) == m.client
+}
+
+func (m contextClientMatcher) String() string {
+	return "has the HTTP client"
+}
@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+// summaryCommentMarker is put at the top of summary comments to tell them from others.
+const summaryCommentMarker = "<!-- prchecklist:summary -->"
+
+// summaryCommentEscaper breaks "@" mentions and "#" references in texts written by users,
+// so that the summary comment does not notify users nor link to other issues.
+var summaryCommentEscaper = strings.NewReplacer("@", "@\u200b", "#", "#\u200b")
+
+// goSyncSummaryComment runs syncSummaryComment in background, so that checking items
+// does not wait for GitHub.
+func (u Usecase) goSyncSummaryComment(ctx context.Context, checklist *prchecklist.Checklist) {
+	if checklist.Config == nil || !checklist.Config.SummaryComment {
+		return
+	}
+
+	ctx = prchecklist.NewContextWithValuesOf(ctx)
+	go func() {
+		if err := u.syncSummaryComment(ctx, checklist); err != nil {
+			log.Printf("syncSummaryComment(%s): %s", checklist, err)
+		}
+	}()
+}
+
+// syncSummaryComment posts or updates the comment on the release pull request of checklist
+// which summarizes the progress of all the stages, if enabled by prchecklist.yml.
+func (u Usecase) syncSummaryComment(ctx context.Context, checklist *prchecklist.Checklist) error {
+	if checklist.Config == nil || !checklist.Config.SummaryComment {
+		return nil
+	}
+
+	stages := checklist.Config.Stages
+	if len(stages) == 0 {
+		stages = []string{checklist.Stage}
+	}
+
+	ref := checklist.Ref()
+	ref.Stage = ""
+
+	// Serialize per pull request to avoid posting duplicate comments,
+	// and to let the last one reflect the latest checks
+	if u.summaryMu != nil {
+		unlock := u.summaryMu.lock(ref.String())
+		defer unlock()
+	}
+
+	// Edit the comment as prchecklist itself, as visitors may not be allowed to edit others' comments
+	ctx = u.serviceContext(ctx, checklist.Owner, checklist.Repo)
+
+	checklists := make([]*prchecklist.Checklist, len(stages))
+	for i, stage := range stages {
+		cl := &prchecklist.Checklist{
+			PullRequest: checklist.PullRequest,
+			Host:        checklist.Host,
+			Stage:       stage,
+			Items:       make([]*prchecklist.ChecklistItem, len(checklist.Items)),
+			Config:      checklist.Config,
+		}
+		for j, item := range checklist.Items {
+			cl.Items[j] = &prchecklist.ChecklistItem{
+				PullRequest: item.PullRequest,
+				CheckedBy:   []prchecklist.GitHubUser{},
+			}
+		}
+
+		clRef := checklist.Ref()
+		clRef.Stage = stage
+		if err := u.fillCheckedBy(ctx, clRef, cl.Items); err != nil {
+			return err
+		}
+
+		checklists[i] = cl
+	}
+
+	body := summaryCommentBody(ctx, checklists)
+
+	ref = checklist.Ref()
+	commentID, err := u.coreRepo.GetSummaryCommentID(ctx, ref)
+	if err != nil {
+		return errors.Wrap(err, "GetSummaryCommentID")
+	}
+
+	newCommentID, err := u.github.PutIssueComment(ctx, checklist.Owner, checklist.Repo, checklist.Number, commentID, body)
+	if err != nil {
+		return errors.Wrap(err, "github.PutIssueComment")
+	}
+
+	if newCommentID != commentID {
+		return errors.Wrap(u.coreRepo.SetSummaryCommentID(ctx, ref, newCommentID), "SetSummaryCommentID")
+	}
+
+	return nil
+}
+
+// summaryCommentBody renders the summary comment in Markdown.
+// Logins are not prefixed with "@" and titles are escaped so that editing the comment does not notify users.
+func summaryCommentBody(ctx context.Context, checklists []*prchecklist.Checklist) string {
+	var b strings.Builder
+
+	b.WriteString(summaryCommentMarker + "\n")
+	b.WriteString("### prchecklist\n")
+
+	for _, cl := range checklists {
+		checked, total := cl.Progress()
+		status := ""
+		if cl.Completed() {
+			status = " :white_check_mark:"
+		}
+		fmt.Fprintf(&b, "\n**[%s](%s)**: %d/%d checked%s\n\n", cl.Stage, prchecklist.BuildURL(ctx, cl.Path()), checked, total, status)
+
+		for _, item := range cl.Items {
+			mark := ":white_large_square:"
+			if len(item.CheckedBy) > 0 {
+				mark = ":ballot_box_with_check:"
+			}
+			fmt.Fprintf(&b, "- %s #%d %s (%s)", mark, item.Number, summaryCommentEscaper.Replace(item.Title), item.User.Login)
+			if len(item.CheckedBy) > 0 {
+				logins := make([]string, len(item.CheckedBy))
+				for i, user := range item.CheckedBy {
+					logins[i] = user.Login
+				}
+				fmt.Fprintf(&b, " checked by %s", strings.Join(logins, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
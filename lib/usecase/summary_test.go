@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/repository_mock"
+)
+
+func TestSummaryCommentBody(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+
+	body := summaryCommentBody(ctx, []*prchecklist.Checklist{cl})
+
+	assert.True(t, strings.HasPrefix(body, summaryCommentMarker+"\n"), body)
+	assert.Contains(t, body, "**[qa](https://prchecklist.test/test/test/pull/1/qa)**: 1/3 checked\n")
+	assert.Contains(t, body, "- :ballot_box_with_check: #2 Feature A (foo) checked by alice\n")
+	assert.Contains(t, body, "- :white_large_square: #3 Feature B (foo)\n")
+	assert.NotContains(t, body, "@")
+}
+
+func TestSummaryCommentBody_escape(t *testing.T) {
+	ctx := notificationTestContext()
+	cl := makeNotificationTestChecklist()
+	cl.Items[1].Title = "Fix #123 reported by @alice"
+
+	body := summaryCommentBody(ctx, []*prchecklist.Checklist{cl})
+
+	assert.Contains(t, body, "#3 Fix #\u200b123 reported by @\u200balice (foo)\n")
+	assert.NotContains(t, body, "@alice")
+	assert.NotContains(t, body, "#123")
+}
+
+func TestUsecase_syncSummaryComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+
+	cl := makeNotificationTestChecklist()
+	cl.Config = &prchecklist.ChecklistConfig{
+		Stages:         []string{"qa", "production"},
+		SummaryComment: true,
+	}
+
+	qaRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "qa"}
+	productionRef := prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 1, Stage: "production"}
+
+	repo.EXPECT().GetChecks(gomock.Any(), qaRef).
+		Return(prchecklist.Checks{"2": {1}, "3": {1}}, nil)
+	repo.EXPECT().GetUsers(gomock.Any(), "", []int{1}).
+		Return(map[int]prchecklist.GitHubUser{1: {ID: 1, Login: "alice"}}, nil)
+	repo.EXPECT().GetChecks(gomock.Any(), productionRef).
+		Return(prchecklist.Checks{}, nil)
+	repo.EXPECT().GetUsers(gomock.Any(), "", gomock.Len(0)).
+		Return(map[int]prchecklist.GitHubUser{}, nil)
+
+	repo.EXPECT().GetSummaryCommentID(gomock.Any(), qaRef).Return(int64(0), nil)
+
+	serviceClient := &http.Client{}
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(serviceClient, nil)
+
+	var body string
+	github.EXPECT().PutIssueComment(contextClientMatcher{serviceClient}, "test", "test", 1, int64(0), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, _ int, _ int64, b string) (int64, error) {
+			body = b
+			return 42, nil
+		})
+
+	repo.EXPECT().SetSummaryCommentID(gomock.Any(), qaRef, int64(42)).Return(nil)
+
+	app := New(github, repo)
+	err := app.syncSummaryComment(notificationTestContext(), cl)
+	require.NoError(t, err)
+
+	assert.Contains(t, body, "**[qa](https://prchecklist.test/test/test/pull/1/qa)**: 2/3 checked\n")
+	assert.Contains(t, body, "**[production](https://prchecklist.test/test/test/pull/1/production)**: 0/3 checked\n")
+}
+
+func TestUsecase_syncSummaryComment_host(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository_mock.NewMockCoreRepository(ctrl)
+	github := NewMockGitHubGateway(ctrl)
+
+	cl := makeNotificationTestChecklist()
+	cl.Host = "ghe.example.com"
+	cl.Config = &prchecklist.ChecklistConfig{
+		Stages:         []string{"qa"},
+		SummaryComment: true,
+	}
+
+	qaRef := prchecklist.ChecklistRef{Host: "ghe.example.com", Owner: "test", Repo: "test", Number: 1, Stage: "qa"}
+
+	repo.EXPECT().GetChecks(gomock.Any(), qaRef).Return(prchecklist.Checks{}, nil)
+	repo.EXPECT().GetUsers(gomock.Any(), "ghe.example.com", gomock.Len(0)).Return(map[int]prchecklist.GitHubUser{}, nil)
+	repo.EXPECT().GetSummaryCommentID(gomock.Any(), qaRef).Return(int64(42), nil)
+
+	github.EXPECT().ServiceHTTPClient(gomock.Any(), "test", "test").Return(nil, errors.New("no service client"))
+
+	var body string
+	github.EXPECT().PutIssueComment(gomock.Any(), "test", "test", 1, int64(42), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, _ int, _ int64, b string) (int64, error) {
+			body = b
+			return 42, nil
+		})
+
+	app := New(github, repo)
+	require.NoError(t, app.syncSummaryComment(notificationTestContext(), cl))
+
+	assert.Contains(t, body, "**[qa](https://prchecklist.test/ghe.example.com/test/test/pull/1/qa)**: 0/3 checked\n")
+}
+
+func TestUsecase_syncSummaryComment_disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	app := New(NewMockGitHubGateway(ctrl), repository_mock.NewMockCoreRepository(ctrl))
+
+	cl := makeNotificationTestChecklist()
+	assert.NoError(t, app.syncSummaryComment(context.Background(), cl))
+
+	cl.Config = &prchecklist.ChecklistConfig{}
+	assert.NoError(t, app.syncSummaryComment(context.Background(), cl))
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/tools/container/intsets"
 	"gopkg.in/yaml.v2"
@@ -21,8 +25,16 @@ import (
 type GitHubGateway interface {
 	GetBlob(ctx context.Context, ref prchecklist.ChecklistRef, sha string) ([]byte, error)
 	GetPullRequest(ctx context.Context, clRef prchecklist.ChecklistRef, isMain bool) (*prchecklist.PullRequest, context.Context, error)
-	GetRecentPullRequests(ctx context.Context) (map[string][]*prchecklist.PullRequest, error)
-	SetRepositoryStatusAs(ctx context.Context, owner, repo, ref, contextName, state, targetURL string) error
+	GetFeaturePullRequests(ctx context.Context, owner, repo string, numbers []int) (map[int]*prchecklist.PullRequest, error)
+	GetRecentPullRequests(ctx context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error)
+	SetRepositoryStatusAs(ctx context.Context, owner, repo, ref, contextName, state, description, targetURL string) error
+	CreateCheckRun(ctx context.Context, owner, repo string, run prchecklist.CheckRun) error
+	CanCreateCheckRun(ctx context.Context) bool
+	ServiceHTTPClient(ctx context.Context, owner, repo string) (*http.Client, error)
+	InvalidatePullRequest(ctx context.Context, owner, repo string, number int)
+	GetPullRequestNumbersByHead(ctx context.Context, owner, repo, branch string) ([]int, error)
+	PutIssueComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (int64, error)
+	RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
 }
 
 // CoreRepository is a repository for prchecklist's core data,
@@ -37,24 +49,79 @@ type CoreRepository interface {
 
 	// AddUser registers the user's data, which can retrieved by GetUsers.
 	AddUser(ctx context.Context, user prchecklist.GitHubUser) error
-	// GetUsers retrieves the users' data registered by AddUser.
-	GetUsers(ctx context.Context, userIDs []int) (map[int]prchecklist.GitHubUser, error)
+	// GetUsers retrieves the users' data of the GitHub host registered by AddUser.
+	GetUsers(ctx context.Context, host string, userIDs []int) (map[int]prchecklist.GitHubUser, error)
+
+	// PutOutboxNotification adds or updates the notification n in the outbox.
+	PutOutboxNotification(ctx context.Context, n prchecklist.OutboxNotification) error
+	// GetOutboxNotification retrieves a notification in the outbox by its ID. Returns nil if not found.
+	GetOutboxNotification(ctx context.Context, id string) (*prchecklist.OutboxNotification, error)
+	// GetOutboxNotifications retrieves the notifications in the outbox with the state, in the order of their IDs.
+	GetOutboxNotifications(ctx context.Context, state prchecklist.OutboxState) ([]*prchecklist.OutboxNotification, error)
+	// ClaimOutboxNotifications atomically leases the pending notifications due at now for the duration,
+	// so that they are not claimed by others meanwhile, and returns them.
+	ClaimOutboxNotifications(ctx context.Context, now time.Time, lease time.Duration) ([]*prchecklist.OutboxNotification, error)
+	// UpdateOutboxNotification atomically updates the notification in the outbox specified by id with update.
+	// Returns nil if not found, or the error update returned without updating.
+	UpdateOutboxNotification(ctx context.Context, id string, update func(n *prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error)
+	// RemoveOutboxNotification removes a notification from the outbox.
+	RemoveOutboxNotification(ctx context.Context, id string) error
+
+	// GetSummaryCommentID returns the ID of the summary comment on the pull request pointed by ref,
+	// ignoring its Stage. Returns 0 if not posted yet.
+	GetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef) (int64, error)
+	// SetSummaryCommentID stores the ID of the summary comment on the pull request pointed by ref.
+	SetSummaryCommentID(ctx context.Context, ref prchecklist.ChecklistRef, commentID int64) error
+
+	// GetKnownItemNumbers returns the numbers of the items of the checklist pointed by clRef
+	// recorded by SetKnownItemNumbers. Returns nil if not recorded yet.
+	GetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef) ([]int, error)
+	// SetKnownItemNumbers records the numbers of the items of the checklist pointed by clRef.
+	SetKnownItemNumbers(ctx context.Context, clRef prchecklist.ChecklistRef, numbers []int) error
+
+	// GetSlackUserLink retrieves the link of the Slack user. Returns nil if not linked.
+	GetSlackUserLink(ctx context.Context, teamID, userID string) (*prchecklist.SlackUserLink, error)
+	// PutSlackUserLink adds or updates the link of a Slack user.
+	PutSlackUserLink(ctx context.Context, link prchecklist.SlackUserLink) error
+	// GetSlackUserLinksOf retrieves the links of Slack users to the GitHub user.
+	GetSlackUserLinksOf(ctx context.Context, host string, githubUserID int) ([]prchecklist.SlackUserLink, error)
 }
 
 // Usecase stands for the use cases of this application by its methods.
 type Usecase struct {
-	coreRepo CoreRepository
-	github   GitHubGateway
+	coreRepo        CoreRepository
+	github          GitHubGateway
+	mentions        map[string]string
+	secrets         map[string]Secret
+	outboxWake      chan struct{}
+	summaryMu       *keyedMutex
+	statuses        *publishedStatuses
+	itemsMu         *keyedMutex
+	tokensMu        *keyedMutex
+	refreshedTokens *refreshedTokens
 }
 
 // New creates a new Usecase.
 func New(github GitHubGateway, coreRepo CoreRepository) *Usecase {
 	return &Usecase{
-		coreRepo: coreRepo,
-		github:   github,
+		coreRepo:        coreRepo,
+		github:          github,
+		outboxWake:      make(chan struct{}, 1),
+		summaryMu:       newKeyedMutex(),
+		statuses:        newPublishedStatuses(),
+		itemsMu:         newKeyedMutex(),
+		tokensMu:        newKeyedMutex(),
+		refreshedTokens: newRefreshedTokens(),
 	}
 }
 
+// SetMentions sets the server-wide mapping from GitHub logins to chat user IDs
+// used to mention users in notifications.
+// Mappings in prchecklist.yml take precedence over it.
+func (u *Usecase) SetMentions(mentions map[string]string) {
+	u.mentions = mentions
+}
+
 // GetChecklist retrieves a Checklist pointed by clRef.
 // It makes some call to GitHub to create a complete view of one checklist.
 // Only this method can create prchecklist.Checklist.
@@ -68,6 +135,7 @@ func (u Usecase) GetChecklist(ctx context.Context, clRef prchecklist.ChecklistRe
 
 	checklist := &prchecklist.Checklist{
 		PullRequest: pr,
+		Host:        clRef.Host,
 		Stage:       clRef.Stage,
 		Items:       make([]*prchecklist.ChecklistItem, len(refs)),
 		Config:      nil,
@@ -75,17 +143,35 @@ func (u Usecase) GetChecklist(ctx context.Context, clRef prchecklist.ChecklistRe
 
 	{
 		g, ctx := errgroup.WithContext(ctx)
-		for i, ref := range refs {
-			i, ref := i, ref
+		if len(refs) > 0 {
 			g.Go(func() error {
-				featurePullReq, _, err := u.github.GetPullRequest(ctx, ref, false)
+				numbers := make([]int, len(refs))
+				for i, ref := range refs {
+					numbers[i] = ref.Number
+				}
+
+				featurePullReqs, err := u.github.GetFeaturePullRequests(ctx, pr.Owner, pr.Repo, numbers)
 				if err != nil {
-					return err
+					return errors.Wrap(err, "github.GetFeaturePullRequests")
 				}
 
-				checklist.Items[i] = &prchecklist.ChecklistItem{
-					PullRequest: featurePullReq,
-					CheckedBy:   []prchecklist.GitHubUser{}, // filled up later
+				for i, ref := range refs {
+					featurePullReq := featurePullReqs[ref.Number]
+					if featurePullReq == nil {
+						// Such as deleted or inaccessible ones; keep the item to be checked
+						log.Printf("could not retrieve %s", ref)
+						featurePullReq = &prchecklist.PullRequest{
+							Owner:   pr.Owner,
+							Repo:    pr.Repo,
+							Number:  ref.Number,
+							Commits: []prchecklist.Commit{},
+						}
+					}
+
+					checklist.Items[i] = &prchecklist.ChecklistItem{
+						PullRequest: featurePullReq,
+						CheckedBy:   []prchecklist.GitHubUser{}, // filled up later
+					}
 				}
 				return nil
 			})
@@ -98,8 +184,14 @@ func (u Usecase) GetChecklist(ctx context.Context, clRef prchecklist.ChecklistRe
 					return errors.Wrap(err, "github.GetBlob")
 				}
 
-				checklist.Config, err = u.loadConfig(buf)
-				return err
+				checklist.Config, err = u.loadConfig(ctx, clRef, buf)
+				if err != nil {
+					return err
+				}
+				for _, w := range checklist.Config.Warnings {
+					log.Printf("%s: prchecklist.yml: %s", clRef, w)
+				}
+				return nil
 			})
 		}
 
@@ -111,9 +203,18 @@ func (u Usecase) GetChecklist(ctx context.Context, clRef prchecklist.ChecklistRe
 
 	// may move to before fetching feature pullreqs
 	// for early return
+	if err := u.fillCheckedBy(ctx, clRef, checklist.Items); err != nil {
+		return nil, err
+	}
+
+	return checklist, nil
+}
+
+// fillCheckedBy sets CheckedBy of items by the checks for the checklist pointed by clRef.
+func (u Usecase) fillCheckedBy(ctx context.Context, clRef prchecklist.ChecklistRef, items []*prchecklist.ChecklistItem) error {
 	checks, err := u.coreRepo.GetChecks(ctx, clRef)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	log.Printf("%s: checks: %+v", clRef, checks)
@@ -125,21 +226,21 @@ func (u Usecase) GetChecklist(ctx context.Context, clRef prchecklist.ChecklistRe
 		}
 	}
 
-	users, err := u.coreRepo.GetUsers(ctx, s.AppendTo(nil))
+	users, err := u.coreRepo.GetUsers(ctx, clRef.Host, s.AppendTo(nil))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, item := range checklist.Items {
+	for _, item := range items {
 		for _, id := range checks[prchecklist.ChecksKeyFeatureNum(item.Number)] {
 			item.CheckedBy = append(item.CheckedBy, users[id])
 		}
 	}
 
-	return checklist, nil
+	return nil
 }
 
-func (u Usecase) loadConfig(buf []byte) (*prchecklist.ChecklistConfig, error) {
+func (u Usecase) loadConfig(ctx context.Context, clRef prchecklist.ChecklistRef, buf []byte) (*prchecklist.ChecklistConfig, error) {
 	var config prchecklist.ChecklistConfig
 	err := yaml.Unmarshal(buf, &config)
 	if err != nil {
@@ -162,16 +263,90 @@ func (u Usecase) loadConfig(buf []byte) (*prchecklist.ChecklistConfig, error) {
 		config.Notification.Events.OnCompleteChecksOfUser = []string{}
 	}
 
+	if config.Notification.Events.OnItemAdded == nil {
+		config.Notification.Events.OnItemAdded = []string{"default"}
+	}
+
+	if config.Notification.Events.OnItemRemoved == nil {
+		config.Notification.Events.OnItemRemoved = []string{}
+	}
+
+	switch config.Status {
+	case "":
+		config.Status = prchecklist.StatusCommitStatus
+	case prchecklist.StatusCommitStatus, prchecklist.StatusCheckRun, prchecklist.StatusNone:
+	default:
+		return nil, errors.Errorf("unknown status %q", config.Status)
+	}
+	if config.Status == prchecklist.StatusCheckRun && (u.github == nil || !u.github.CanCreateCheckRun(ctx)) {
+		config.Warnings = append(config.Warnings, fmt.Sprintf("status %q requires a GitHub App; %q is used instead", config.Status, prchecklist.StatusCommitStatus))
+		config.Status = prchecklist.StatusCommitStatus
+	}
+
+	// A bad channel is dropped so that it does not break the checklist
+	for name, ch := range config.Notification.Channels {
+		ch, err := u.loadNotificationChannel(clRef, ch)
+		if err != nil {
+			config.Warnings = append(config.Warnings, fmt.Sprintf("notification channel %q dropped: %s", name, err))
+			delete(config.Notification.Channels, name)
+			continue
+		}
+		config.Notification.Channels[name] = ch
+	}
+	sort.Strings(config.Warnings)
+
+	if err := validateNotificationTemplates(config.Notification.Templates); err != nil {
+		return nil, errors.Wrap(err, "notification templates")
+	}
+
 	return &config, nil
 }
 
+// loadNotificationChannel validates ch and resolves its url_from for the checklist pointed by clRef.
+func (u Usecase) loadNotificationChannel(clRef prchecklist.ChecklistRef, ch prchecklist.NotificationChannel) (prchecklist.NotificationChannel, error) {
+	switch ch.Format {
+	case "":
+		ch.Format = prchecklist.NotificationFormatBlocks
+	case prchecklist.NotificationFormatBlocks, prchecklist.NotificationFormatText, prchecklist.NotificationFormatJSON:
+	default:
+		return ch, errors.Errorf("unknown format %q", ch.Format)
+	}
+	if ch.URLFrom != "" {
+		if ch.URL != "" {
+			return ch, errors.New("both url and url_from are specified")
+		}
+		webhookURL, err := u.resolveSecretRef(ch.URLFrom, repositoryPath(clRef))
+		if err != nil {
+			return ch, errors.Wrap(err, "url_from")
+		}
+		ch.URL = webhookURL
+	}
+	if err := validateWebhookURL(ch.URL); err != nil {
+		return ch, err
+	}
+	if ch.SecretFrom != "" {
+		if _, err := u.resolveSecretRef(ch.SecretFrom, repositoryPath(clRef)); err != nil {
+			return ch, errors.Wrap(err, "secret_from")
+		}
+	}
+	if ch.Digest != "" {
+		if _, err := time.ParseDuration(ch.Digest); err != nil {
+			return ch, errors.Wrap(err, "digest")
+		}
+	}
+	if err := validateNotificationTemplates(ch.Templates); err != nil {
+		return ch, err
+	}
+	return ch, nil
+}
+
 // AddUser calls a repo to register the information of a user.
 func (u Usecase) AddUser(ctx context.Context, user prchecklist.GitHubUser) error {
 	return u.coreRepo.AddUser(ctx, user)
 }
 
 // AddCheck adds a check by the user for a checklist item for a feature pull reuquest number featNum, for the checklist pointed by clRef.
-// On checking, it may send notifications according to the configuration on prchecklist.yml.
+// On checking, it may enqueue notifications according to the configuration on prchecklist.yml.
 // NOTE: we may not need user, could receive only token (from ctx) for checking visiblities & gettting user info
 func (u Usecase) AddCheck(ctx context.Context, clRef prchecklist.ChecklistRef, featNum int, user prchecklist.GitHubUser) (*prchecklist.Checklist, error) {
 	err := u.coreRepo.AddCheck(ctx, clRef, prchecklist.ChecksKeyFeatureNum(featNum), user)
@@ -185,24 +360,28 @@ func (u Usecase) AddCheck(ctx context.Context, clRef prchecklist.ChecklistRef, f
 	}
 
 	// TODO: check item existence?
-	go func(ctx context.Context) {
-		// notify in sequence
-		events := []notificationEvent{
-			addCheckEvent{checklist: checklist, item: checklist.Item(featNum), user: user},
-		}
-		if author := checklist.Item(featNum).User; checklist.CompletedChecksOfUser(author) {
-			events = append(events, completeChecksOfUserEvent{checklist: checklist, user: author})
-		}
-		if checklist.Completed() {
-			events = append(events, completeEvent{checklist: checklist})
-		}
-		for _, event := range events {
-			err := u.notifyEvent(ctx, checklist, event)
-			if err != nil {
-				log.Printf("notifyEvent(%v): %s", event, err)
-			}
+	// notify in sequence
+	events := []notificationEvent{
+		addCheckEvent{checklist: checklist, item: checklist.Item(featNum), user: user},
+	}
+	if author := checklist.Item(featNum).User; checklist.CompletedChecksOfUser(author) {
+		events = append(events, completeChecksOfUserEvent{checklist: checklist, user: author})
+	}
+	if checklist.Completed() {
+		events = append(events, completeEvent{checklist: checklist})
+	}
+	for _, event := range events {
+		err := u.notifyEvent(ctx, checklist, event)
+		if err != nil {
+			log.Printf("notifyEvent(%v): %s", event, err)
 		}
-	}(prchecklist.NewContextWithValuesOf(ctx))
+	}
+
+	u.goSyncSummaryComment(ctx, checklist)
+
+	if err := u.SyncChecklistStatus(ctx, checklist); err != nil {
+		log.Printf("SyncChecklistStatus(%s): %s", checklist, err)
+	}
 
 	return checklist, nil
 }
@@ -221,31 +400,44 @@ func (u Usecase) RemoveCheck(ctx context.Context, clRef prchecklist.ChecklistRef
 		return nil, err
 	}
 
-	go func(ctx context.Context, cl *prchecklist.Checklist) {
-		events := []notificationEvent{
-			removeCheckEvent{
-				checklist: cl,
-				item:      cl.Item(featNum),
-				user:      user,
-			},
-		}
-		for _, event := range events {
-			err := u.notifyEvent(ctx, cl, event)
-			if err != nil {
-				log.Printf("notifyEvent(%v): %s", event, err)
-			}
+	events := []notificationEvent{
+		removeCheckEvent{
+			checklist: cl,
+			item:      cl.Item(featNum),
+			user:      user,
+		},
+	}
+	for _, event := range events {
+		err := u.notifyEvent(ctx, cl, event)
+		if err != nil {
+			log.Printf("notifyEvent(%v): %s", event, err)
 		}
-	}(prchecklist.NewContextWithValuesOf(ctx), cl)
+	}
+
+	u.goSyncSummaryComment(ctx, cl)
+
+	if err := u.SyncChecklistStatus(ctx, cl); err != nil {
+		log.Printf("SyncChecklistStatus(%s): %s", cl, err)
+	}
 
 	return cl, nil
 }
 
-var rxMergeCommitMessage = regexp.MustCompile(`\AMerge pull request #(?P<number>\d+) `)
+var (
+	rxMergeCommitMessage = regexp.MustCompile(`\AMerge pull request #(?P<number>\d+) `)
+	// Merge commits of GitLab end with a line like "See merge request group/project!123"
+	rxGitLabMergeCommitMessage = regexp.MustCompile(`(?m)^See merge request \S*!(?P<number>\d+)\s*\z`)
+)
 
 func (u Usecase) mergedPullRequestRefs(pr *prchecklist.PullRequest) []prchecklist.ChecklistRef {
+	rx := rxMergeCommitMessage
+	if pr.Provider == prchecklist.ProviderGitLab {
+		rx = rxGitLabMergeCommitMessage
+	}
+
 	refs := []prchecklist.ChecklistRef{}
 	for _, commit := range pr.Commits {
-		m := rxMergeCommitMessage.FindStringSubmatch(commit.Message)
+		m := rx.FindStringSubmatch(commit.Message)
 		if m == nil {
 			continue
 		}
@@ -260,13 +452,3 @@ func (u Usecase) mergedPullRequestRefs(pr *prchecklist.PullRequest) []prchecklis
 	}
 	return refs
 }
-
-// GetRecentPullRequests list recent pullrequests the user may be interested in.
-// Crafted for the top page.
-func (u Usecase) GetRecentPullRequests(ctx context.Context) (map[string][]*prchecklist.PullRequest, error) {
-	return u.github.GetRecentPullRequests(ctx)
-}
-
-func (u Usecase) setRepositoryCompletedStatusAs(ctx context.Context, owner, repo, ref, state, stage, targetURL string) error {
-	return u.github.SetRepositoryStatusAs(ctx, owner, repo, ref, fmt.Sprintf("prchecklist/%s/completed", stage), state, targetURL)
-}
@@ -36,11 +36,13 @@ func TestUseCase_GetChecklist(t *testing.T) {
 		ConfigBlobID: "DUMMY-CONFIG-BLOB-ID",
 	}, context.Background(), nil)
 
-	github.EXPECT().GetPullRequest(
+	github.EXPECT().GetFeaturePullRequests(
 		gomock.Any(),
-		prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 2},
-		false,
-	).Return(&prchecklist.PullRequest{}, context.Background(), nil)
+		"test", "test",
+		[]int{2},
+	).Return(map[int]*prchecklist.PullRequest{
+		2: {Number: 2},
+	}, nil)
 
 	github.EXPECT().GetBlob(
 		gomock.Any(),
@@ -58,7 +60,7 @@ stages:
 	repo.EXPECT().GetChecks(gomock.Any(), clRef).
 		Return(prchecklist.Checks{}, nil)
 
-	repo.EXPECT().GetUsers(gomock.Any(), gomock.Len(0)).
+	repo.EXPECT().GetUsers(gomock.Any(), "", gomock.Len(0)).
 		Return(map[int]prchecklist.GitHubUser{}, nil)
 
 	app := New(github, repo)
@@ -86,26 +88,19 @@ func setupMocks(clRef prchecklist.ChecklistRef, github *MockGitHubGateway, repo
 		},
 	}, context.Background(), nil)
 
-	github.EXPECT().GetPullRequest(
+	github.EXPECT().GetFeaturePullRequests(
 		gomock.Any(),
-		prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 2},
-		false,
-	).Return(&prchecklist.PullRequest{
-		Number: 2,
-	}, context.Background(), nil)
-
-	github.EXPECT().GetPullRequest(
-		gomock.Any(),
-		prchecklist.ChecklistRef{Owner: "test", Repo: "test", Number: 3},
-		false,
-	).Return(&prchecklist.PullRequest{
-		Number: 3,
-	}, context.Background(), nil)
+		"test", "test",
+		[]int{2, 3},
+	).Return(map[int]*prchecklist.PullRequest{
+		2: {Number: 2},
+		3: {Number: 3},
+	}, nil)
 
 	repo.EXPECT().GetChecks(gomock.Any(), clRef).
 		Return(prchecklist.Checks{}, nil)
 
-	repo.EXPECT().GetUsers(gomock.Any(), gomock.Len(0)).
+	repo.EXPECT().GetUsers(gomock.Any(), "", gomock.Len(0)).
 		Return(map[int]prchecklist.GitHubUser{}, nil)
 }
 
@@ -176,3 +171,24 @@ func TestUsecase_RemoveCheck(t *testing.T) {
 	assert.NoError(t, err)
 	t.Log(cl)
 }
+
+func TestUsecase_mergedPullRequestRefs_gitLab(t *testing.T) {
+	app := Usecase{}
+
+	refs := app.mergedPullRequestRefs(&prchecklist.PullRequest{
+		Owner:    "group/subgroup",
+		Repo:     "project",
+		Provider: prchecklist.ProviderGitLab,
+		Commits: []prchecklist.Commit{
+			{Message: "Merge branch 'feature-1' into 'main'\n\nAdd feature 1\n\nSee merge request group/subgroup/project!12"},
+			{Message: "Fix typo\n\nSee merge request !5 for details"},
+			{Message: "Merge branch 'feature-2' into 'main'\n\nSee merge request group/subgroup/project!13\n"},
+			{Message: "Merge pull request #2 from motemen/feature"},
+		},
+	})
+
+	assert.Equal(t, []prchecklist.ChecklistRef{
+		{Owner: "group/subgroup", Repo: "project", Number: 12},
+		{Owner: "group/subgroup", Repo: "project", Number: 13},
+	}, refs)
+}
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	webhookAllowedHosts        = os.Getenv("PRCHECKLIST_WEBHOOK_ALLOWED_HOSTS")
+	webhookAllowPrivateNetwork = os.Getenv("PRCHECKLIST_WEBHOOK_ALLOW_PRIVATE_NETWORK") != ""
+	webhookProxy               = os.Getenv("PRCHECKLIST_WEBHOOK_PROXY")
+)
+
+// errWebhookRejected is the cause of errors for webhooks not allowed to send notifications to.
+var errWebhookRejected = errors.New("webhook rejected")
+
+// webhookBlockedNetworks are private, loopback, link-local and other special-purpose networks
+// that notifications are not sent to unless webhookAllowPrivateNetwork is set.
+var webhookBlockedNetworks []*net.IPNet
+
+var webhookBlockedNetworksCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+func init() {
+	flag.StringVar(&webhookAllowedHosts, "webhook-allowed-hosts", webhookAllowedHosts, "comma-separated host patterns such as \"hooks.slack.com,*.example.com\" notifications can be sent to; any if empty (PRCHECKLIST_WEBHOOK_ALLOWED_HOSTS)")
+	flag.BoolVar(&webhookAllowPrivateNetwork, "webhook-allow-private-network", webhookAllowPrivateNetwork, "allow sending notifications to private, loopback and link-local addresses (PRCHECKLIST_WEBHOOK_ALLOW_PRIVATE_NETWORK)")
+	flag.StringVar(&webhookProxy, "webhook-proxy", webhookProxy, "`URL` of HTTP proxy to send notifications through (PRCHECKLIST_WEBHOOK_PROXY)")
+
+	for _, cidr := range webhookBlockedNetworksCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		webhookBlockedNetworks = append(webhookBlockedNetworks, ipNet)
+	}
+}
+
+func rejectWebhookf(format string, args ...interface{}) error {
+	return errors.Wrapf(errWebhookRejected, format, args...)
+}
+
+func isWebhookRejected(err error) bool {
+	return errors.Is(err, errWebhookRejected)
+}
+
+// isWebhookHostAllowed reports whether host matches any of the patterns in webhookAllowedHosts.
+func isWebhookHostAllowed(host string) bool {
+	if strings.TrimSpace(webhookAllowedHosts) == "" {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	for _, pattern := range strings.Split(webhookAllowedHosts, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func checkWebhookIP(ip net.IP) error {
+	if webhookAllowPrivateNetwork {
+		return nil
+	}
+
+	for _, ipNet := range webhookBlockedNetworks {
+		if ipNet.Contains(ip) {
+			return rejectWebhookf("address %s is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+// validateWebhookURL checks whether notifications can be sent to rawurl
+// by its scheme and host, without resolving the host name.
+func validateWebhookURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rejectWebhookf("invalid URL: %s", err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return rejectWebhookf("scheme %q is not allowed", u.Scheme)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return rejectWebhookf("no host in URL")
+	}
+
+	if !isWebhookHostAllowed(host) {
+		return rejectWebhookf("host %q is not allowed", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return checkWebhookIP(ip)
+	}
+
+	return nil
+}
+
+// webhookDialControl rejects connections to addresses not allowed,
+// checked after the host names are resolved to prevent DNS rebinding.
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	if webhookProxy != "" {
+		// Connecting to the proxy, which is trusted
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return rejectWebhookf("unexpected address %q", address)
+	}
+
+	return checkWebhookIP(ip)
+}
+
+// checkWebhookResolvedHost checks the addresses of the host of req,
+// used when the connection is made through the proxy.
+func checkWebhookResolvedHost(ctx context.Context, req *http.Request) error {
+	if webhookAllowPrivateNetwork {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, req.URL.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if err := checkWebhookIP(addr.IP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func webhookProxyURL(req *http.Request) (*url.URL, error) {
+	if webhookProxy == "" {
+		return nil, nil
+	}
+	return url.Parse(webhookProxy)
+}
+
+// newWebhookHTTPClient creates an *http.Client to send notifications,
+// which follows the policy configured by the flags.
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   webhookDialControl,
+	}
+
+	return &http.Client{
+		Timeout: outboxPostTimeout,
+		Transport: &http.Transport{
+			Proxy:                 webhookProxyURL,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return validateWebhookURL(req.URL.String())
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/motemen/prchecklist/v2"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	defer func() { webhookAllowedHosts = "" }()
+
+	assert.NoError(t, validateWebhookURL("https://hooks.slack.com/services/XXX"))
+	assert.NoError(t, validateWebhookURL("https://203.0.113.1/hook"))
+
+	for _, u := range []string{
+		"ftp://hooks.slack.com/",
+		"https:///path",
+		"http://127.0.0.1:8080/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.1.2.3/",
+		"http://[::1]/",
+		"http://[fe80::1]/",
+		"http://[::ffff:192.168.0.1]/",
+	} {
+		err := validateWebhookURL(u)
+		assert.Error(t, err, u)
+		assert.True(t, isWebhookRejected(err), u)
+	}
+
+	webhookAllowedHosts = "hooks.slack.com, *.example.com"
+	assert.NoError(t, validateWebhookURL("https://hooks.slack.com/services/XXX"))
+	assert.NoError(t, validateWebhookURL("https://chat.example.com/hook"))
+	assert.True(t, isWebhookRejected(validateWebhookURL("https://example.com/hook")))
+	assert.True(t, isWebhookRejected(validateWebhookURL("https://evil.test/hook")))
+}
+
+func TestPostNotification_rejectsAtDialTime(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Error("should not be reached")
+	}))
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	u.Host = "localhost:" + u.Port()
+
+	err := Usecase{}.postNotification(context.Background(), &prchecklist.OutboxNotification{URL: u.String()})
+	assert.Error(t, err)
+	assert.True(t, isWebhookRejected(err), "%v", err)
+}
+
+func TestUsecase_loadConfig_rejectsWebhook(t *testing.T) {
+	config, err := Usecase{}.loadConfig(context.Background(), prchecklist.ChecklistRef{}, []byte(`
+notification:
+  channels:
+    default:
+      url: http://169.254.169.254/latest/meta-data/
+    ok:
+      url: https://hooks.slack.com/services/XXX
+`))
+	require.NoError(t, err, "bad channels do not break the checklist")
+	assert.NotContains(t, config.Notification.Channels, "default")
+	assert.Contains(t, config.Notification.Channels, "ok")
+	require.Equal(t, 1, len(config.Warnings))
+	assert.Contains(t, config.Warnings[0], `notification channel "default" dropped`)
+}
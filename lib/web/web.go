@@ -3,15 +3,20 @@ package web
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,15 +37,21 @@ import (
 var (
 	sessionSecret = os.Getenv("PRCHECKLIST_SESSION_SECRET")
 	behindProxy   = os.Getenv("PRCHECKLIST_BEHIND_PROXY") != ""
+	adminLogins   = os.Getenv("PRCHECKLIST_ADMIN_LOGINS")
 )
 
 const sessionName = "s"
 
 const (
 	sessionKeyOAuthState = "oauthState"
+	sessionKeyOAuthHost  = "oauthHost"
 	sessionKeyGitHubUser = "githubUser"
+	sessionKeyCSRFToken  = "csrfToken"
 )
 
+// headerCSRFToken is the header in which admin APIs give and receive the CSRF token.
+const headerCSRFToken = "X-CSRF-Token"
+
 var htmlContent = `<!DOCTYPE html>
 <html>
 <head>
@@ -61,6 +72,7 @@ var htmlContent = `<!DOCTYPE html>
 func init() {
 	flag.StringVar(&sessionSecret, "session-secret", sessionSecret, "session secret (PRCHECKLIST_SESSION_SECRET)")
 	flag.BoolVar(&behindProxy, "behind-proxy", behindProxy, "prchecklist is behind a reverse proxy (PRCHECKLIST_BEHIND_PROXY)")
+	flag.StringVar(&adminLogins, "admin-logins", adminLogins, "comma-separated GitHub logins of administrators (PRCHECKLIST_ADMIN_LOGINS)")
 
 	gob.Register(&prchecklist.GitHubUser{})
 }
@@ -68,9 +80,10 @@ func init() {
 // GitHubGateway is an interface that makes API calls to GitHub (Enterprise).
 // Used for OAuth interaction.
 type GitHubGateway interface {
-	AuthCodeURL(state string, redirectURI *url.URL) string
+	AuthCodeURL(ctx context.Context, state string, redirectURI *url.URL) (string, error)
 	AuthenticateUser(ctx context.Context, code string) (*prchecklist.GitHubUser, error)
 	GetUserFromToken(ctx context.Context, token *oauth2.Token) (*prchecklist.GitHubUser, error)
+	Hosts() []string
 }
 
 // Web is a web server implementation.
@@ -88,6 +101,7 @@ func New(app *usecase.Usecase, github GitHubGateway) *Web {
 		Path:     "/",
 		MaxAge:   int(30 * 24 * time.Hour / time.Second),
 		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	}
 
 	// TODO: write doc about it
@@ -118,11 +132,22 @@ func (web *Web) Handler() http.Handler {
 	router.Handle("/auth", httpHandler(web.handleAuth))
 	router.Handle("/auth/callback", httpHandler(web.handleAuthCallback))
 	router.Handle("/auth/clear", httpHandler(web.handleAuthClear))
-	router.Handle("/api/me", httpHandler(web.handleAPIMe))
-	router.Handle("/api/checklist", httpHandler(web.handleAPIChecklist))
-	router.Handle("/api/check", httpHandler(web.handleAPICheck)).Methods("PUT", "DELETE")
+	router.Handle("/api/me", web.refreshingToken(web.handleAPIMe))
+	router.Handle("/api/checklist", web.refreshingToken(web.handleAPIChecklist))
+	router.Handle("/api/check", web.refreshingToken(web.handleAPICheck)).Methods("PUT", "DELETE")
+	router.Handle("/admin/notifications", httpHandler(web.handleAdminNotifications)).Methods("GET")
+	router.Handle("/admin/notifications/{id}/replay", httpHandler(web.handleAdminNotificationReplay)).Methods("POST")
+	router.Handle("/slack/interactions", httpHandler(web.handleSlackInteractions)).Methods("POST")
+	router.Handle("/slack/link", httpHandler(web.handleSlackLink)).Methods("GET", "POST")
+	router.Handle("/webhook/github", httpHandler(web.handleGitHubWebhook)).Methods("POST")
 	router.Handle("/{owner}/{repo}/pull/{number}", httpHandler(web.handleChecklist))
 	router.Handle("/{owner}/{repo}/pull/{number}/{stage}", httpHandler(web.handleChecklist))
+	// Additional GitHub hosts, whose domains have dots unlike owners
+	router.Handle("/{host:[^/]+\\.[^/]+}/{owner}/{repo}/pull/{number}", httpHandler(web.handleChecklist))
+	router.Handle("/{host:[^/]+\\.[^/]+}/{owner}/{repo}/pull/{number}/{stage}", httpHandler(web.handleChecklist))
+	// GitLab, whose owners may have slashes
+	router.Handle("/{owner:.+}/{repo}/-/merge_requests/{number:[0-9]+}", httpHandler(web.handleChecklist))
+	router.Handle("/{owner:.+}/{repo}/-/merge_requests/{number:[0-9]+}/{stage}", httpHandler(web.handleChecklist))
 	router.PathPrefix("/js/").Handler(http.FileServer(&assetfs.AssetFS{Asset: Asset, AssetDir: AssetDir, AssetInfo: AssetInfo}))
 
 	if testToken := os.Getenv("PRCHECKLIST_TEST_GITHUB_TOKEN"); testToken != "" {
@@ -151,6 +176,11 @@ func (h httpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		log.Printf("ServeHTTP: %s (%+v)", err, err)
 
+		if errors.Is(err, prchecklist.ErrNotAuthed) && (strings.HasPrefix(req.URL.Path, "/api/") || req.Method == "GET") {
+			renderNotAuthed(w, req)
+			return
+		}
+
 		status := http.StatusInternalServerError
 		if he, ok := err.(httpError); ok {
 			status = int(he)
@@ -171,6 +201,35 @@ func renderJSON(w http.ResponseWriter, v interface{}) error {
 	return nil
 }
 
+// renderNotAuthed tells the visitor to authenticate again,
+// by an ErrorResponse for APIs or by redirecting to /auth for pages.
+func renderNotAuthed(w http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		renderJSON(w, &prchecklist.ErrorResponse{
+			Type:    prchecklist.ErrorTypeNotAuthed,
+			Message: prchecklist.ErrNotAuthed.Error(),
+		})
+		return
+	}
+
+	http.Redirect(w, req, "/auth?"+url.Values{"return_to": {req.URL.Path}}.Encode(), http.StatusFound)
+}
+
+// renderRateLimited responds with 429 telling until when GitHub API is rate limited.
+func renderRateLimited(w http.ResponseWriter, err *prchecklist.RateLimitedError) error {
+	if wait := time.Until(err.Until); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	return renderJSON(w, &prchecklist.ErrorResponse{
+		Type:    prchecklist.ErrorTypeRateLimited,
+		Message: err.Error(),
+	})
+}
+
 func (web *Web) handleAuth(w http.ResponseWriter, req *http.Request) error {
 	sess, _ := web.sessionStore.Get(req, sessionName)
 
@@ -179,17 +238,24 @@ func (web *Web) handleAuth(w http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
+	returnTo := req.URL.Query().Get("return_to")
+	host := req.URL.Query().Get("host")
+	if host == "" {
+		host = web.hostFromPath(returnTo)
+	}
+
 	sess.Values[sessionKeyOAuthState] = state
+	sess.Values[sessionKeyOAuthHost] = host
 	err = web.sessionStore.Save(req, w, sess)
 	if err != nil {
 		return err
 	}
 
-	ctx := prchecklist.RequestContext(req)
+	ctx := prchecklist.ContextWithHost(prchecklist.RequestContext(req), host)
 
 	callback := prchecklist.BuildURL(ctx, "/auth/callback")
 
-	if returnTo := req.URL.Query().Get("return_to"); returnTo != "" {
+	if returnTo != "" {
 		callback.RawQuery = url.Values{"return_to": {returnTo}}.Encode()
 	}
 
@@ -198,13 +264,59 @@ func (web *Web) handleAuth(w http.ResponseWriter, req *http.Request) error {
 		callback = web.oauthForwarder.CreateURL(callback.String())
 	}
 
-	authURL := web.github.AuthCodeURL(state, callback)
+	authURL, err := web.github.AuthCodeURL(ctx, state, callback)
+	if err != nil {
+		return err
+	}
 
 	http.Redirect(w, req, authURL, http.StatusFound)
 
 	return nil
 }
 
+// hostFromPath returns the GitHub host of the checklist at path, or empty for the default host.
+// Only the configured hosts are taken, as GitLab groups may have dots as well.
+func (web *Web) hostFromPath(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	for _, host := range web.github.Hosts() {
+		if segment == host {
+			return host
+		}
+	}
+	return ""
+}
+
+// csrfToken returns the token bound to the session of the visitor, to be embedded in forms
+// and verified by verifyCSRFToken when they are submitted.
+func (web *Web) csrfToken(w http.ResponseWriter, req *http.Request) (string, error) {
+	sess, err := web.sessionStore.Get(req, sessionName)
+	if err != nil {
+		return "", errors.Wrapf(err, "sessionStore.Get")
+	}
+
+	if token, ok := sess.Values[sessionKeyCSRFToken].(string); ok && token != "" {
+		return token, nil
+	}
+
+	token, err := makeRandomString()
+	if err != nil {
+		return "", err
+	}
+	sess.Values[sessionKeyCSRFToken] = token
+	return token, sess.Save(req, w)
+}
+
+// verifyCSRFToken reports whether token is the one of the session given by csrfToken.
+func (web *Web) verifyCSRFToken(req *http.Request, token string) bool {
+	sess, err := web.sessionStore.Get(req, sessionName)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := sess.Values[sessionKeyCSRFToken].(string)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
 func makeRandomString() (string, error) {
 	buf := make([]byte, 16)
 	_, err := rand.Read(buf)
@@ -264,9 +376,11 @@ func (web *Web) handleAuthCallback(w http.ResponseWriter, req *http.Request) err
 		return httpError(http.StatusBadRequest)
 	}
 
+	host, _ := sess.Values[sessionKeyOAuthHost].(string)
 	delete(sess.Values, sessionKeyOAuthState)
+	delete(sess.Values, sessionKeyOAuthHost)
 
-	ctx := prchecklist.RequestContext(req)
+	ctx := prchecklist.ContextWithHost(prchecklist.RequestContext(req), host)
 
 	code := req.URL.Query().Get("code")
 	user, err := web.github.AuthenticateUser(ctx, code)
@@ -327,20 +441,89 @@ func (web *Web) getAuthInfo(w http.ResponseWriter, req *http.Request) (*prcheckl
 		return nil, sess.Save(req, w)
 	}
 
+	// Tokens of GitHub Apps expire
+	if !user.Token.Expiry.IsZero() && !user.Token.Valid() {
+		return web.refreshAuthInfo(w, req, user)
+	}
+
 	return user, nil
 }
 
+// refreshAuthInfo refreshes the token of the visitor u and saves it to the session.
+// The visitor is logged out and nil is returned if GitHub does not refresh the token.
+func (web *Web) refreshAuthInfo(w http.ResponseWriter, req *http.Request, u *prchecklist.GitHubUser) (*prchecklist.GitHubUser, error) {
+	sess, err := web.sessionStore.Get(req, sessionName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sessionStore.Get")
+	}
+
+	ctx := prchecklist.ContextWithHost(prchecklist.RequestContext(req), u.Host)
+	refreshed, err := web.app.RefreshUserToken(ctx, *u)
+	if errors.Is(err, prchecklist.ErrNotAuthed) {
+		log.Printf("refreshAuthInfo: %s: logging out", u.Login)
+		delete(sess.Values, sessionKeyGitHubUser)
+		return nil, sess.Save(req, w)
+	} else if err != nil {
+		return nil, err
+	}
+
+	sess.Values[sessionKeyGitHubUser] = &refreshed
+	return &refreshed, sess.Save(req, w)
+}
+
+// refreshingToken wraps h to refresh the token of the visitor and retry h once,
+// when GitHub rejects the token before its expiry, such as after revoked.
+// h must not write responses before failing with prchecklist.ErrNotAuthed.
+func (web *Web) refreshingToken(h httpHandler) httpHandler {
+	return func(w http.ResponseWriter, req *http.Request) error {
+		err := h(w, req)
+		if !errors.Is(err, prchecklist.ErrNotAuthed) {
+			return err
+		}
+
+		u, _ := web.getAuthInfo(w, req)
+		if u == nil {
+			return err
+		}
+
+		u, refreshErr := web.refreshAuthInfo(w, req, u)
+		if refreshErr != nil {
+			return refreshErr
+		}
+		if u == nil {
+			return err
+		}
+
+		return h(w, req)
+	}
+}
+
+// userContext associates the client, the ID and the GitHub host of the visitor u to ctx.
+func userContext(ctx context.Context, u *prchecklist.GitHubUser) context.Context {
+	ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, u.HTTPClient(ctx))
+	ctx = prchecklist.ContextWithUserID(ctx, u.ID)
+	return prchecklist.ContextWithHost(ctx, u.Host)
+}
+
 func (web *Web) handleAPIMe(w http.ResponseWriter, req *http.Request) error {
 	u, _ := web.getAuthInfo(w, req)
 	result := prchecklist.MeResponse{Me: u}
 	if u != nil {
 		ctx := prchecklist.RequestContext(req)
-		ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, u.HTTPClient(ctx))
-		var err error
-		result.PullRequests, err = web.app.GetRecentPullRequests(ctx)
+		ctx = userContext(ctx, u)
+
+		query := req.URL.Query()
+		release, _ := strconv.ParseBool(query.Get("release"))
+		recent, err := web.app.GetRecentPullRequests(ctx, prchecklist.RecentPullRequestsQuery{
+			After:       query.Get("after"),
+			Search:      query.Get("q"),
+			ReleaseOnly: release,
+		})
 		if err != nil {
 			return err
 		}
+		result.PullRequests = recent.PullRequests
+		result.NextCursor = recent.NextCursor
 	}
 
 	return renderJSON(w, &result)
@@ -359,6 +542,7 @@ func (web *Web) handleAPIChecklist(w http.ResponseWriter, req *http.Request) err
 	}
 
 	type inQuery struct {
+		Host   string
 		Owner  string
 		Repo   string
 		Number int
@@ -370,23 +554,44 @@ func (web *Web) handleAPIChecklist(w http.ResponseWriter, req *http.Request) err
 	if err != nil {
 		return err
 	}
+	if in.Host != u.Host {
+		// Authenticated against another host
+		w.WriteHeader(http.StatusForbidden)
+		return renderJSON(w, &prchecklist.ErrorResponse{
+			Type: prchecklist.ErrorTypeNotAuthed,
+		})
+	}
 	if in.Stage == "" {
 		in.Stage = "default"
 	}
 
 	ctx := prchecklist.RequestContext(req)
-	ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, u.HTTPClient(ctx))
+	ctx = userContext(ctx, u)
 
 	cl, err := web.app.GetChecklist(ctx, prchecklist.ChecklistRef{
+		Host:   in.Host,
 		Owner:  in.Owner,
 		Repo:   in.Repo,
 		Number: in.Number,
 		Stage:  in.Stage,
 	})
 	if err != nil {
+		var rlErr *prchecklist.RateLimitedError
+		if errors.As(err, &rlErr) {
+			return renderRateLimited(w, rlErr)
+		}
 		return err
 	}
 
+	if err := web.app.SyncChecklistItems(ctx, cl); err != nil {
+		log.Printf("SyncChecklistItems(%s): %s", cl, err)
+	}
+
+	// Mark the status as pending when the checklist is first viewed
+	if err := web.app.SyncChecklistStatus(ctx, cl); err != nil {
+		log.Printf("SyncChecklistStatus(%s): %s", cl, err)
+	}
+
 	return renderJSON(w, &prchecklist.ChecklistResponse{
 		Checklist: cl,
 		Me:        u,
@@ -403,6 +608,7 @@ func (web *Web) handleAPICheck(w http.ResponseWriter, req *http.Request) error {
 	}
 
 	type inQuery struct {
+		Host          string
 		Owner         string
 		Repo          string
 		Number        int
@@ -422,15 +628,19 @@ func (web *Web) handleAPICheck(w http.ResponseWriter, req *http.Request) error {
 	if in.Stage == "" {
 		in.Stage = "default"
 	}
+	if in.Host != u.Host {
+		return httpError(http.StatusForbidden)
+	}
 
 	clRef := prchecklist.ChecklistRef{
+		Host:   in.Host,
 		Owner:  in.Owner,
 		Repo:   in.Repo,
 		Number: in.Number,
 		Stage:  in.Stage,
 	}
 	ctx := prchecklist.RequestContext(req)
-	ctx = context.WithValue(ctx, prchecklist.ContextKeyHTTPClient, u.HTTPClient(ctx))
+	ctx = userContext(ctx, u)
 
 	log.Printf("handleAPICheck: %s %+v", req.Method, in)
 
@@ -463,7 +673,7 @@ func (web *Web) handleAPICheck(w http.ResponseWriter, req *http.Request) error {
 func (web *Web) handleChecklist(w http.ResponseWriter, req *http.Request) error {
 	// handle logged-out state earlier than APIs called
 	u, _ := web.getAuthInfo(w, req)
-	if u == nil {
+	if u == nil || u.Host != mux.Vars(req)["host"] {
 		http.Redirect(w, req, "/auth?"+url.Values{"return_to": {req.URL.Path}}.Encode(), http.StatusFound)
 		return nil
 	}
@@ -471,3 +681,209 @@ func (web *Web) handleChecklist(w http.ResponseWriter, req *http.Request) error
 	fmt.Fprint(w, htmlContent)
 	return nil
 }
+
+func isAdmin(u *prchecklist.GitHubUser) bool {
+	// Admins are the users of the default host
+	if u == nil || u.Host != "" {
+		return false
+	}
+	for _, login := range strings.Split(adminLogins, ",") {
+		if strings.TrimSpace(login) == u.Login {
+			return true
+		}
+	}
+	return false
+}
+
+func (web *Web) handleAdminNotifications(w http.ResponseWriter, req *http.Request) error {
+	u, err := web.getAuthInfo(w, req)
+	if err != nil {
+		return err
+	}
+	if !isAdmin(u) {
+		return httpError(http.StatusForbidden)
+	}
+
+	state := prchecklist.OutboxState(req.URL.Query().Get("state"))
+	if state == "" {
+		state = prchecklist.OutboxStateDead
+	}
+
+	notifications, err := web.app.GetOutboxNotifications(req.Context(), state)
+	if err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		n.URL = maskURL(n.URL)
+	}
+
+	// To be sent back on replaying
+	csrfToken, err := web.csrfToken(w, req)
+	if err != nil {
+		return err
+	}
+	w.Header().Set(headerCSRFToken, csrfToken)
+
+	return renderJSON(w, notifications)
+}
+
+func (web *Web) handleAdminNotificationReplay(w http.ResponseWriter, req *http.Request) error {
+	u, err := web.getAuthInfo(w, req)
+	if err != nil {
+		return err
+	}
+	if !isAdmin(u) {
+		return httpError(http.StatusForbidden)
+	}
+	if !web.verifyCSRFToken(req, req.Header.Get(headerCSRFToken)) {
+		return httpError(http.StatusForbidden)
+	}
+
+	n, err := web.app.ReplayOutboxNotification(req.Context(), mux.Vars(req)["id"])
+	if errors.Is(err, prchecklist.ErrOutboxNotificationLeased) {
+		return httpError(http.StatusConflict)
+	} else if err != nil {
+		return err
+	}
+	if n == nil {
+		return httpError(http.StatusNotFound)
+	}
+	n.URL = maskURL(n.URL)
+
+	return renderJSON(w, n)
+}
+
+// maskURL hides the path of webhook URLs, which are secrets, leaving the host to tell them.
+func maskURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+func (web *Web) handleSlackInteractions(w http.ResponseWriter, req *http.Request) error {
+	if !usecase.SlackInteractivityEnabled() {
+		return httpError(http.StatusNotFound)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if err := usecase.VerifySlackRequest(req.Header, body, time.Now()); err != nil {
+		log.Printf("VerifySlackRequest: %s", err)
+		return httpError(http.StatusUnauthorized)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return httpError(http.StatusBadRequest)
+	}
+
+	ctx := prchecklist.RequestContext(req)
+	if err := web.app.HandleSlackInteraction(ctx, []byte(form.Get("payload"))); err != nil {
+		log.Printf("HandleSlackInteraction: %s", err)
+		return httpError(http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (web *Web) handleGitHubWebhook(w http.ResponseWriter, req *http.Request) error {
+	if !usecase.GitHubWebhookEnabled() {
+		return httpError(http.StatusNotFound)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if err := usecase.VerifyGitHubWebhook(req.Header, body); err != nil {
+		log.Printf("VerifyGitHubWebhook: %s", err)
+		return httpError(http.StatusUnauthorized)
+	}
+
+	// Webhooks of additional hosts are set up with ?host=<domain>
+	ctx := prchecklist.ContextWithHost(prchecklist.RequestContext(req), req.URL.Query().Get("host"))
+	if err := web.app.HandleGitHubWebhook(ctx, req.Header.Get("X-GitHub-Event"), body); err != nil {
+		log.Printf("HandleGitHubWebhook: %s", err)
+		return httpError(http.StatusBadRequest)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+var slackLinkTemplate = template.Must(template.New("slackLink").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta name=viewport content="width=device-width">
+  <title>prchecklist</title>
+</head>
+<body>
+  <form method="POST" action="/slack/link">
+    <p>Link Slack user <strong>{{if .Request.UserName}}@{{.Request.UserName}}{{else}}{{.Request.UserID}}{{end}}</strong>
+      of workspace <strong>{{if .Request.TeamDomain}}{{.Request.TeamDomain}}{{else}}{{.Request.TeamID}}{{end}}</strong>
+      to GitHub user <strong>{{.Login}}</strong>?</p>
+    <p>Checks from Slack by the Slack user will be made as the GitHub user. Do not proceed if you did not request this link from Slack yourself.</p>
+    <input type="hidden" name="token" value="{{.Token}}">
+    <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+    <button type="submit">Link</button>
+  </form>
+</body>
+</html>
+`))
+
+// handleSlackLink shows the Slack user to be linked to the visitor on GET,
+// and links them on POST from the page, so that the link sent to someone else cannot be used to link them.
+func (web *Web) handleSlackLink(w http.ResponseWriter, req *http.Request) error {
+	if !usecase.SlackInteractivityEnabled() {
+		return httpError(http.StatusNotFound)
+	}
+
+	u, _ := web.getAuthInfo(w, req)
+	if u == nil {
+		if req.Method != "GET" {
+			return httpError(http.StatusForbidden)
+		}
+		http.Redirect(w, req, "/auth?"+url.Values{"return_to": {req.URL.RequestURI()}}.Encode(), http.StatusFound)
+		return nil
+	}
+
+	if req.Method == "GET" {
+		token := req.URL.Query().Get("token")
+		r, err := web.app.ParseSlackLinkToken(token)
+		if err != nil {
+			log.Printf("ParseSlackLinkToken: %s", err)
+			return httpError(http.StatusBadRequest)
+		}
+
+		csrfToken, err := web.csrfToken(w, req)
+		if err != nil {
+			return err
+		}
+
+		return slackLinkTemplate.Execute(w, map[string]interface{}{
+			"Request":   r,
+			"Login":     u.Login,
+			"Token":     token,
+			"CSRFToken": csrfToken,
+		})
+	}
+
+	if !web.verifyCSRFToken(req, req.PostFormValue("csrf_token")) {
+		return httpError(http.StatusForbidden)
+	}
+
+	ctx := prchecklist.RequestContext(req)
+	if err := web.app.LinkSlackUser(ctx, req.PostFormValue("token"), *u); err != nil {
+		log.Printf("LinkSlackUser: %s", err)
+		return httpError(http.StatusBadRequest)
+	}
+
+	fmt.Fprintf(w, "Your Slack account is linked to GitHub user %s. You can now check items from Slack.\n", u.Login)
+	return nil
+}
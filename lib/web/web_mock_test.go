@@ -6,51 +6,53 @@ package web
 
 import (
 	context "context"
+	url "net/url"
+	reflect "reflect"
+
 	gomock "github.com/golang/mock/gomock"
 	prchecklist "github.com/motemen/prchecklist/v2"
 	oauth2 "golang.org/x/oauth2"
-	url "net/url"
-	reflect "reflect"
 )
 
-// MockGitHubGateway is a mock of GitHubGateway interface
+// MockGitHubGateway is a mock of GitHubGateway interface.
 type MockGitHubGateway struct {
 	ctrl     *gomock.Controller
 	recorder *MockGitHubGatewayMockRecorder
 }
 
-// MockGitHubGatewayMockRecorder is the mock recorder for MockGitHubGateway
+// MockGitHubGatewayMockRecorder is the mock recorder for MockGitHubGateway.
 type MockGitHubGatewayMockRecorder struct {
 	mock *MockGitHubGateway
 }
 
-// NewMockGitHubGateway creates a new mock instance
+// NewMockGitHubGateway creates a new mock instance.
 func NewMockGitHubGateway(ctrl *gomock.Controller) *MockGitHubGateway {
 	mock := &MockGitHubGateway{ctrl: ctrl}
 	mock.recorder = &MockGitHubGatewayMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockGitHubGateway) EXPECT() *MockGitHubGatewayMockRecorder {
 	return m.recorder
 }
 
-// AuthCodeURL mocks base method
-func (m *MockGitHubGateway) AuthCodeURL(arg0 string, arg1 *url.URL) string {
+// AuthCodeURL mocks base method.
+func (m *MockGitHubGateway) AuthCodeURL(arg0 context.Context, arg1 string, arg2 *url.URL) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AuthCodeURL", arg0, arg1)
+	ret := m.ctrl.Call(m, "AuthCodeURL", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// AuthCodeURL indicates an expected call of AuthCodeURL
-func (mr *MockGitHubGatewayMockRecorder) AuthCodeURL(arg0, arg1 interface{}) *gomock.Call {
+// AuthCodeURL indicates an expected call of AuthCodeURL.
+func (mr *MockGitHubGatewayMockRecorder) AuthCodeURL(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthCodeURL", reflect.TypeOf((*MockGitHubGateway)(nil).AuthCodeURL), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthCodeURL", reflect.TypeOf((*MockGitHubGateway)(nil).AuthCodeURL), arg0, arg1, arg2)
 }
 
-// AuthenticateUser mocks base method
+// AuthenticateUser mocks base method.
 func (m *MockGitHubGateway) AuthenticateUser(arg0 context.Context, arg1 string) (*prchecklist.GitHubUser, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AuthenticateUser", arg0, arg1)
@@ -59,13 +61,13 @@ func (m *MockGitHubGateway) AuthenticateUser(arg0 context.Context, arg1 string)
 	return ret0, ret1
 }
 
-// AuthenticateUser indicates an expected call of AuthenticateUser
+// AuthenticateUser indicates an expected call of AuthenticateUser.
 func (mr *MockGitHubGatewayMockRecorder) AuthenticateUser(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateUser", reflect.TypeOf((*MockGitHubGateway)(nil).AuthenticateUser), arg0, arg1)
 }
 
-// GetUserFromToken mocks base method
+// GetUserFromToken mocks base method.
 func (m *MockGitHubGateway) GetUserFromToken(arg0 context.Context, arg1 *oauth2.Token) (*prchecklist.GitHubUser, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetUserFromToken", arg0, arg1)
@@ -74,8 +76,22 @@ func (m *MockGitHubGateway) GetUserFromToken(arg0 context.Context, arg1 *oauth2.
 	return ret0, ret1
 }
 
-// GetUserFromToken indicates an expected call of GetUserFromToken
+// GetUserFromToken indicates an expected call of GetUserFromToken.
 func (mr *MockGitHubGatewayMockRecorder) GetUserFromToken(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFromToken", reflect.TypeOf((*MockGitHubGateway)(nil).GetUserFromToken), arg0, arg1)
 }
+
+// Hosts mocks base method.
+func (m *MockGitHubGateway) Hosts() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hosts")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Hosts indicates an expected call of Hosts.
+func (mr *MockGitHubGatewayMockRecorder) Hosts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hosts", reflect.TypeOf((*MockGitHubGateway)(nil).Hosts))
+}
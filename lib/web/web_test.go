@@ -2,8 +2,18 @@
 package web
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"net/http"
 	"net/http/httptest"
@@ -11,7 +21,14 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/motemen/go-nuts/httputil"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/motemen/prchecklist/v2"
+	"github.com/motemen/prchecklist/v2/lib/mocks"
+	"github.com/motemen/prchecklist/v2/lib/signature"
+	"github.com/motemen/prchecklist/v2/lib/usecase"
 )
 
 var noRedirectClient = http.Client{
@@ -20,10 +37,22 @@ var noRedirectClient = http.Client{
 	},
 }
 
+// sessionCookie creates the cookie of the session logged in as u.
+func sessionCookie(t *testing.T, web *Web, u *prchecklist.GitHubUser) *http.Cookie {
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	sess, err := web.sessionStore.Get(req, sessionName)
+	require.NoError(t, err)
+	sess.Values[sessionKeyGitHubUser] = u
+	require.NoError(t, sess.Save(req, w))
+	return w.Result().Cookies()[0]
+}
+
 func TestWeb_HandleAuth(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	g := NewMockGitHubGateway(ctrl)
+	g.EXPECT().Hosts().Return(nil).AnyTimes()
 
 	web := New(nil, g)
 	s := httptest.NewServer(web.Handler())
@@ -33,7 +62,7 @@ func TestWeb_HandleAuth(t *testing.T) {
 	u.Path = "/auth/callback"
 	u.RawQuery = url.Values{"return_to": {"/motemen/test-repository/pull/2"}}.Encode()
 
-	g.EXPECT().AuthCodeURL(gomock.Any(), u).Return("http://github-auth-stub")
+	g.EXPECT().AuthCodeURL(gomock.Any(), gomock.Any(), u).Return("http://github-auth-stub", nil)
 
 	resp, err := noRedirectClient.Get(s.URL + "/auth?return_to=/motemen/test-repository/pull/2")
 	if err != nil {
@@ -71,6 +100,7 @@ func TestWeb_HandleAuth_forward(t *testing.T) {
 	build := func() testServer {
 		ctrl := gomock.NewController(t)
 		g := NewMockGitHubGateway(ctrl)
+		g.EXPECT().Hosts().Return(nil).AnyTimes()
 		web := New(nil, g)
 		s := httptest.NewServer(web.Handler())
 		return testServer{
@@ -91,8 +121,8 @@ func TestWeb_HandleAuth_forward(t *testing.T) {
 	defer mainApp.server.Close()
 	defer reviewApp.server.Close()
 
-	reviewApp.github.EXPECT().AuthCodeURL(gomock.Any(), gomock.Any()).DoAndReturn(func(state string, redirectURI *url.URL) string {
-		return "http://github-auth-stub?redirect_uri=" + url.QueryEscape(redirectURI.String())
+	reviewApp.github.EXPECT().AuthCodeURL(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, state string, redirectURI *url.URL) (string, error) {
+		return "http://github-auth-stub?redirect_uri=" + url.QueryEscape(redirectURI.String()), nil
 	})
 
 	var redirectURI *url.URL
@@ -156,3 +186,387 @@ func TestWeb_HandleAuth_forward(t *testing.T) {
 		require.True(t, resp.StatusCode >= 400)
 	})
 }
+
+func TestWeb_AdminNotifications_forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	web := New(nil, NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/admin/notifications")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Post(s.URL+"/admin/notifications/xxx/replay", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestWeb_AdminNotifications(t *testing.T) {
+	require.NoError(t, flag.Set("admin-logins", "motemen"))
+	defer flag.Set("admin-logins", "")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreRepo := mocks.NewMockCoreRepository(ctrl)
+	web := New(usecase.New(nil, coreRepo), NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	cookie := sessionCookie(t, web, &prchecklist.GitHubUser{ID: 1, Login: "motemen", Token: &oauth2.Token{AccessToken: "token"}})
+
+	coreRepo.EXPECT().GetOutboxNotifications(gomock.Any(), prchecklist.OutboxStateDead).Return([]*prchecklist.OutboxNotification{
+		{ID: "1", URL: "https://hooks.slack.com/services/XXX/YYY/ZZZ", State: prchecklist.OutboxStateDead},
+	}, nil)
+
+	req, err := http.NewRequest("GET", s.URL+"/admin/notifications", nil)
+	require.NoError(t, err)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotContains(t, string(b), "XXX", "webhook URLs are masked")
+	require.Contains(t, string(b), "https://hooks.slack.com/***")
+
+	csrfToken := resp.Header.Get("X-CSRF-Token")
+	require.NotEmpty(t, csrfToken)
+	for _, c := range resp.Cookies() {
+		if c.Name == cookie.Name {
+			cookie = c
+		}
+	}
+
+	replay := func(csrfToken string) *http.Response {
+		req, err := http.NewRequest("POST", s.URL+"/admin/notifications/1/replay", nil)
+		require.NoError(t, err)
+		req.AddCookie(cookie)
+		if csrfToken != "" {
+			req.Header.Set("X-CSRF-Token", csrfToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Without a CSRF token
+	require.Equal(t, http.StatusForbidden, replay("").StatusCode)
+	require.Equal(t, http.StatusForbidden, replay("invalid").StatusCode)
+
+	updateOutboxNotification := func(n prchecklist.OutboxNotification) func(context.Context, string, func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+		return func(_ context.Context, _ string, update func(*prchecklist.OutboxNotification) error) (*prchecklist.OutboxNotification, error) {
+			return &n, update(&n)
+		}
+	}
+
+	coreRepo.EXPECT().UpdateOutboxNotification(gomock.Any(), "1", gomock.Any()).
+		DoAndReturn(updateOutboxNotification(prchecklist.OutboxNotification{ID: "1", State: prchecklist.OutboxStateDead}))
+	require.Equal(t, http.StatusOK, replay(csrfToken).StatusCode)
+
+	// Being delivered
+	coreRepo.EXPECT().UpdateOutboxNotification(gomock.Any(), "1", gomock.Any()).
+		DoAndReturn(updateOutboxNotification(prchecklist.OutboxNotification{ID: "1", State: prchecklist.OutboxStatePending, LeasedUntil: time.Now().Add(time.Minute)}))
+	require.Equal(t, http.StatusConflict, replay(csrfToken).StatusCode)
+}
+
+func TestWeb_SlackInteractions_unverified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	web := New(nil, NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	resp, err := http.Post(s.URL+"/slack/interactions", "application/x-www-form-urlencoded", strings.NewReader("payload=%7B%7D"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.NoError(t, flag.Set("slack-signing-secret", "s3cret"))
+	defer flag.Set("slack-signing-secret", "")
+
+	req, err := http.NewRequest("POST", s.URL+"/slack/interactions", strings.NewReader("payload=%7B%7D"))
+	require.NoError(t, err)
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set("X-Slack-Signature", "v0=0000")
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWeb_GitHubWebhook_unverified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	web := New(nil, NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	resp, err := http.Post(s.URL+"/webhook/github", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.NoError(t, flag.Set("github-webhook-secret", "s3cret"))
+	defer flag.Set("github-webhook-secret", "")
+
+	req, err := http.NewRequest("POST", s.URL+"/webhook/github", strings.NewReader("{}"))
+	require.NoError(t, err)
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256=0000")
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRenderRateLimited(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, renderRateLimited(w, &prchecklist.RateLimitedError{Until: time.Now().Add(10 * time.Minute)}))
+
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	var resp prchecklist.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, prchecklist.ErrorTypeRateLimited, resp.Type)
+	require.Contains(t, resp.Message, "rate limited until")
+}
+
+func TestWeb_Checklist_gitLabPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	web := New(nil, NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	for _, path := range []string{
+		"/group/subgroup/project/-/merge_requests/12",
+		"/group/project/-/merge_requests/12/production",
+	} {
+		resp, err := noRedirectClient.Get(s.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		// not logged in
+		require.Equal(t, http.StatusFound, resp.StatusCode, path)
+		require.Equal(t, "/auth?"+url.Values{"return_to": {path}}.Encode(), resp.Header.Get("Location"), path)
+	}
+}
+
+func TestWeb_Checklist_hostPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	web := New(nil, NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	path := "/ghe.example.com/motemen/test-repository/pull/2/qa"
+	resp, err := noRedirectClient.Get(s.URL + path)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "/auth?"+url.Values{"return_to": {path}}.Encode(), resp.Header.Get("Location"))
+}
+
+func TestWeb_hostFromPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	g := NewMockGitHubGateway(ctrl)
+	g.EXPECT().Hosts().Return([]string{"ghe.example.com"}).AnyTimes()
+	web := New(nil, g)
+
+	require.Equal(t, "ghe.example.com", web.hostFromPath("/ghe.example.com/motemen/test/pull/1"))
+	require.Equal(t, "", web.hostFromPath("/motemen/test/pull/1"))
+	require.Equal(t, "", web.hostFromPath(""))
+	// GitLab groups with dots
+	require.Equal(t, "", web.hostFromPath("/my.group/proj/-/merge_requests/1"))
+	require.Equal(t, "", web.hostFromPath("/unknown.example.com/motemen/test/pull/1"))
+}
+
+func TestWeb_HandleAPIMe_paging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	g := mocks.NewMockGitHubGateway(ctrl)
+	web := New(usecase.New(g, mocks.NewMockCoreRepository(ctrl)), NewMockGitHubGateway(ctrl))
+	cookie := sessionCookie(t, web, &prchecklist.GitHubUser{ID: 1, Login: "motemen", Token: &oauth2.Token{AccessToken: "token"}})
+
+	getMe := func(query url.Values) prchecklist.MeResponse {
+		req := httptest.NewRequest("GET", "/api/me?"+query.Encode(), nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		web.Handler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp prchecklist.MeResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	g.EXPECT().GetRecentPullRequests(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+		require.Equal(t, "", q.After)
+		require.Equal(t, "fix", q.Search)
+		return &prchecklist.RecentPullRequests{
+			PullRequests: map[string][]*prchecklist.PullRequest{"motemen/test": {{Number: 1}}},
+			NextCursor:   "CURSOR",
+		}, nil
+	})
+	resp := getMe(url.Values{"q": {"fix"}})
+	require.Equal(t, "CURSOR", resp.NextCursor)
+	require.Equal(t, 1, resp.PullRequests["motemen/test"][0].Number)
+
+	// The next page by the cursor, which is the last
+	g.EXPECT().GetRecentPullRequests(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, q prchecklist.RecentPullRequestsQuery) (*prchecklist.RecentPullRequests, error) {
+		require.Equal(t, "CURSOR", q.After)
+		require.Equal(t, "fix", q.Search)
+		return &prchecklist.RecentPullRequests{
+			PullRequests: map[string][]*prchecklist.PullRequest{"motemen/test": {{Number: 2}}},
+		}, nil
+	})
+	resp = getMe(url.Values{"q": {"fix"}, "after": {resp.NextCursor}})
+	require.Equal(t, "", resp.NextCursor)
+	require.Equal(t, 2, resp.PullRequests["motemen/test"][0].Number)
+}
+
+func TestWeb_refreshingToken(t *testing.T) {
+	require.NoError(t, flag.Set("slack-token-key", "k3y"))
+	defer flag.Set("slack-token-key", "")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	g := mocks.NewMockGitHubGateway(ctrl)
+	coreRepo := mocks.NewMockCoreRepository(ctrl)
+	web := New(usecase.New(g, coreRepo), NewMockGitHubGateway(ctrl))
+
+	sessionCookie := func(token *oauth2.Token) *http.Cookie {
+		return sessionCookie(t, web, &prchecklist.GitHubUser{ID: 1, Login: "motemen", Token: token})
+	}
+
+	var tokens []string
+	handler := httpHandler(web.refreshingToken(func(w http.ResponseWriter, req *http.Request) error {
+		u, err := web.getAuthInfo(w, req)
+		require.NoError(t, err)
+		if u == nil {
+			return httpError(http.StatusForbidden)
+		}
+		tokens = append(tokens, u.Token.AccessToken)
+		if u.Token.AccessToken == "revoked" {
+			return errors.Wrap(prchecklist.ErrNotAuthed, "GetChecklist")
+		}
+		return renderJSON(w, "OK")
+	}))
+
+	// Refreshed and retried
+	g.EXPECT().RefreshToken(gomock.Any(), &oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh"}).
+		Return(&oauth2.Token{AccessToken: "refreshed", RefreshToken: "refresh2"}, nil)
+	coreRepo.EXPECT().GetSlackUserLinksOf(gomock.Any(), "", 1).Return([]prchecklist.SlackUserLink{{TeamID: "T1", UserID: "U1", GitHubUserID: 1}}, nil)
+	coreRepo.EXPECT().PutSlackUserLink(gomock.Any(), gomock.Any()).Do(func(_ context.Context, link prchecklist.SlackUserLink) {
+		require.Equal(t, "U1", link.UserID)
+		require.NotEqual(t, "refreshed", link.Token.AccessToken, "sealed")
+	}).Return(nil)
+
+	req := httptest.NewRequest("GET", "/api/checklist", nil)
+	req.AddCookie(sessionCookie(&oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"revoked", "refreshed"}, tokens)
+	require.NotEmpty(t, w.Result().Cookies(), "session is saved")
+
+	// Expired tokens are refreshed beforehand
+	tokens = nil
+	g.EXPECT().RefreshToken(gomock.Any(), gomock.Any()).
+		Return(&oauth2.Token{AccessToken: "refreshed", RefreshToken: "refresh2", Expiry: time.Now().Add(time.Hour)}, nil)
+	coreRepo.EXPECT().GetSlackUserLinksOf(gomock.Any(), "", 1).Return(nil, nil)
+
+	req = httptest.NewRequest("GET", "/api/checklist", nil)
+	req.AddCookie(sessionCookie(&oauth2.Token{AccessToken: "expired", RefreshToken: "refresh3", Expiry: time.Now().Add(-time.Minute)}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"refreshed"}, tokens)
+
+	// Not refreshed
+	tokens = nil
+	g.EXPECT().RefreshToken(gomock.Any(), gomock.Any()).Return(nil, prchecklist.ErrNotAuthed)
+
+	req = httptest.NewRequest("GET", "/api/checklist", nil)
+	req.AddCookie(sessionCookie(&oauth2.Token{AccessToken: "revoked"}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, []string{"revoked"}, tokens)
+
+	var resp prchecklist.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, prchecklist.ErrorTypeNotAuthed, resp.Type)
+}
+
+func TestWeb_SlackLink(t *testing.T) {
+	require.NoError(t, flag.Set("slack-signing-secret", "s3cret"))
+	defer flag.Set("slack-signing-secret", "")
+	require.NoError(t, flag.Set("slack-token-key", "k3y"))
+	defer flag.Set("slack-token-key", "")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreRepo := mocks.NewMockCoreRepository(ctrl)
+	web := New(usecase.New(nil, coreRepo), NewMockGitHubGateway(ctrl))
+	s := httptest.NewServer(web.Handler())
+	defer s.Close()
+
+	message := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("T1:U1:%d:example:mallory", time.Now().Add(time.Hour).Unix())))
+	token := message + "." + signature.SignHex([]byte("s3cret"), []byte(message))
+	cookie := sessionCookie(t, web, &prchecklist.GitHubUser{ID: 1, Login: "alice", Token: &oauth2.Token{AccessToken: "alice"}})
+
+	// GET only asks to confirm, without linking
+	req, err := http.NewRequest("GET", s.URL+"/slack/link?"+url.Values{"token": {token}}.Encode(), nil)
+	require.NoError(t, err)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "@mallory")
+	require.Contains(t, string(body), "example")
+	require.Contains(t, string(body), "alice")
+
+	m := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(string(body))
+	require.NotNil(t, m)
+	csrfToken := m[1]
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionName {
+			cookie = c
+		}
+	}
+
+	post := func(form url.Values) *http.Response {
+		req, err := http.NewRequest("POST", s.URL+"/slack/link", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	require.Equal(t, http.StatusForbidden, post(url.Values{"token": {token}}).StatusCode, "without CSRF token")
+	require.Equal(t, http.StatusForbidden, post(url.Values{"token": {token}, "csrf_token": {"invalid"}}).StatusCode)
+
+	coreRepo.EXPECT().PutSlackUserLink(gomock.Any(), gomock.Any()).Do(func(_ context.Context, link prchecklist.SlackUserLink) {
+		require.Equal(t, "U1", link.UserID)
+		require.Equal(t, "alice", link.GitHubLogin)
+	}).Return(nil)
+	require.Equal(t, http.StatusOK, post(url.Values{"token": {token}, "csrf_token": {csrfToken}}).StatusCode)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
@@ -19,6 +20,8 @@ type ChecklistResponse struct {
 type MeResponse struct {
 	Me           *GitHubUser
 	PullRequests map[string][]*PullRequest
+	// NextCursor is given as "after" to /api/me to get the next page, empty if none.
+	NextCursor string
 }
 
 // Checklist is the main entity of prchecklist.
@@ -29,6 +32,8 @@ type MeResponse struct {
 // and the "release" pull request is about to merge into master.
 type Checklist struct {
 	*PullRequest
+	// Host is the domain of the GitHub hosting the checklist, or empty for the default one.
+	Host   string
 	Stage  string
 	Items  []*ChecklistItem
 	Config *ChecklistConfig
@@ -44,6 +49,16 @@ func (c Checklist) Completed() bool {
 	return true
 }
 
+// Progress returns the number of items checked by any user and the number of all items.
+func (c Checklist) Progress() (checked, total int) {
+	for _, item := range c.Items {
+		if len(item.CheckedBy) > 0 {
+			checked++
+		}
+	}
+	return checked, len(c.Items)
+}
+
 // CompletedChecksOfUser returns whether all the items of user are checked by any user.
 func (c Checklist) CompletedChecksOfUser(user GitHubUserSimple) bool {
 	for _, item := range c.Items {
@@ -64,9 +79,27 @@ func (c Checklist) Item(featNum int) *ChecklistItem {
 	return nil
 }
 
+// Ref returns the ChecklistRef pointing to c.
+func (c Checklist) Ref() ChecklistRef {
+	return ChecklistRef{
+		Host:   c.Host,
+		Owner:  c.Owner,
+		Repo:   c.Repo,
+		Number: c.Number,
+		Stage:  c.Stage,
+	}
+}
+
 // Path returns the path used for the permalink of the checklist c.
 func (c Checklist) Path() string {
 	path := fmt.Sprintf("/%s/%s/pull/%d", c.Owner, c.Repo, c.Number)
+	if c.Provider == ProviderGitLab {
+		// Resembles the URLs of merge requests on GitLab, whose owners may have slashes
+		path = fmt.Sprintf("/%s/%s/-/merge_requests/%d", c.Owner, c.Repo, c.Number)
+	}
+	if c.Host != "" {
+		path = "/" + c.Host + path
+	}
 	if c.Stage != "" {
 		path = path + "/" + c.Stage
 	}
@@ -75,6 +108,9 @@ func (c Checklist) Path() string {
 
 func (c Checklist) String() string {
 	s := fmt.Sprintf("%s/%s#%d", c.Owner, c.Repo, c.Number)
+	if c.Host != "" {
+		s = c.Host + "/" + s
+	}
 	if c.Stage != "default" {
 		s = s + "::" + c.Stage
 	}
@@ -91,11 +127,72 @@ type ChecklistConfig struct {
 			OnCompleteChecksOfUser []string `yaml:"on_complete_checks_of_user"` // channel names
 			OnCheck                []string `yaml:"on_check"`                   // channel names
 			OnRemove               []string `yaml:"on_remove"`                  // channel names
+			OnItemAdded            []string `yaml:"on_item_added"`              // channel names
+			OnItemRemoved          []string `yaml:"on_item_removed"`            // channel names
 		}
-		Channels map[string]struct{ URL string }
+		Templates NotificationTemplates
+		Channels  map[string]NotificationChannel
+		Mentions  map[string]string // GitHub login -> chat user ID
 	}
+	// SummaryComment enables a comment on the release pull request
+	// summarizing the progress of the stages, updated on every check.
+	SummaryComment bool `yaml:"summary_comment"`
+	// Status is how the progress of checklists is published on the head commit,
+	// one of "commit_status" (the default), "check_run" and "none".
+	Status string
+	// Warnings are the problems found in the configuration, such as
+	// notification channels dropped for their bad settings.
+	Warnings []string `yaml:"-" json:",omitempty"`
 }
 
+// Values of ChecklistConfig.Status.
+const (
+	StatusCommitStatus = "commit_status"
+	StatusCheckRun     = "check_run"
+	StatusNone         = "none"
+)
+
+// NotificationTemplates are text/template templates for notification messages
+// by events, which replace the default messages.
+type NotificationTemplates struct {
+	OnComplete             string `yaml:"on_complete"`
+	OnCompleteChecksOfUser string `yaml:"on_complete_checks_of_user"`
+	OnCheck                string `yaml:"on_check"`
+	OnRemove               string `yaml:"on_remove"`
+	OnItemAdded            string `yaml:"on_item_added"`
+	OnItemRemoved          string `yaml:"on_item_removed"`
+}
+
+// NotificationChannel is a destination of notifications,
+// configured under notification.channels in prchecklist.yml.
+type NotificationChannel struct {
+	// URL is the webhook URL, which is kept secret from API responses.
+	URL string `json:"-"`
+	// URLFrom refers to a secret on the server holding the webhook URL,
+	// in the form of "secret:NAME". Used instead of URL if specified.
+	URLFrom string `yaml:"url_from"`
+	// Format is the message format sent to the channel.
+	// "blocks" (the default) sends Slack Block Kit messages,
+	// "text" sends single-line plain text messages and
+	// "json" sends JSON describing the event.
+	Format string
+	// SecretFrom refers to a secret on the server in the form of "secret:NAME",
+	// with which JSON payloads are signed.
+	SecretFrom string `yaml:"secret_from"`
+	// Templates take precedence over the ones of the notification.
+	Templates NotificationTemplates
+	// Digest is a duration such as "5m". If set, events other than completion
+	// are collected over the duration and sent as one message.
+	Digest string
+}
+
+// Notification channel formats.
+const (
+	NotificationFormatBlocks = "blocks"
+	NotificationFormatText   = "text"
+	NotificationFormatJSON   = "json"
+)
+
 // ChecklistItem is a checklist item, which belongs to a Checklist
 // and can be checked by multiple GitHubUsers.
 type ChecklistItem struct {
@@ -144,6 +241,8 @@ func (c Checks) Remove(featNum string, user GitHubUser) bool {
 
 // ChecklistRef represents a pointer to Checklist.
 type ChecklistRef struct {
+	// Host is the domain of the GitHub hosting the checklist, or empty for the default one.
+	Host   string
 	Owner  string
 	Repo   string
 	Number int
@@ -151,7 +250,11 @@ type ChecklistRef struct {
 }
 
 func (clRef ChecklistRef) String() string {
-	return fmt.Sprintf("%s/%s#%d::%s", clRef.Owner, clRef.Repo, clRef.Number, clRef.Stage)
+	s := fmt.Sprintf("%s/%s#%d::%s", clRef.Owner, clRef.Repo, clRef.Number, clRef.Stage)
+	if clRef.Host != "" {
+		s = clRef.Host + "/" + s
+	}
+	return s
 }
 
 // Validate validates is clRef is valid or returns error.
@@ -163,7 +266,10 @@ func (clRef ChecklistRef) Validate() error {
 	return nil
 }
 
-// PullRequest represens a pull request on GitHub.
+// ProviderGitLab is the Provider of merge requests on GitLab.
+const ProviderGitLab = "gitlab"
+
+// PullRequest represens a pull request on GitHub, or a merge request on GitLab.
 type PullRequest struct {
 	URL       string
 	Title     string
@@ -173,6 +279,21 @@ type PullRequest struct {
 	Number    int
 	IsPrivate bool
 	User      GitHubUserSimple
+	// Provider is the service hosting the pull request, ProviderGitLab or empty for GitHub.
+	Provider string
+
+	// Filled for "feature" pull reqs
+	Labels             []string
+	MergedAt           *time.Time
+	MergedBy           *GitHubUserSimple
+	RequestedReviewers []GitHubUserSimple
+	ApprovedBy         []GitHubUserSimple
+	ChangedFiles       int
+	Additions          int
+	Deletions          int
+	// CIStatus is the combined status of the checks and commit statuses of the head commit,
+	// one of "success", "pending", "failure" and "error", or empty if none.
+	CIStatus string
 
 	// Filled for "base" pull reqs
 	Commits      []Commit
@@ -197,7 +318,9 @@ type GitHubUser struct {
 	ID        int
 	Login     string
 	AvatarURL string
-	Token     *oauth2.Token `json:"-"`
+	// Host is the domain of the GitHub the user authenticated against, or empty for the default one.
+	Host  string
+	Token *oauth2.Token `json:"-"`
 }
 
 // HTTPClient creates an *http.Client which uses u.Token
@@ -208,7 +331,8 @@ func (u GitHubUser) HTTPClient(ctx context.Context) *http.Client {
 
 // ErrorResponse corresponds to JSON containing error results in APIs.
 type ErrorResponse struct {
-	Type ErrorType
+	Type    ErrorType
+	Message string
 }
 
 // ErrorType indicates the type of ErrorResponse.
@@ -217,4 +341,10 @@ type ErrorType string
 const (
 	// ErrorTypeNotAuthed means: Visitor has not been authenticated. Should visit /auth
 	ErrorTypeNotAuthed ErrorType = "not_authed"
+	// ErrorTypeRateLimited means: GitHub API is rate limited. Message tells until when
+	ErrorTypeRateLimited ErrorType = "rate_limited"
 )
+
+// ErrNotAuthed is returned when GitHub (or GitLab) rejects the token of the request,
+// which may have been expired or revoked.
+var ErrNotAuthed = errors.New("token is expired or revoked")
@@ -2,6 +2,11 @@ package prchecklist
 
 import "testing"
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 func TestChecksKeyFeatureNum(t *testing.T) {
 }
 
@@ -53,6 +58,20 @@ func TestChecklist_Completed(t *testing.T) {
 	}
 }
 
+func TestChecklist_Progress(t *testing.T) {
+	checklist := makeStubChecklist()
+
+	if checked, total := checklist.Progress(); checked != 0 || total != 3 {
+		t.Errorf("expected 0/3 but got %d/%d", checked, total)
+	}
+
+	checklist.Items[0].CheckedBy = append(checklist.Items[0].CheckedBy, GitHubUser{}, GitHubUser{})
+	checklist.Items[2].CheckedBy = append(checklist.Items[2].CheckedBy, GitHubUser{})
+	if checked, total := checklist.Progress(); checked != 2 || total != 3 {
+		t.Errorf("expected 2/3 but got %d/%d", checked, total)
+	}
+}
+
 func TestChecklist_CompletedChecksOfUser(t *testing.T) {
 	checklist := makeStubChecklist()
 
@@ -97,15 +116,50 @@ func TestChecklist_Path(t *testing.T) {
 	if expected, got := "/motemen/test/pull/1", checklist.Path(); got != expected {
 		t.Errorf("expected %v but got %v", expected, got)
 	}
+
+	checklist.Provider = ProviderGitLab
+	checklist.Owner = "motemen/group"
+	checklist.Stage = "qa"
+	if expected, got := "/motemen/group/test/-/merge_requests/1/qa", checklist.Path(); got != expected {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+
+	checklist = makeStubChecklist()
+	checklist.Host = "ghe.example.com"
+	if expected, got := "/ghe.example.com/motemen/test/pull/1", checklist.Path(); got != expected {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
 }
 
 func TestChecklist_String(t *testing.T) {
 }
+
+func TestChecklistConfig_redactsChannelURL(t *testing.T) {
+	var config ChecklistConfig
+	config.Notification.Channels = map[string]NotificationChannel{
+		"default": {URL: "https://hooks.slack.com/services/SECRET"},
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "SECRET") {
+		t.Errorf("webhook URL leaked: %s", b)
+	}
+}
 func TestChecks_Add(t *testing.T) {
 }
 func TestChecks_Remove(t *testing.T) {
 }
 func TestChecklistRef_String(t *testing.T) {
+	ref := ChecklistRef{Owner: "motemen", Repo: "test", Number: 1, Stage: "qa"}
+	hostRef := ref
+	hostRef.Host = "ghe.example.com"
+	if got := hostRef.String(); got != "ghe.example.com/"+ref.String() {
+		t.Errorf("expected host prefixed but got %v", got)
+	}
 }
 func TestChecklistRef_Validate(t *testing.T) {
 }
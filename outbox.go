@@ -0,0 +1,68 @@
+package prchecklist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OutboxNotification is an outgoing notification persisted in the outbox
+// until it is delivered.
+type OutboxNotification struct {
+	ID string
+	// Checklist and Channel are for the information of administrators.
+	Checklist string
+	Channel   string
+
+	URL         string
+	ContentType string
+	Body        []byte
+	// SecretRef refers to the secret to sign the request with, if any.
+	// The secret itself is not persisted.
+	SecretRef string
+	// Repository is the path of the repository of the checklist,
+	// which SecretRef is resolved from.
+	Repository string
+
+	State         OutboxState
+	Attempts      int
+	NextAttemptAt time.Time
+	// LeasedUntil is set while a worker is delivering the notification,
+	// not to be dispatched or replayed again meanwhile.
+	LeasedUntil time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// Leased reports whether the notification is being delivered at now.
+func (n OutboxNotification) Leased(now time.Time) bool {
+	return n.LeasedUntil.After(now)
+}
+
+// ErrOutboxNotificationLeased is returned on updating a notification being delivered.
+var ErrOutboxNotificationLeased = errors.New("notification is being delivered")
+
+// OutboxState indicates the state of an OutboxNotification.
+// Delivered notifications are removed from the outbox.
+type OutboxState string
+
+const (
+	// OutboxStatePending means the notification is waiting for (re)delivery.
+	OutboxStatePending OutboxState = "pending"
+	// OutboxStateDead means the notification has failed to be delivered too many times.
+	OutboxStateDead OutboxState = "dead"
+	// OutboxStateDigest means the notification is an event collected for a digest,
+	// sent together with the others of the same checklist and channel after the NextAttemptAt of the first one.
+	OutboxStateDigest OutboxState = "digest"
+)
+
+// NewOutboxID generates an ID for an OutboxNotification,
+// which sorts in the order of creation.
+func NewOutboxID(t time.Time) string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return fmt.Sprintf("%016x%s", t.UnixNano(), hex.EncodeToString(buf))
+}
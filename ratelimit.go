@@ -0,0 +1,16 @@
+package prchecklist
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimitedError is returned when GitHub API requests are rate limited,
+// until Until.
+type RateLimitedError struct {
+	Until time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("GitHub API rate limited until %s", e.Until.Local().Format("15:04"))
+}
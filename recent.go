@@ -0,0 +1,22 @@
+package prchecklist
+
+// RecentPullRequestsQuery specifies the pull requests listed on the top page.
+type RecentPullRequestsQuery struct {
+	// After is the cursor of the page, given by RecentPullRequests.NextCursor.
+	After string
+	// Search is a search query for pull requests, such as "repo:owner/name release".
+	Search string
+	// BaseBranches restricts pull requests to the ones into these branches,
+	// or into the default branch of each repository if empty.
+	BaseBranches []string
+	// ReleaseOnly restricts pull requests to release ones, which have merge commits of pull requests.
+	ReleaseOnly bool
+}
+
+// RecentPullRequests is a page of pull requests listed on the top page,
+// grouped by repositories ("owner/name").
+type RecentPullRequests struct {
+	PullRequests map[string][]*PullRequest
+	// NextCursor is the cursor of the next page, empty if this is the last one.
+	NextCursor string
+}
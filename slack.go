@@ -0,0 +1,28 @@
+package prchecklist
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// SlackUserLink links a Slack user to a GitHub user,
+// so that the Slack user can check items on behalf of the GitHub user from Slack.
+type SlackUserLink struct {
+	TeamID string
+	UserID string
+
+	GitHubUserID int
+	GitHubLogin  string
+	// GitHubHost is the domain of the GitHub of the user, or empty for the default one.
+	GitHubHost string
+	Token      *oauth2.Token
+}
+
+// GitHubUser returns the linked GitHubUser with its token.
+func (l SlackUserLink) GitHubUser() GitHubUser {
+	return GitHubUser{
+		ID:    l.GitHubUserID,
+		Login: l.GitHubLogin,
+		Host:  l.GitHubHost,
+		Token: l.Token,
+	}
+}
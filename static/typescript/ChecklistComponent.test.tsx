@@ -9,6 +9,7 @@ test("", async () => {
     <ChecklistComponent
       checklistRef={{
         Number: 1,
+        Host: "",
         Owner: "test",
         Repo: "test",
         Stage: "production",
@@ -13,6 +13,11 @@ interface ChecklistState {
   error?: any;
 }
 
+// redirectToAuth lets the visitor authenticate again and come back.
+function redirectToAuth() {
+  location.href = `/auth?return_to=${encodeURIComponent(location.pathname)}`;
+}
+
 export class ChecklistComponent extends React.Component<
   ChecklistProps,
   ChecklistState
@@ -26,9 +31,7 @@ export class ChecklistComponent extends React.Component<
       .then((data) => {
         if (data instanceof API.APIError) {
           if (data.errorType === "not_authed") {
-            location.href = `/auth?return_to=${encodeURIComponent(
-              location.pathname
-            )}`;
+            redirectToAuth();
             return;
           }
           throw data;
@@ -99,6 +102,13 @@ export class ChecklistComponent extends React.Component<
             <a href={checklist.URL}>#{checklist.Number}</a> {checklist.Title}
           </span>
         </h1>
+        {checklist.Config && checklist.Config.Warnings ? (
+          <ul className="warnings">
+            {checklist.Config.Warnings.map((warning, i) => (
+              <li key={`warning-${i}`}>{warning}</li>
+            ))}
+          </ul>
+        ) : null}
         <div id="checklist-items" className="items">
           <ul>
             {checklist.Items.map((item) => {
@@ -148,12 +158,12 @@ export class ChecklistComponent extends React.Component<
     const checklistRef = this.props.checklistRef;
     if (stages.length) {
       if (stages.findIndex((s) => s === checklistRef.Stage) === -1) {
-        this.navigateToStage(stages[0]);
+        this.navigateToStage(checklist, stages[0]);
         return true;
       }
     } else {
       if (checklistRef.Stage !== "") {
-        this.navigateToStage("");
+        this.navigateToStage(checklist, "");
         return true;
       }
     }
@@ -161,17 +171,13 @@ export class ChecklistComponent extends React.Component<
     return false;
   }
 
-  private navigateToStage(stage: string) {
-    const checklistRef = this.props.checklistRef;
-    if (stage === "") {
-      location.replace(
-        `/${checklistRef.Owner}/${checklistRef.Repo}/pull/${checklistRef.Number}`
-      );
-    } else {
-      location.replace(
-        `/${checklistRef.Owner}/${checklistRef.Repo}/pull/${checklistRef.Number}/${stage}`
-      );
-    }
+  private navigateToStage(checklist: API.Checklist, stage: string) {
+    location.replace(
+      API.checklistPath(
+        { ...this.props.checklistRef, Stage: stage },
+        checklist.Provider
+      )
+    );
   }
 
   private handleOnClickChecklistItem = (
@@ -198,6 +204,13 @@ export class ChecklistComponent extends React.Component<
 
       API.setCheck(this.props.checklistRef, item.Number, checked).then(
         (data) => {
+          if (data instanceof API.APIError) {
+            if (data.errorType === "not_authed") {
+              redirectToAuth();
+              return;
+            }
+            throw data;
+          }
           this.setState({
             checklist: data.Checklist,
             loading: false,
@@ -1,4 +1,4 @@
-export type ErrorType = "not_authed";
+export type ErrorType = "not_authed" | "rate_limited";
 
 /**
  * Checklist is the main entity of prchecklist.
@@ -9,18 +9,42 @@ export type ErrorType = "not_authed";
  * and the "release" pull request is about to merge into master.
  */
 export interface Checklist {
+  Additions: number;
+  ApprovedBy: GitHubUserSimple[];
   Body: string;
+  /**
+   * CIStatus is the combined status of the checks and commit statuses of the head commit,
+   * one of "success", "pending", "failure" and "error", or empty if none.
+   */
+  CIStatus: string;
+  ChangedFiles: number;
   /**
    * Filled for "base" pull reqs
    */
   Commits: Commit[];
   Config: ChecklistConfig;
   ConfigBlobID: string;
+  Deletions: number;
+  /**
+   * Host is the domain of the GitHub hosting the checklist, or empty for the default one.
+   */
+  Host: string;
   IsPrivate: boolean;
   Items: ChecklistItem[];
+  /**
+   * Filled for "feature" pull reqs
+   */
+  Labels: string[];
+  MergedAt: string;
+  MergedBy: GitHubUserSimple;
   Number: number;
   Owner: string;
+  /**
+   * Provider is the service hosting the pull request, ProviderGitLab or empty for GitHub.
+   */
+  Provider: string;
   Repo: string;
+  RequestedReviewers: GitHubUserSimple[];
   Stage: string;
   Title: string;
   URL: string;
@@ -40,35 +64,118 @@ export interface Commit {
 export interface ChecklistConfig {
   Notification: {
     Channels: {
-      [k: string]: {
-        URL: string;
-      };
+      [k: string]: NotificationChannel;
     };
     Events: {
       OnCheck: string[];
       OnComplete: string[];
       OnCompleteChecksOfUser: string[];
+      OnItemAdded: string[];
+      OnItemRemoved: string[];
       OnRemove: string[];
     };
+    Mentions: {
+      [k: string]: string;
+    };
+    Templates: NotificationTemplates;
   };
   Stages: string[];
+  /**
+   * Status is how the progress of checklists is published on the head commit,
+   * one of "commit_status" (the default), "check_run" and "none".
+   */
+  Status: string;
+  /**
+   * SummaryComment enables a comment on the release pull request
+   * summarizing the progress of the stages, updated on every check.
+   */
+  SummaryComment: boolean;
+  /**
+   * Warnings are the problems found in the configuration, such as
+   * notification channels dropped for their bad settings.
+   */
+  Warnings?: string[];
+}
+/**
+ * NotificationChannel is a destination of notifications,
+ * configured under notification.channels in prchecklist.yml.
+ */
+export interface NotificationChannel {
+  /**
+   * Digest is a duration such as "5m". If set, events other than completion
+   * are collected over the duration and sent as one message.
+   */
+  Digest: string;
+  /**
+   * Format is the message format sent to the channel.
+   * "blocks" (the default) sends Slack Block Kit messages,
+   * "text" sends single-line plain text messages and
+   * "json" sends JSON describing the event.
+   */
+  Format: string;
+  /**
+   * SecretFrom refers to a secret on the server in the form of "secret:NAME",
+   * with which JSON payloads are signed.
+   */
+  SecretFrom: string;
+  /**
+   * Templates take precedence over the ones of the notification.
+   */
+  Templates: NotificationTemplates;
+  /**
+   * URLFrom refers to a secret on the server holding the webhook URL,
+   * in the form of "secret:NAME". Used instead of URL if specified.
+   */
+  URLFrom: string;
+}
+/**
+ * NotificationTemplates are text/template templates for notification messages
+ * by events, which replace the default messages.
+ */
+export interface NotificationTemplates {
+  OnCheck: string;
+  OnComplete: string;
+  OnCompleteChecksOfUser: string;
+  OnItemAdded: string;
+  OnItemRemoved: string;
+  OnRemove: string;
 }
 /**
  * ChecklistItem is a checklist item, which belongs to a Checklist
  * and can be checked by multiple GitHubUsers.
  */
 export interface ChecklistItem {
+  Additions: number;
+  ApprovedBy: GitHubUserSimple[];
   Body: string;
+  /**
+   * CIStatus is the combined status of the checks and commit statuses of the head commit,
+   * one of "success", "pending", "failure" and "error", or empty if none.
+   */
+  CIStatus: string;
+  ChangedFiles: number;
   CheckedBy: GitHubUser[];
   /**
    * Filled for "base" pull reqs
    */
   Commits: Commit[];
   ConfigBlobID: string;
+  Deletions: number;
   IsPrivate: boolean;
+  /**
+   * Filled for "feature" pull reqs
+   */
+  Labels: string[];
+  MergedAt: string;
+  MergedBy: GitHubUserSimple;
   Number: number;
   Owner: string;
+  /**
+   * Provider is the service hosting the pull request, ProviderGitLab or empty for GitHub.
+   */
+  Provider: string;
   Repo: string;
+  RequestedReviewers: GitHubUserSimple[];
   Title: string;
   URL: string;
   User: GitHubUserSimple;
@@ -80,6 +187,10 @@ export interface ChecklistItem {
  */
 export interface GitHubUser {
   AvatarURL: string;
+  /**
+   * Host is the domain of the GitHub the user authenticated against, or empty for the default one.
+   */
+  Host: string;
   ID: number;
   Login: string;
 }
@@ -93,6 +204,10 @@ export interface GitHubUserSimple {
  * ChecklistRef represents a pointer to Checklist.
  */
 export interface ChecklistRef {
+  /**
+   * Host is the domain of the GitHub hosting the checklist, or empty for the default one.
+   */
+  Host: string;
   Number: number;
   Owner: string;
   Repo: string;
@@ -113,12 +228,17 @@ export interface Checks {
  */
 export interface ErrorResponse {
   Type: ErrorType;
+  Message: string;
 }
 /**
  * MeResponse represents the JSON for the top page.
  */
 export interface MeResponse {
   Me: GitHubUser;
+  /**
+   * NextCursor is given as "after" to /api/me to get the next page, empty if none.
+   */
+  NextCursor: string;
   PullRequests: {
     [k: string]: PullRequest[];
   };
@@ -127,16 +247,36 @@ export interface MeResponse {
  * PullRequest represens a pull request on GitHub.
  */
 export interface PullRequest {
+  Additions: number;
+  ApprovedBy: GitHubUserSimple[];
   Body: string;
+  /**
+   * CIStatus is the combined status of the checks and commit statuses of the head commit,
+   * one of "success", "pending", "failure" and "error", or empty if none.
+   */
+  CIStatus: string;
+  ChangedFiles: number;
   /**
    * Filled for "base" pull reqs
    */
   Commits: Commit[];
   ConfigBlobID: string;
+  Deletions: number;
   IsPrivate: boolean;
+  /**
+   * Filled for "feature" pull reqs
+   */
+  Labels: string[];
+  MergedAt: string;
+  MergedBy: GitHubUserSimple;
   Number: number;
   Owner: string;
+  /**
+   * Provider is the service hosting the pull request, ProviderGitLab or empty for GitHub.
+   */
+  Provider: string;
   Repo: string;
+  RequestedReviewers: GitHubUserSimple[];
   Title: string;
   URL: string;
   User: GitHubUserSimple;
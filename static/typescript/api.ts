@@ -16,13 +16,30 @@ export {
 } from "./api-schema";
 
 export class APIError {
-  constructor(public errorType: ErrorType) {}
+  constructor(public errorType: ErrorType, public message: string = "") {}
+
+  public toString() {
+    return this.message || this.errorType;
+  }
 }
 
 function asQueryParam(ref: ChecklistRef) {
-  return `owner=${ref.Owner}&repo=${ref.Repo}&number=${ref.Number}&stage=${
-    ref.Stage || ""
-  }`;
+  return `host=${ref.Host || ""}&owner=${ref.Owner}&repo=${ref.Repo}&number=${
+    ref.Number
+  }&stage=${ref.Stage || ""}`;
+}
+
+// checklistPath returns the path of the checklist page, which resembles
+// the URL of the pull request on GitHub, or of the merge request on GitLab.
+// Checklists on additional GitHub hosts are prefixed with the domain.
+export function checklistPath(ref: ChecklistRef, provider: string): string {
+  const path =
+    provider === "gitlab"
+      ? `/${ref.Owner}/${ref.Repo}/-/merge_requests/${ref.Number}`
+      : `${ref.Host ? `/${ref.Host}` : ""}/${ref.Owner}/${ref.Repo}/pull/${
+          ref.Number
+        }`;
+  return ref.Stage ? `${path}/${ref.Stage}` : path;
 }
 
 export function getChecklist(
@@ -36,7 +53,7 @@ export function getChecklist(
         try {
           // If request failed and respnose body was a JSON, it must be an ErrorResponse.
           const err: ErrorResponse = JSON.parse(text);
-          return new APIError(err.Type);
+          return new APIError(err.Type, err.Message);
         } catch (e) {
           // fallthrough
         }
@@ -54,13 +71,19 @@ export function setCheck(
   ref: ChecklistRef,
   featNum: number,
   checked: boolean
-): Promise<ChecklistResponse> {
+): Promise<ChecklistResponse | APIError> {
   return fetch(`/api/check?${asQueryParam(ref)}&featureNumber=${featNum}`, {
     credentials: "same-origin",
     method: checked ? "PUT" : "DELETE",
   }).then((res) => {
     if (!res.ok) {
-      return res.text().then((text) => {
+      return res.text().then((text): APIError | never => {
+        try {
+          const err: ErrorResponse = JSON.parse(text);
+          return new APIError(err.Type, err.Message);
+        } catch (e) {
+          // fallthrough
+        }
         throw new Error(`${res.status} ${res.statusText}\n${text}`);
       });
     }
@@ -68,8 +91,15 @@ export function setCheck(
   });
 }
 
-export function getMe(): Promise<MeResponse> {
-  return fetch("/api/me", {
+export function getMe(
+  params: { after?: string; q?: string; release?: boolean } = {}
+): Promise<MeResponse> {
+  const query = new URLSearchParams();
+  if (params.after) query.set("after", params.after);
+  if (params.q) query.set("q", params.q);
+  if (params.release) query.set("release", "1");
+  const qs = query.toString();
+  return fetch(qs ? `/api/me?${qs}` : "/api/me", {
     credentials: "same-origin",
   }).then((res) => res.json());
 }
@@ -17,6 +17,7 @@ if (/^\/([^/]+)\/([^/]+)\/pull\/(\d+)$/.test(location.pathname)) {
     <EnvContext.Provider value={{ appVersion }}>
       <ChecklistComponent
         checklistRef={{
+          Host: "",
           Owner: RegExp.$1,
           Repo: RegExp.$2,
           Number: parseInt(RegExp.$3, 10),
@@ -33,6 +34,47 @@ if (/^\/([^/]+)\/([^/]+)\/pull\/(\d+)$/.test(location.pathname)) {
     <EnvContext.Provider value={{ appVersion }}>
       <ChecklistComponent
         checklistRef={{
+          Host: "",
+          Owner: RegExp.$1,
+          Repo: RegExp.$2,
+          Number: parseInt(RegExp.$3, 10),
+          Stage: RegExp.$4,
+        }}
+      />
+    </EnvContext.Provider>,
+    document.querySelector("#main")
+  );
+} else if (
+  /^\/([^/]+\.[^/]+)\/([^/]+)\/([^/]+)\/pull\/(\d+)(?:\/([^/]+))?$/.test(
+    location.pathname
+  )
+) {
+  // Additional GitHub hosts, whose domains have dots unlike owners
+  ReactDOM.render(
+    <EnvContext.Provider value={{ appVersion }}>
+      <ChecklistComponent
+        checklistRef={{
+          Host: RegExp.$1,
+          Owner: RegExp.$2,
+          Repo: RegExp.$3,
+          Number: parseInt(RegExp.$4, 10),
+          Stage: RegExp.$5,
+        }}
+      />
+    </EnvContext.Provider>,
+    document.querySelector("#main")
+  );
+} else if (
+  /^\/(.+)\/([^/]+)\/-\/merge_requests\/(\d+)(?:\/([^/]+))?$/.test(
+    location.pathname
+  )
+) {
+  // GitLab, whose owners may have slashes
+  ReactDOM.render(
+    <EnvContext.Provider value={{ appVersion }}>
+      <ChecklistComponent
+        checklistRef={{
+          Host: "",
           Owner: RegExp.$1,
           Repo: RegExp.$2,
           Number: parseInt(RegExp.$3, 10),
@@ -43,34 +85,84 @@ if (/^\/([^/]+)\/([^/]+)\/pull\/(\d+)$/.test(location.pathname)) {
     document.querySelector("#main")
   );
 } else {
-  API.getMe().then((data) => {
-    ReactDOM.render(
-      <EnvContext.Provider value={{ appVersion }}>
-        <section>
-          <NavComponent me={data.Me} />
-          {data.PullRequests ? (
-            <section id="index-pullRequets">
-              {Object.keys(data.PullRequests).map((repoPath: string) => (
-                <div key={`repo-${repoPath}`}>
-                  <h2>{repoPath}</h2>
-                  <ul>
-                    {data.PullRequests[repoPath].map((pr) => (
-                      <li key={`repo-${repoPath}-pr-${pr.Number}`}>
-                        <a href={`${repoPath}/pull/${pr.Number}`}>
-                          #{pr.Number} {pr.Title}
-                        </a>
-                      </li>
-                    ))}
-                  </ul>
-                </div>
-              ))}
-            </section>
-          ) : (
-            []
-          )}
-        </section>
-      </EnvContext.Provider>,
-      document.querySelector("#main")
-    );
-  });
+  const params = new URLSearchParams(location.search);
+  const q = params.get("q") || "";
+  const release = params.get("release") === "1";
+  const nextPageURL = (cursor: string) => {
+    const next = new URLSearchParams();
+    if (q) next.set("q", q);
+    if (release) next.set("release", "1");
+    next.set("after", cursor);
+    return `/?${next.toString()}`;
+  };
+
+  API.getMe({ after: params.get("after") || undefined, q, release }).then(
+    (data) => {
+      ReactDOM.render(
+        <EnvContext.Provider value={{ appVersion }}>
+          <section>
+            <NavComponent me={data.Me} />
+            {data.Me ? (
+              <form id="index-search" method="get" action="/">
+                <input
+                  type="search"
+                  name="q"
+                  defaultValue={q}
+                  placeholder="Search pull requests"
+                />
+                <label>
+                  <input
+                    type="checkbox"
+                    name="release"
+                    value="1"
+                    defaultChecked={release}
+                  />
+                  Release only
+                </label>
+                <button type="submit">Search</button>
+              </form>
+            ) : (
+              []
+            )}
+            {data.PullRequests ? (
+              <section id="index-pullRequets">
+                {Object.keys(data.PullRequests).map((repoPath: string) => (
+                  <div key={`repo-${repoPath}`}>
+                    <h2>{repoPath}</h2>
+                    <ul>
+                      {data.PullRequests[repoPath].map((pr) => (
+                        <li key={`repo-${repoPath}-pr-${pr.Number}`}>
+                          <a
+                            href={
+                              pr.Provider === "gitlab"
+                                ? `/${repoPath}/-/merge_requests/${pr.Number}`
+                                : `${
+                                    data.Me.Host ? `/${data.Me.Host}` : ""
+                                  }/${repoPath}/pull/${pr.Number}`
+                            }
+                          >
+                            #{pr.Number} {pr.Title}
+                          </a>
+                        </li>
+                      ))}
+                    </ul>
+                  </div>
+                ))}
+                {data.NextCursor ? (
+                  <a id="index-nextPage" href={nextPageURL(data.NextCursor)}>
+                    Next page
+                  </a>
+                ) : (
+                  []
+                )}
+              </section>
+            ) : (
+              []
+            )}
+          </section>
+        </EnvContext.Provider>,
+        document.querySelector("#main")
+      );
+    }
+  );
 }
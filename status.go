@@ -0,0 +1,14 @@
+package prchecklist
+
+// CheckRun is a GitHub check run published for the progress of a checklist.
+type CheckRun struct {
+	Name       string
+	HeadSHA    string
+	DetailsURL string
+	// Conclusion is "success" for completed checklists, or empty if still in progress.
+	Conclusion string
+
+	Title   string
+	Summary string
+	Text    string // in Markdown
+}